@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/adapters/logging"
+	"one1-be-chal/internal/adapters/storages/mongo"
+	"one1-be-chal/internal/adapters/storages/mongo/migrations"
+	"os"
+	"strconv"
+	"time"
+)
+
+const usage = `usage: migrate <command>
+
+commands:
+  status     list migrations and when they were applied
+  up         apply every pending migration
+  down [n]   revert the last n applied migrations (default 1)`
+
+// errUsage makes main print the usage.
+var errUsage = errors.New("usage")
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Println(usage)
+			os.Exit(2)
+		}
+		slog.Error("migrating MongoDB failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	config := config.New()
+	ctx := context.Background()
+	logger, err := logging.New(os.Stderr, config.Log.Level, config.Log.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	userDBClient, err := mongo.New(ctx, config.UserDB)
+	if err != nil {
+		return fmt.Errorf("error initializing MongoDB connection: %w", err)
+	}
+	defer userDBClient.Close(ctx)
+
+	migrator, err := migrations.NewMigrator(userDBClient.Client.Database(config.UserDB.Name), migrations.All())
+	if err != nil {
+		return fmt.Errorf("error loading migrations: %w", err)
+	}
+
+	switch args[0] {
+	case "status":
+		return status(ctx, migrator)
+	case "up":
+		applied, err := migrator.Up(ctx)
+		report(applied)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errUsage
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		report(reverted)
+		return err
+	default:
+		return errUsage
+	}
+}
+
+func status(ctx context.Context, migrator *migrations.Migrator) error {
+	applied, err := migrator.Applied(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrator.Migrations() {
+		state := "pending"
+		if record, ok := applied[migration.Version]; ok {
+			state = "applied " + record.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%4d  %-28s  %s\n", migration.Version, state, migration.Description)
+	}
+	return nil
+}
+
+// report tells the user when there was nothing to do, the migrator logs every
+// migration it applies or reverts.
+func report(done []migrations.Migration) {
+	if len(done) == 0 {
+		fmt.Println("Nothing to do")
+	}
+}
@@ -2,39 +2,237 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"one1-be-chal/internal/adapters/config"
 	"one1-be-chal/internal/adapters/handlers"
+	"one1-be-chal/internal/adapters/hashers"
+	"one1-be-chal/internal/adapters/logging"
+	"one1-be-chal/internal/adapters/metrics"
+	"one1-be-chal/internal/adapters/notifiers"
+	"one1-be-chal/internal/adapters/storages/memory"
 	"one1-be-chal/internal/adapters/storages/mongo"
+	"one1-be-chal/internal/adapters/storages/mongo/migrations"
 	"one1-be-chal/internal/adapters/storages/mongo/repositories"
+	"one1-be-chal/internal/adapters/tracing"
+	"one1-be-chal/internal/core/ports"
 	"one1-be-chal/internal/core/services"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
 )
 
+// userCountInterval is how often the users_total gauge is refreshed.
+const userCountInterval = 10 * time.Second
+
 func main() {
+	if err := run(); err != nil {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
+}
+
+func run() error {
+	config := config.New()
+	logger, err := logging.New(os.Stdout, config.Log.Level, config.Log.Format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
 	app := handlers.EchoMiddleware()
 	app.Validator = handlers.NewRequestValidator()
-	config := config.New()
-	ctx := context.Background()
-	userDBClient, err := mongo.New(ctx, config.UserDB)
+	// Startup is logged through slog so every line stays structured.
+	app.HideBanner = true
+	app.HidePort = true
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, config.Tracing)
 	if err != nil {
-		log.Printf("Error initializing MongoDB connection: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error initializing tracing: %w", err)
 	}
-	defer userDBClient.Close(ctx)
-	userDB := userDBClient.Client.Database("backend-challenge")
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("flushing traces", "error", err)
+		}
+	}()
+
+	appMetrics := metrics.New()
+	app.Use(handlers.MetricsMiddleware(appMetrics), handlers.TracingMiddleware)
 
-	userRepo := repositories.NewUserRepository(userDB, "users")
-	userService := services.NewUserService(userRepo)
+	health := handlers.NewHealthHandler(config.Server.ReadinessTimeout)
+
+	notifier, err := notifiers.New(config.Notifier)
+	if err != nil {
+		return fmt.Errorf("error initializing notifier: %w", err)
+	}
+	if config.Notifier.Driver != "smtp" {
+		slog.Warn("messages are not emailed, reset and verification tokens are written locally, use this for development only",
+			"notifier", config.Notifier.Driver)
+	}
+	passwordHasher, err := hashers.New(config.PasswordHashing)
+	if err != nil {
+		return fmt.Errorf("error initializing password hasher: %w", err)
+	}
+
+	var (
+		userRepo             ports.UserRepository
+		refreshTokenRepo     ports.RefreshTokenRepository
+		tokenRevocationStore ports.TokenRevocationStore
+		rateLimitStore       ports.RateLimitStore
+		loginAttemptStore    ports.LoginAttemptStore
+		passwordResetTokens  ports.PasswordResetTokenRepository
+		emailVerifyTokens    ports.EmailVerificationTokenRepository
+	)
+	if config.UserDB.Driver == "memory" {
+		slog.Warn("using in-memory storage, data is lost on restart")
+		userRepo = memory.NewUserRepository()
+		refreshTokenRepo = memory.NewRefreshTokenRepository()
+		tokenRevocationStore = memory.NewTokenRevocationStore()
+		rateLimitStore = memory.NewRateLimitStore()
+		loginAttemptStore = memory.NewLoginAttemptStore()
+		passwordResetTokens = memory.NewPasswordResetTokenRepository()
+		emailVerifyTokens = memory.NewEmailVerificationTokenRepository()
+	} else {
+		userDBClient, err := mongo.New(ctx, config.UserDB)
+		if err != nil {
+			return fmt.Errorf("error initializing MongoDB connection: %w", err)
+		}
+		defer func() {
+			// ctx is already cancelled by now, give the disconnect its own deadline.
+			closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			userDBClient.Close(closeCtx)
+		}()
+		health.Register(userDBClient)
+		userDB := userDBClient.Client.Database(config.UserDB.Name)
+
+		if config.UserDB.MigrateOnBoot {
+			if err := migrate(ctx, userDB); err != nil {
+				return fmt.Errorf("error migrating MongoDB: %w", err)
+			}
+		}
+
+		if config.JWT.RevocationStore == "memory" {
+			tokenRevocationStore = memory.NewTokenRevocationStore()
+		} else {
+			tokenRevocationStore = repositories.NewTokenRevocationStore(userDB, repositories.RevokedTokensCollection)
+		}
+		if config.RateLimit.Store == "memory" {
+			// Every replica counts on its own, so the effective limit scales with them.
+			rateLimitStore = memory.NewRateLimitStore()
+		} else {
+			rateLimitStore = repositories.NewRateLimitStore(userDB, repositories.RateLimitsCollection)
+		}
+		userRepo = repositories.NewUserRepository(userDB, repositories.UsersCollection)
+		refreshTokenRepo = repositories.NewRefreshTokenRepository(userDB, repositories.RefreshTokensCollection)
+		loginAttemptStore = repositories.NewLoginAttemptStore(userDB, repositories.LoginAttemptsCollection)
+		passwordResetTokens = repositories.NewPasswordResetTokenRepository(userDB, repositories.PasswordResetTokensCollection)
+		emailVerifyTokens = repositories.NewEmailVerificationTokenRepository(userDB, repositories.EmailVerificationTokensCollection)
+	}
+
+	userRepo = metrics.InstrumentUserRepository(tracing.TraceUserRepository(userRepo), appMetrics)
+	refreshTokenRepo = tracing.TraceRefreshTokenRepository(refreshTokenRepo)
+	tokenRevocationStore = tracing.TraceTokenRevocationStore(tokenRevocationStore)
+	loginAttemptStore = tracing.TraceLoginAttemptStore(loginAttemptStore)
+	passwordResetTokens = tracing.TracePasswordResetTokenRepository(passwordResetTokens)
+	emailVerifyTokens = tracing.TraceEmailVerificationTokenRepository(emailVerifyTokens)
+	// Deliveries run in the background, requests only queue them.
+	notifierQueue := notifiers.NewQueueNotifier(tracing.TraceNotifier(notifier), config.Notifier.QueueSize, config.Notifier.DrainTimeout)
+	notifier = notifierQueue
+	userService := metrics.InstrumentUserService(
+		services.NewUserService(
+			userRepo,
+			refreshTokenRepo,
+			tokenRevocationStore,
+			loginAttemptStore,
+			passwordResetTokens,
+			emailVerifyTokens,
+			notifier,
+			passwordHasher,
+		),
+		appMetrics,
+	)
 	userHandler := handlers.NewHttpUserHandler(userService, config)
+	jwtMiddleware := handlers.JWTMiddleware(config, tokenRevocationStore)
+	byIP := handlers.KeyByIP(config.Server.TrustProxy)
+	registerLimit := handlers.RateLimitMiddleware(rateLimitStore, "register", config.RateLimit.Register, byIP)
+	loginLimit := handlers.RateLimitMiddleware(rateLimitStore, "login", config.RateLimit.Login, byIP)
+	loginTwoFactorLimit := handlers.RateLimitMiddleware(rateLimitStore, "login_2fa", config.RateLimit.Login, byIP)
+	refreshLimit := handlers.RateLimitMiddleware(rateLimitStore, "token_refresh", config.RateLimit.TokenRefresh, byIP)
+	forgotPasswordLimit := handlers.RateLimitMiddleware(rateLimitStore, "password_forgot", config.RateLimit.PasswordReset, byIP)
+	resetPasswordLimit := handlers.RateLimitMiddleware(rateLimitStore, "password_reset", config.RateLimit.PasswordReset, byIP)
+	verifyEmailLimit := handlers.RateLimitMiddleware(rateLimitStore, "verify_email", config.RateLimit.EmailVerification, byIP)
+	resendVerificationLimit := handlers.RateLimitMiddleware(rateLimitStore, "verify_email_resend", config.RateLimit.EmailVerification, handlers.KeyByUser(byIP))
+	userLimit := handlers.RateLimitMiddleware(rateLimitStore, "user", config.RateLimit.User, handlers.KeyByUser(byIP))
+	verifiedEmail := handlers.VerifiedEmailMiddleware(config.EmailVerification != nil && config.EmailVerification.Required)
 
-	app.POST("/register", userHandler.Register)
-	app.GET("/user/:id", userHandler.GetUserByID, handlers.JWTMiddleware(config))
-	app.GET("/user", userHandler.GetAllUsers, handlers.JWTMiddleware(config))
-	app.PATCH("/user/:id", userHandler.UpdateUser, handlers.JWTMiddleware(config))
-	app.DELETE("/user/:id", userHandler.DeleteUser, handlers.JWTMiddleware(config))
+	app.GET("/metrics", echo.WrapHandler(appMetrics.Handler()))
+	app.GET("/healthz", health.Liveness)
+	app.GET("/readyz", health.Readiness)
+	app.GET("/.well-known/jwks.json", handlers.JWKSHandler(config))
+	app.POST("/register", userHandler.Register, registerLimit)
+	app.POST("/login", userHandler.Login, loginLimit)
+	app.POST("/login/2fa", userHandler.LoginTwoFactor, loginTwoFactorLimit)
+	app.POST("/token/refresh", userHandler.RefreshToken, refreshLimit)
+	app.POST("/password/forgot", userHandler.ForgotPassword, forgotPasswordLimit)
+	app.POST("/password/reset", userHandler.ResetPassword, resetPasswordLimit)
+	app.GET("/verify-email", userHandler.VerifyEmail, verifyEmailLimit)
+	app.POST("/verify-email", userHandler.VerifyEmail, verifyEmailLimit)
+	app.POST("/verify-email/resend", userHandler.ResendVerification, jwtMiddleware, resendVerificationLimit)
+	app.POST("/logout", userHandler.Logout, jwtMiddleware, userLimit)
+	app.GET("/user/:id", userHandler.GetUserByID, jwtMiddleware, userLimit, verifiedEmail)
+	app.GET("/user", userHandler.GetAllUsers, jwtMiddleware, userLimit, verifiedEmail)
+	app.PATCH("/user/:id", userHandler.UpdateUser, jwtMiddleware, userLimit, verifiedEmail, handlers.SelfOrAdminMiddleware)
+	app.DELETE("/user/:id", userHandler.DeleteUser, jwtMiddleware, userLimit, verifiedEmail, handlers.SelfOrAdminMiddleware)
+	app.PUT("/user/:id/password", userHandler.ChangePassword, jwtMiddleware, userLimit, verifiedEmail, handlers.SelfMiddleware)
+	app.POST("/user/:id/2fa", userHandler.EnrollTwoFactor, jwtMiddleware, userLimit, verifiedEmail, handlers.SelfMiddleware)
+	app.POST("/user/:id/2fa/confirm", userHandler.ConfirmTwoFactor, jwtMiddleware, userLimit, verifiedEmail, handlers.SelfMiddleware)
+	app.POST("/user/:id/2fa/disable", userHandler.DisableTwoFactor, jwtMiddleware, userLimit, verifiedEmail, handlers.SelfMiddleware)
+	app.POST("/user/:id/unlock", userHandler.UnlockUser, jwtMiddleware, userLimit, verifiedEmail, handlers.AdminMiddleware)
 
-	go userService.LogTotalUser(ctx)
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		appMetrics.TrackUserCount(ctx, userRepo, userCountInterval)
+	}()
+	// The server still answers requests during the shutdown delay and timeout,
+	// so the notifier keeps delivering until it has stopped.
+	notifierCtx, stopNotifier := context.WithCancel(context.Background())
+	defer stopNotifier()
+	go func() {
+		defer workers.Done()
+		notifierQueue.Run(notifierCtx)
+	}()
 
-	app.Logger.Fatal(app.Start(":8080"))
+	slog.Info("server started", "address", config.Server.Address)
+	// Readiness fails for the shutdown delay before the listener closes.
+	err = handlers.Serve(ctx, app, config.Server.Address, health.ShuttingDown, config.Server.ShutdownDelay, config.Server.ShutdownTimeout)
+	// Stop the workers whether the server was signalled or failed to start.
+	stop()
+	stopNotifier()
+	workers.Wait()
+	if err != nil {
+		return fmt.Errorf("error running server: %w", err)
+	}
+	return nil
+}
+
+func migrate(ctx context.Context, db *mongodriver.Database) error {
+	migrator, err := migrations.NewMigrator(db, migrations.All())
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(ctx)
+	return err
 }
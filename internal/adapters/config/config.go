@@ -1,22 +1,174 @@
 package config
 
 import (
+	"crypto"
+	"fmt"
+	"one1-be-chal/internal/core/domain"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Container struct {
-	UserDB *UserDB
-	JWT    *JWT
+	Log               *Log
+	Tracing           *Tracing
+	Server            *Server
+	UserDB            *UserDB
+	JWT               *JWT
+	RateLimit         *RateLimit
+	Lockout           *Lockout
+	PasswordPolicy    *domain.PasswordPolicy
+	PasswordHashing   *PasswordHashing
+	PasswordReset     *PasswordReset
+	EmailVerification *EmailVerification
+	TwoFactor         *TwoFactor
+	Notifier          *Notifier
+}
+
+type Log struct {
+	Level  string // "debug", "info", "warn" or "error"
+	Format string // "json" or "text"
+}
+
+type Tracing struct {
+	Exporter    string // "none", "stdout" or "otlp"
+	ServiceName string
+	// SampleRatio is the share of new traces that are recorded, traces started
+	// by the caller follow the caller's decision.
+	SampleRatio float64
+}
+
+type Server struct {
+	Address string
+	// ShutdownDelay is how long readiness fails after SIGINT or SIGTERM before
+	// the server stops accepting connections.
+	ShutdownDelay time.Duration
+	// ShutdownTimeout is how long in-flight requests get to finish after SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration
+	// ReadinessTimeout bounds every dependency check of GET /readyz.
+	ReadinessTimeout time.Duration
+	// TrustProxy takes the client IP from X-Forwarded-For / X-Real-IP instead
+	// of the peer address, only enable it behind a proxy that overwrites them.
+	TrustProxy bool
 }
 
 type UserDB struct {
-	URI string
+	URI    string
+	Name   string
+	Driver string // "mongo" or "memory"
+	// MigrateOnBoot applies pending migrations when the server starts, turn it
+	// off to run them with cmd/migrate instead.
+	MigrateOnBoot bool
 }
 
 type JWT struct {
-	SecretKey []byte
+	SecretKey       []byte
+	RefreshTokenTTL time.Duration
+	RevocationStore string // "mongo" or "memory"
+
+	// SigningKey switches token signing from HS256 to RS256/EdDSA when set.
+	SigningKey *SigningKey
+	// VerificationKeys holds every public key accepted by kid, including the
+	// signing key's own, so retired keys keep verifying until their tokens expire.
+	VerificationKeys map[string]crypto.PublicKey
+}
+
+// RateLimit holds the token buckets of the rate limited routes, a nil limit
+// turns limiting off for its route.
+type RateLimit struct {
+	Store string // "mongo" or "memory"
+
+	Register     *domain.RateLimit // per IP
+	Login        *domain.RateLimit // per IP
+	TokenRefresh *domain.RateLimit // per IP
+	// PasswordReset limits POST /password/forgot and /password/reset each, per IP.
+	PasswordReset *domain.RateLimit
+	// EmailVerification limits /verify-email per IP and /verify-email/resend per user.
+	EmailVerification *domain.RateLimit
+	User              *domain.RateLimit // per user, on every authenticated route
+}
+
+// Lockout throttles logins after failed attempts, counted per account and per
+// client IP. The IP policy is looser since many users can share an IP.
+type Lockout struct {
+	Account domain.LockoutPolicy
+	IP      domain.LockoutPolicy
+}
+
+// PasswordHashing selects how new passwords are hashed. Hashes of the other
+// algorithm or with other parameters keep verifying and are replaced on the
+// user's next login.
+type PasswordHashing struct {
+	Algorithm string // "argon2id" or "bcrypt"
+	Argon2id  *Argon2id
+	Bcrypt    *Bcrypt
+}
+
+type Argon2id struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+type Bcrypt struct {
+	Cost int
+}
+
+type PasswordReset struct {
+	TokenTTL time.Duration
+	// URL is the page of the frontend that resets passwords, the token is
+	// added as the token query parameter. Messages only carry the token without it.
+	URL string
+}
+
+// EmailVerification is nil unless EMAIL_VERIFICATION_ENABLED is set, emails
+// are then trusted as given.
+type EmailVerification struct {
+	// Required keeps users whose email isn't verified yet out of every route
+	// but their own account, see handlers.VerifiedEmailMiddleware.
+	Required bool
+	TokenTTL time.Duration
+	// URL is the page of the frontend that verifies emails, the token is
+	// added as the token query parameter. Messages only carry the token without it.
+	URL string
+}
+
+type TwoFactor struct {
+	// Issuer names the service in authenticator apps.
+	Issuer string
+	// PendingTokenTTL is how long a login has to finish the two-factor step
+	// after the password was accepted.
+	PendingTokenTTL time.Duration
+	// RecoveryCodes is how many one-time recovery codes a user gets when
+	// enabling two-factor authentication.
+	RecoveryCodes int
+}
+
+type Notifier struct {
+	// Driver is "log", "file" or "smtp". It has no default, the log and file
+	// drivers write reset and verification tokens where others may read them.
+	Driver string
+	// FilePath is where the file notifier appends messages.
+	FilePath string
+	SMTP     *SMTP
+	// QueueSize is how many messages wait for delivery in the background
+	// before new ones are dropped.
+	QueueSize int
+	// DrainTimeout is how long queued messages still get on shutdown.
+	DrainTimeout time.Duration
+}
+
+type SMTP struct {
+	Host string
+	Port int
+	// Username and Password are optional, they are only sent over TLS or to localhost.
+	Username string
+	Password string
+	From     string
+	// RequireTLS refuses to deliver through servers that don't offer STARTTLS.
+	RequireTLS bool
 }
 
 func New() *Container {
@@ -26,12 +178,210 @@ func New() *Container {
 		panic(err)
 	}
 
+	verificationKeys, err := LoadVerificationKeys(os.Getenv("JWT_VERIFICATION_KEYS"))
+	if err != nil {
+		panic(err)
+	}
+	var signingKey *SigningKey
+	if path := os.Getenv("JWT_SIGNING_KEY_FILE"); path != "" {
+		signingKey, err = LoadSigningKey(os.Getenv("JWT_SIGNING_KEY_ID"), path)
+		if err != nil {
+			panic(err)
+		}
+		verificationKeys[signingKey.ID] = signingKey.Key.Public()
+	}
+
+	var passwordBlocklist map[string]struct{}
+	if path := getString("PASSWORD_BLOCKLIST_FILE", "common-passwords.txt"); path != "off" {
+		passwordBlocklist, err = LoadPasswordBlocklist(path)
+		if err != nil {
+			panic(fmt.Errorf("PASSWORD_BLOCKLIST_FILE: %w", err))
+		}
+	}
+
+	passwordHashing := &PasswordHashing{
+		Algorithm: getString("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		Argon2id: &Argon2id{
+			Memory:      uint32(getInt("PASSWORD_ARGON2ID_MEMORY", 19*1024)),
+			Iterations:  uint32(getInt("PASSWORD_ARGON2ID_ITERATIONS", 2)),
+			Parallelism: uint8(getInt("PASSWORD_ARGON2ID_PARALLELISM", 1)),
+		},
+		Bcrypt: &Bcrypt{
+			Cost: getInt("PASSWORD_BCRYPT_COST", 10),
+		},
+	}
+
+	// Failures are forgotten LOGIN_LOCK_DURATION after the last one, zero
+	// would forget them right away and turn off backoff and lockout alike.
+	lockDuration := mustGetPositiveDuration("LOGIN_LOCK_DURATION", 15*time.Minute)
+
 	return &Container{
+		Log: &Log{
+			Level:  getString("LOG_LEVEL", "info"),
+			Format: getString("LOG_FORMAT", "json"),
+		},
+		Tracing: &Tracing{
+			Exporter:    getString("TRACING_EXPORTER", "none"),
+			ServiceName: getString("OTEL_SERVICE_NAME", "backend-challenge"),
+			SampleRatio: getFloat("TRACING_SAMPLE_RATIO", 1),
+		},
+		Server: &Server{
+			Address:          getString("SERVER_ADDRESS", ":8080"),
+			ShutdownDelay:    getDuration("SERVER_SHUTDOWN_DELAY", 5*time.Second),
+			ShutdownTimeout:  getDuration("SERVER_SHUTDOWN_TIMEOUT", 10*time.Second),
+			ReadinessTimeout: getDuration("SERVER_READINESS_TIMEOUT", 2*time.Second),
+			TrustProxy:       getBool("SERVER_TRUST_PROXY", false),
+		},
 		UserDB: &UserDB{
-			URI: os.Getenv("MONGODB_URI"),
+			URI:           os.Getenv("MONGODB_URI"),
+			Name:          getString("MONGODB_DATABASE", "backend-challenge"),
+			Driver:        getString("USER_DB_DRIVER", "mongo"),
+			MigrateOnBoot: getBool("USER_DB_MIGRATE_ON_BOOT", true),
 		},
 		JWT: &JWT{
-			SecretKey: []byte(os.Getenv("JWT_SECRET_KEY")),
+			SecretKey:        []byte(os.Getenv("JWT_SECRET_KEY")),
+			RefreshTokenTTL:  getDuration("JWT_REFRESH_TOKEN_TTL", 7*24*time.Hour),
+			RevocationStore:  getString("JWT_REVOCATION_STORE", "mongo"),
+			SigningKey:       signingKey,
+			VerificationKeys: verificationKeys,
+		},
+		RateLimit: &RateLimit{
+			Store:         getString("RATE_LIMIT_STORE", getString("USER_DB_DRIVER", "mongo")),
+			Register:      mustGetRateLimit("RATE_LIMIT_REGISTER", "10/1h"),
+			Login:         mustGetRateLimit("RATE_LIMIT_LOGIN", "10/1m"),
+			TokenRefresh:  mustGetRateLimit("RATE_LIMIT_TOKEN_REFRESH", "30/1m"),
+			User:          mustGetRateLimit("RATE_LIMIT_USER", "120/1m"),
+			PasswordReset: mustGetRateLimit("RATE_LIMIT_PASSWORD_RESET", "5/1h"),
+
+			EmailVerification: mustGetRateLimit("RATE_LIMIT_EMAIL_VERIFICATION", "10/1h"),
+		},
+		Lockout: &Lockout{
+			Account: domain.LockoutPolicy{
+				BackoffAfter: getInt("LOGIN_BACKOFF_AFTER", 3),
+				BaseDelay:    getDuration("LOGIN_BACKOFF_BASE_DELAY", time.Second),
+				MaxDelay:     getDuration("LOGIN_BACKOFF_MAX_DELAY", time.Minute),
+				LockAfter:    getInt("LOGIN_LOCK_AFTER", 10),
+				LockDuration: lockDuration,
+			},
+			IP: domain.LockoutPolicy{
+				BackoffAfter: getInt("LOGIN_IP_BACKOFF_AFTER", 20),
+				BaseDelay:    getDuration("LOGIN_BACKOFF_BASE_DELAY", time.Second),
+				MaxDelay:     getDuration("LOGIN_BACKOFF_MAX_DELAY", time.Minute),
+				LockAfter:    getInt("LOGIN_IP_LOCK_AFTER", 100),
+				LockDuration: lockDuration,
+			},
+		},
+		PasswordPolicy: &domain.PasswordPolicy{
+			MinLength:           getInt("PASSWORD_MIN_LENGTH", 8),
+			MaxBytes:            passwordHashing.MaxPasswordBytes(),
+			MinCharacterClasses: getInt("PASSWORD_MIN_CHARACTER_CLASSES", 2),
+			RejectPersonalInfo:  getBool("PASSWORD_REJECT_PERSONAL_INFO", true),
+			Blocklist:           passwordBlocklist,
+		},
+		PasswordHashing: passwordHashing,
+		PasswordReset: &PasswordReset{
+			TokenTTL: getDuration("PASSWORD_RESET_TOKEN_TTL", 30*time.Minute),
+			URL:      os.Getenv("PASSWORD_RESET_URL"),
+		},
+		EmailVerification: getEmailVerification(),
+		TwoFactor: &TwoFactor{
+			Issuer:          getString("TWO_FACTOR_ISSUER", "backend-challenge"),
+			PendingTokenTTL: getDuration("TWO_FACTOR_PENDING_TOKEN_TTL", 5*time.Minute),
+			RecoveryCodes:   getInt("TWO_FACTOR_RECOVERY_CODES", 10),
+		},
+		Notifier: &Notifier{
+			Driver:       os.Getenv("NOTIFIER"),
+			FilePath:     getString("NOTIFIER_FILE", "notifications.log"),
+			QueueSize:    getInt("NOTIFIER_QUEUE_SIZE", 100),
+			DrainTimeout: getDuration("NOTIFIER_DRAIN_TIMEOUT", 10*time.Second),
+			SMTP: &SMTP{
+				Host:       os.Getenv("SMTP_HOST"),
+				Port:       getInt("SMTP_PORT", 587),
+				Username:   os.Getenv("SMTP_USERNAME"),
+				Password:   os.Getenv("SMTP_PASSWORD"),
+				From:       os.Getenv("SMTP_FROM"),
+				RequireTLS: getBool("SMTP_REQUIRE_TLS", true),
+			},
 		},
 	}
 }
+
+func getString(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getBool reads a bool from the environment, e.g. "true" or "0".
+func getBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getInt reads an int from the environment, e.g. "10".
+func getInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getFloat reads a float64 from the environment, e.g. "0.25".
+func getFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getDuration reads a time.Duration from the environment, e.g. "168h".
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEmailVerification returns nil when email verification is disabled.
+// Requiring verification without enabling it panics.
+func getEmailVerification() *EmailVerification {
+	required := getBool("EMAIL_VERIFICATION_REQUIRED", false)
+	if !getBool("EMAIL_VERIFICATION_ENABLED", false) {
+		if required {
+			panic("EMAIL_VERIFICATION_REQUIRED needs EMAIL_VERIFICATION_ENABLED=true")
+		}
+		return nil
+	}
+	return &EmailVerification{
+		Required: required,
+		TokenTTL: getDuration("EMAIL_VERIFICATION_TOKEN_TTL", 24*time.Hour),
+		URL:      os.Getenv("EMAIL_VERIFICATION_URL"),
+	}
+}
+
+// mustGetRateLimit reads a rate limit from the environment, e.g. "10/1m" or
+// "off". A typo panics rather than silently leaving a route unprotected.
+func mustGetRateLimit(key string, fallback string) *domain.RateLimit {
+	limit, err := domain.ParseRateLimit(getString(key, fallback))
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", key, err))
+	}
+	return limit
+}
+
+// mustGetPositiveDuration reads a duration like getDuration, but panics when
+// it isn't positive.
+func mustGetPositiveDuration(key string, fallback time.Duration) time.Duration {
+	value := getDuration(key, fallback)
+	if value <= 0 {
+		panic(fmt.Errorf("%s: must be positive, got %s", key, value))
+	}
+	return value
+}
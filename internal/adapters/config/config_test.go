@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFromEnv runs New in an empty directory, so only the given environment counts.
+func newFromEnv(t *testing.T, env map[string]string) *Container {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), nil, 0o600))
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	t.Setenv("PASSWORD_BLOCKLIST_FILE", "off")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	return New()
+}
+
+func TestNewEmailVerification(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		assert.Nil(t, newFromEnv(t, nil).EmailVerification)
+	})
+
+	t.Run("not required", func(t *testing.T) {
+		config := newFromEnv(t, map[string]string{"EMAIL_VERIFICATION_ENABLED": "false", "EMAIL_VERIFICATION_REQUIRED": "false"})
+		assert.Nil(t, config.EmailVerification)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		config := newFromEnv(t, map[string]string{"EMAIL_VERIFICATION_ENABLED": "true", "EMAIL_VERIFICATION_REQUIRED": "true"})
+		require.NotNil(t, config.EmailVerification)
+		assert.True(t, config.EmailVerification.Required)
+	})
+
+	t.Run("required without enabling", func(t *testing.T) {
+		assert.Panics(t, func() { newFromEnv(t, map[string]string{"EMAIL_VERIFICATION_REQUIRED": "true"}) })
+	})
+}
+
+func TestNewLockDuration(t *testing.T) {
+	config := newFromEnv(t, map[string]string{"LOGIN_LOCK_DURATION": "5m"})
+	assert.Equal(t, 5*time.Minute, config.Lockout.Account.LockDuration)
+	assert.Equal(t, 5*time.Minute, config.Lockout.IP.LockDuration)
+
+	for _, duration := range []string{"0s", "-1m"} {
+		assert.Panics(t, func() { newFromEnv(t, map[string]string{"LOGIN_LOCK_DURATION": duration}) }, duration)
+	}
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SigningKey is the private key used to sign new tokens, published under ID as the kid header.
+type SigningKey struct {
+	ID  string
+	Key crypto.Signer
+}
+
+// LoadSigningKey reads an RSA or Ed25519 private key in PKCS#8 or PKCS#1 PEM format.
+func LoadSigningKey(id, path string) (*SigningKey, error) {
+	if id == "" {
+		return nil, errors.New("signing key id is required")
+	}
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return &SigningKey{ID: id, Key: key}, nil
+		case ed25519.PrivateKey:
+			return &SigningKey{ID: id, Key: key}, nil
+		default:
+			return nil, fmt.Errorf("%s: unsupported private key type %T", path, key)
+		}
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return &SigningKey{ID: id, Key: key}, nil
+	}
+	return nil, fmt.Errorf("%s: not an RSA or Ed25519 private key", path)
+}
+
+// LoadVerificationKeys parses a comma separated "kid=path" list of public key PEM files.
+func LoadVerificationKeys(spec string) (map[string]crypto.PublicKey, error) {
+	keys := map[string]crypto.PublicKey{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, path, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || path == "" {
+			return nil, fmt.Errorf("invalid verification key %q, expected kid=path", entry)
+		}
+		key, err := LoadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// LoadPublicKey reads an RSA or Ed25519 public key in PKIX or PKCS#1 PEM format.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			return key, nil
+		case ed25519.PublicKey:
+			return key, nil
+		default:
+			return nil, fmt.Errorf("%s: unsupported public key type %T", path, key)
+		}
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s: not an RSA or Ed25519 public key", path)
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
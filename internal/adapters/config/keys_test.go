@@ -0,0 +1,82 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	pkcs8RSA, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	pkcs8Ed, _ := x509.MarshalPKCS8PrivateKey(edKey)
+
+	tests := []struct {
+		Name string
+		Path string
+	}{
+		{Name: "RSA PKCS#1", Path: writePEM(t, "rsa1.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))},
+		{Name: "RSA PKCS#8", Path: writePEM(t, "rsa8.pem", "PRIVATE KEY", pkcs8RSA)},
+		{Name: "Ed25519 PKCS#8", Path: writePEM(t, "ed.pem", "PRIVATE KEY", pkcs8Ed)},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			key, err := LoadSigningKey("key-1", test.Path)
+			assert.NoError(t, err)
+			assert.Equal(t, "key-1", key.ID)
+			assert.NotNil(t, key.Key)
+		})
+	}
+
+	t.Run("missing id", func(t *testing.T) {
+		_, err := LoadSigningKey("", tests[0].Path)
+		assert.Error(t, err)
+	})
+
+	t.Run("not a PEM file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "garbage.pem")
+		assert.NoError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+		_, err := LoadSigningKey("key-1", path)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadVerificationKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+	pkixRSA, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	pkixEd, _ := x509.MarshalPKIXPublicKey(edPublic)
+	rsaPath := writePEM(t, "rsa.pub.pem", "PUBLIC KEY", pkixRSA)
+	edPath := writePEM(t, "ed.pub.pem", "PUBLIC KEY", pkixEd)
+
+	keys, err := LoadVerificationKeys("old=" + rsaPath + ", older=" + edPath)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, &rsaKey.PublicKey, keys["old"])
+	assert.Equal(t, edPublic, keys["older"])
+
+	keys, err = LoadVerificationKeys("")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, err = LoadVerificationKeys(rsaPath)
+	assert.Error(t, err)
+}
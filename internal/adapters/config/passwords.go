@@ -0,0 +1,45 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// bcryptMaxPasswordBytes is the longest password bcrypt hashes, it rejects
+// longer ones.
+const bcryptMaxPasswordBytes = 72
+
+// MaxPasswordBytes is the longest password the configured algorithm can hash,
+// 0 when there is no limit.
+func (c *PasswordHashing) MaxPasswordBytes() int {
+	if c.Algorithm == "bcrypt" {
+		return bcryptMaxPasswordBytes
+	}
+	return 0
+}
+
+// LoadPasswordBlocklist reads a list of common or breached passwords, one per
+// line. Blank lines and lines starting with # are skipped, entries are matched
+// case-insensitively.
+func LoadPasswordBlocklist(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	blocklist := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return blocklist, nil
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPasswordBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwords.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# common passwords\n123456\n\n  Password  \nqwerty\n"), 0o600))
+
+	blocklist, err := LoadPasswordBlocklist(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"123456": {}, "password": {}, "qwerty": {}}, blocklist)
+
+	_, err = LoadPasswordBlocklist(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"one1-be-chal/internal/core/domain"
+
+	"github.com/labstack/echo"
+)
+
+// Stable error codes returned next to the human readable error message.
+const (
+	CodeBadRequest          = "bad_request"
+	CodeValidationFailed    = "validation_failed"
+	CodeUnauthorized        = "unauthorized"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeInvalidRefreshToken = "invalid_refresh_token"
+	CodeRefreshTokenReused  = "refresh_token_reused"
+	CodeTokenRevoked        = "token_revoked"
+	CodeForbidden           = "forbidden"
+	CodeUserNotFound        = "user_not_found"
+	CodeEmailTaken          = "email_taken"
+	CodeRateLimited         = "rate_limited"
+	CodeLoginThrottled      = "login_throttled"
+	CodeIncorrectPassword   = "incorrect_password"
+	CodeWeakPassword        = "weak_password"
+	CodeInvalidResetToken   = "invalid_reset_token"
+	CodeInvalidVerifyToken  = "invalid_verification_token"
+	CodeEmailVerified       = "email_already_verified"
+	CodeEmailNotVerified    = "email_not_verified"
+	CodeVerifyDisabled      = "email_verification_disabled"
+	CodeInvalidMFAToken     = "invalid_mfa_token"
+	CodeInvalidTwoFactor    = "invalid_two_factor_code"
+	CodeTwoFactorEnabled    = "two_factor_already_enabled"
+	CodeTwoFactorNotEnabled = "two_factor_not_enabled"
+	CodeInternalError       = "internal_error"
+)
+
+var domainErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{err: domain.ErrUserNotFound, status: http.StatusNotFound, code: CodeUserNotFound},
+	{err: domain.ErrEmailTaken, status: http.StatusConflict, code: CodeEmailTaken},
+	{err: domain.ErrValidation, status: http.StatusUnprocessableEntity, code: CodeValidationFailed},
+	{err: domain.ErrInvalidCredentials, status: http.StatusUnauthorized, code: CodeInvalidCredentials},
+	{err: domain.ErrInvalidRefreshToken, status: http.StatusUnauthorized, code: CodeInvalidRefreshToken},
+	{err: domain.ErrRefreshTokenReused, status: http.StatusUnauthorized, code: CodeRefreshTokenReused},
+	{err: domain.ErrLoginThrottled, status: http.StatusTooManyRequests, code: CodeLoginThrottled},
+	{err: domain.ErrIncorrectPassword, status: http.StatusForbidden, code: CodeIncorrectPassword},
+	{err: domain.ErrInvalidPasswordResetToken, status: http.StatusBadRequest, code: CodeInvalidResetToken},
+	{err: domain.ErrInvalidEmailVerificationToken, status: http.StatusBadRequest, code: CodeInvalidVerifyToken},
+	{err: domain.ErrEmailAlreadyVerified, status: http.StatusConflict, code: CodeEmailVerified},
+	{err: domain.ErrEmailVerificationDisabled, status: http.StatusNotFound, code: CodeVerifyDisabled},
+	{err: domain.ErrInvalidMFAToken, status: http.StatusUnauthorized, code: CodeInvalidMFAToken},
+	{err: domain.ErrInvalidTwoFactorCode, status: http.StatusForbidden, code: CodeInvalidTwoFactor},
+	{err: domain.ErrTwoFactorAlreadyEnabled, status: http.StatusConflict, code: CodeTwoFactorEnabled},
+	{err: domain.ErrTwoFactorNotEnabled, status: http.StatusConflict, code: CodeTwoFactorNotEnabled},
+}
+
+func errorJSON(c echo.Context, status int, code, message string) error {
+	return c.JSON(status, echo.Map{"error": message, "code": code})
+}
+
+// errorResponse maps domain errors to their HTTP status and code. Anything
+// else is logged and reported as a 500 without leaking its message.
+func errorResponse(c echo.Context, err error) error {
+	var throttled *domain.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Response().Header().Set("Retry-After", seconds(throttled.RetryAfter))
+	}
+	var weak *domain.WeakPasswordError
+	if errors.As(err, &weak) {
+		return c.JSON(http.StatusUnprocessableEntity, echo.Map{
+			"error":      domain.ErrWeakPassword.Error(),
+			"code":       CodeWeakPassword,
+			"violations": weak.Violations,
+		})
+	}
+	for _, mapping := range domainErrors {
+		if errors.Is(err, mapping.err) {
+			return errorJSON(c, mapping.status, mapping.code, err.Error())
+		}
+	}
+	slog.ErrorContext(c.Request().Context(), "unhandled error",
+		"method", c.Request().Method,
+		"path", c.Request().URL.Path,
+		"error", err,
+	)
+	return errorJSON(c, http.StatusInternalServerError, CodeInternalError, "internal server error")
+}
+
+func bindErrorResponse(c echo.Context, err error) error {
+	return errorJSON(c, http.StatusBadRequest, CodeBadRequest, err.Error())
+}
+
+func validationErrorResponse(c echo.Context, err error) error {
+	return errorResponse(c, domain.NewValidationError(err.Error()))
+}
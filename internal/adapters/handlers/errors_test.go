@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		Name            string
+		Err             error
+		ExpectedStatus  int
+		ExpectedCode    string
+		ExpectedMessage string
+	}{
+		{Name: "user not found", Err: domain.ErrUserNotFound, ExpectedStatus: http.StatusNotFound, ExpectedCode: CodeUserNotFound, ExpectedMessage: "user not found"},
+		{Name: "wrapped user not found", Err: fmt.Errorf("get user: %w", domain.ErrUserNotFound), ExpectedStatus: http.StatusNotFound, ExpectedCode: CodeUserNotFound, ExpectedMessage: "get user: user not found"},
+		{Name: "email taken", Err: domain.ErrEmailTaken, ExpectedStatus: http.StatusConflict, ExpectedCode: CodeEmailTaken, ExpectedMessage: "email already exist"},
+		{Name: "validation", Err: domain.NewValidationError("name and email cannot be empty"), ExpectedStatus: http.StatusUnprocessableEntity, ExpectedCode: CodeValidationFailed, ExpectedMessage: "name and email cannot be empty"},
+		{Name: "invalid credentials", Err: domain.ErrInvalidCredentials, ExpectedStatus: http.StatusUnauthorized, ExpectedCode: CodeInvalidCredentials, ExpectedMessage: "invalid email or password"},
+		{Name: "login throttled", Err: &domain.LoginThrottledError{RetryAfter: time.Minute}, ExpectedStatus: http.StatusTooManyRequests, ExpectedCode: CodeLoginThrottled, ExpectedMessage: "too many failed login attempts, retry later"},
+		{Name: "unknown error", Err: errors.New("connection refused"), ExpectedStatus: http.StatusInternalServerError, ExpectedCode: CodeInternalError, ExpectedMessage: "internal server error"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+			mockService.On("GetUserByID", mock.Anything, "123").Return(domain.User{}, test.Err)
+
+			req := httptest.NewRequest(http.MethodGet, "/user/123", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("123")
+
+			err := handler.GetUserByID(c)
+			assert.NoError(t, err)
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+
+			var body map[string]string
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, test.ExpectedCode, body["code"])
+			assert.Equal(t, test.ExpectedMessage, body["error"])
+		})
+	}
+}
+
+func TestErrorResponseWeakPassword(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/register", nil), rec)
+
+	err := errorResponse(c, &domain.WeakPasswordError{Violations: []domain.PasswordViolation{
+		{Rule: domain.PasswordRuleMinLength, Message: "password must be at least 8 characters"},
+		{Rule: domain.PasswordRuleBlocklist, Message: "password is too common"},
+	}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.JSONEq(t, `{
+		"error": "password does not meet the password policy",
+		"code": "weak_password",
+		"violations": [
+			{"rule": "min_length", "message": "password must be at least 8 characters"},
+			{"rule": "blocklist", "message": "password is too common"}
+		]
+	}`, rec.Body.String())
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"one1-be-chal/internal/core/ports"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	healthStatusOK           = "ok"
+	healthStatusUnavailable  = "unavailable"
+	healthStatusShuttingDown = "shutting_down"
+	checkStatusUp            = "up"
+	checkStatusDown          = "down"
+)
+
+type CheckResponse struct {
+	Status string `json:"status"`
+}
+
+type HealthResponse struct {
+	Status string                   `json:"status"`
+	Checks map[string]CheckResponse `json:"checks,omitempty"`
+}
+
+// HealthHandler serves the liveness and readiness probes. Register every
+// checker before the server starts.
+type HealthHandler struct {
+	checkers     []ports.HealthChecker
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewHealthHandler(timeout time.Duration, checkers ...ports.HealthChecker) *HealthHandler {
+	return &HealthHandler{
+		checkers: checkers,
+		timeout:  timeout,
+	}
+}
+
+func (h *HealthHandler) Register(checker ports.HealthChecker) {
+	h.checkers = append(h.checkers, checker)
+}
+
+// ShuttingDown makes readiness fail from now on, so no new traffic is routed
+// to the instance while it drains.
+func (h *HealthHandler) ShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Liveness reports that the process is up, it never checks dependencies so a
+// database outage doesn't get the instance restarted.
+func (h *HealthHandler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, HealthResponse{Status: healthStatusOK})
+}
+
+// Readiness runs every checker concurrently, each bounded by the timeout.
+func (h *HealthHandler) Readiness(c echo.Context) error {
+	if h.shuttingDown.Load() {
+		return c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: healthStatusShuttingDown})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), h.timeout)
+	defer cancel()
+
+	response := HealthResponse{Status: healthStatusOK, Checks: map[string]CheckResponse{}}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, checker := range h.checkers {
+		wg.Add(1)
+		go func(checker ports.HealthChecker) {
+			defer wg.Done()
+			status := checkStatusUp
+			if err := checker.Check(ctx); err != nil {
+				slog.WarnContext(ctx, "readiness check failed", "check", checker.Name(), "error", err)
+				status = checkStatusDown
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Checks[checker.Name()] = CheckResponse{Status: status}
+			if status == checkStatusDown {
+				response.Status = healthStatusUnavailable
+			}
+		}(checker)
+	}
+	wg.Wait()
+
+	if response.Status != healthStatusOK {
+		return c.JSON(http.StatusServiceUnavailable, response)
+	}
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChecker struct {
+	name string
+	err  error
+	// block makes Check wait for its context to end.
+	block bool
+}
+
+func (f fakeChecker) Name() string {
+	return f.name
+}
+
+func (f fakeChecker) Check(ctx context.Context) error {
+	if f.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return f.err
+}
+
+func probe(t *testing.T, handle echo.HandlerFunc) (int, HealthResponse) {
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handle(echo.New().NewContext(req, rec)))
+
+	var response HealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return rec.Code, response
+}
+
+func TestLiveness(t *testing.T) {
+	handler := NewHealthHandler(time.Second, fakeChecker{name: "mongo", err: errors.New("unreachable")})
+
+	status, response := probe(t, handler.Liveness)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", response.Status)
+}
+
+func TestReadiness(t *testing.T) {
+	handler := NewHealthHandler(time.Second, fakeChecker{name: "mongo"})
+	handler.Register(fakeChecker{name: "cache"})
+
+	status, response := probe(t, handler.Readiness)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, HealthResponse{
+		Status: "ok",
+		Checks: map[string]CheckResponse{"mongo": {Status: "up"}, "cache": {Status: "up"}},
+	}, response)
+}
+
+func TestReadinessDependencyDown(t *testing.T) {
+	handler := NewHealthHandler(time.Second,
+		fakeChecker{name: "mongo", err: errors.New("connection refused")},
+		fakeChecker{name: "cache"},
+	)
+
+	status, response := probe(t, handler.Readiness)
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unavailable", response.Status)
+	assert.Equal(t, "down", response.Checks["mongo"].Status)
+	assert.Equal(t, "up", response.Checks["cache"].Status)
+}
+
+func TestReadinessTimeout(t *testing.T) {
+	handler := NewHealthHandler(20*time.Millisecond, fakeChecker{name: "mongo", block: true})
+
+	started := time.Now()
+	status, response := probe(t, handler.Readiness)
+
+	assert.Less(t, time.Since(started), time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "down", response.Checks["mongo"].Status)
+}
+
+func TestReadinessShuttingDown(t *testing.T) {
+	handler := NewHealthHandler(time.Second, fakeChecker{name: "mongo"})
+	handler.ShuttingDown()
+
+	status, response := probe(t, handler.Readiness)
+
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "shutting_down", response.Status)
+
+	status, _ = probe(t, handler.Liveness)
+	assert.Equal(t, http.StatusOK, status)
+}
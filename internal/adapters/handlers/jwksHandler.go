@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/adapters/helpers"
+
+	"github.com/labstack/echo"
+)
+
+// JWKSHandler publishes the public keys tokens are verified with.
+func JWKSHandler(config *config.Container) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set("Cache-Control", "public, max-age=300")
+		return c.JSON(http.StatusOK, helpers.PublicJWKS(*config))
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/adapters/helpers"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	e := echo.New()
+	publicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	mockConfig := &config.Container{
+		JWT: &config.JWT{VerificationKeys: map[string]crypto.PublicKey{"key-1": publicKey}},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := JWKSHandler(mockConfig)(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var jwks helpers.JWKS
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jwks))
+	assert.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "key-1", jwks.Keys[0].Kid)
+}
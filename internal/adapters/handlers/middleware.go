@@ -1,14 +1,25 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"one1-be-chal/internal/adapters/config"
 	"one1-be-chal/internal/adapters/helpers"
+	"one1-be-chal/internal/adapters/logging"
+	"one1-be-chal/internal/adapters/metrics"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func EchoMiddleware() *echo.Echo {
@@ -38,42 +49,212 @@ func EchoMiddleware() *echo.Echo {
 			app.DefaultHTTPErrorHandler(err, context)
 		}
 	}
-	app.Use(LoggerMiddleware)
+	app.Use(RequestIDMiddleware, LoggerMiddleware)
 	return app
 }
 
-// Logging middleware that logs HTTP method, path, and execution time.
-func LoggerMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+// RequestIDMiddleware keeps the caller's X-Request-ID when it looks sane and
+// generates one otherwise. The ID is echoed back and stored in the request
+// context, so every log line of the request carries it.
+func RequestIDMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		defer func(start time.Time) {
-			log.Println(c.Request().Method, c.Request().URL.Path, time.Since(start))
-		}(time.Now())
+		requestID := c.Request().Header.Get(echo.HeaderXRequestID)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+		c.SetRequest(c.Request().WithContext(logging.WithRequestID(c.Request().Context(), requestID)))
 		return next(c)
 	}
 }
 
-func JWTMiddleware(config *config.Container) echo.MiddlewareFunc {
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// LoggerMiddleware logs the method, route, status and duration of every request.
+func LoggerMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		status := responseStatus(c, err)
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		// The request is read after next so the user ID set by JWTMiddleware is included.
+		slog.Log(c.Request().Context(), level, "request",
+			"method", c.Request().Method,
+			"path", c.Request().URL.Path,
+			"route", c.Path(),
+			"status", status,
+			"duration", time.Since(start),
+		)
+		return err
+	}
+}
+
+// responseStatus is the status of the response to c. Errors returned by the
+// handler are only written by the error handler after the middlewares return.
+func responseStatus(c echo.Context, err error) int {
+	if he, ok := err.(*echo.HTTPError); ok {
+		return he.Code
+	} else if err != nil {
+		return http.StatusInternalServerError
+	}
+	return c.Response().Status
+}
+
+// MetricsMiddleware records the count and latency of every request by route and status.
+func MetricsMiddleware(m *metrics.Metrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			// Echo reports the raw path as the route of unknown paths, which
+			// would give every probe of the server its own series.
+			route := c.Path()
+			if err == echo.ErrNotFound {
+				route = "unmatched"
+			}
+			m.ObserveRequest(c.Request().Method, route, responseStatus(c, err), time.Since(start))
+			return err
+		}
+	}
+}
+
+// TracingMiddleware starts a server span per request, continuing the trace of
+// an incoming W3C traceparent header, and makes it the parent of every span
+// the request's handler, service and repositories start.
+func TracingMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, req.Method+" "+c.Path(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.HTTPRoute(c.Path()),
+				semconv.URLPath(req.URL.Path),
+			),
+		)
+		defer span.End()
+		c.SetRequest(req.WithContext(ctx))
+
+		err := next(c)
+
+		if err == echo.ErrNotFound {
+			span.SetName(req.Method + " unmatched")
+			span.SetAttributes(semconv.HTTPRoute("unmatched"))
+		}
+		status := responseStatus(c, err)
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		return err
+	}
+}
+
+const tracerName = "one1-be-chal/internal/adapters/handlers"
+
+func JWTMiddleware(config *config.Container, revocations ports.TokenRevocationStore) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 
 		return func(c echo.Context) error {
 			auth := c.Request().Header.Get("Authorization")
 			if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-				return c.JSON(
-					http.StatusUnauthorized,
-					echo.Map{"error": "Missing or invalid token"},
-				)
+				return errorJSON(c, http.StatusUnauthorized, CodeUnauthorized, "Missing or invalid token")
 			}
 			tokenStr := strings.TrimPrefix(auth, "Bearer ")
 
 			claims, err := helpers.ParseJWT(tokenStr, *config)
 			if err != nil {
-				return c.JSON(
-					http.StatusUnauthorized,
-					echo.Map{"error": "Invalid token"},
-				)
+				return errorJSON(c, http.StatusUnauthorized, CodeUnauthorized, "Invalid token")
+			}
+
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			revoked, err := revocations.IsRevoked(c.Request().Context(), claims.TokenID(), claims.ID, issuedAt)
+			if err != nil {
+				return errorResponse(c, err)
+			}
+			if revoked {
+				return errorJSON(c, http.StatusUnauthorized, CodeTokenRevoked, "Token has been revoked")
 			}
 			c.Set("claims", claims)
+			c.SetRequest(c.Request().WithContext(logging.WithUserID(c.Request().Context(), claims.ID)))
+
+			return next(c)
+		}
+	}
+}
+
+// SelfOrAdminMiddleware only lets a user act on the account in the :id path
+// parameter when it is their own, admins may act on any account.
+// It must run after JWTMiddleware.
+func SelfOrAdminMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, ok := c.Get("claims").(*helpers.Claims)
+		if !ok {
+			return errorJSON(c, http.StatusUnauthorized, CodeUnauthorized, "Missing or invalid token")
+		}
+		if claims.ID != c.Param("id") && claims.Role != domain.RoleAdmin {
+			return errorJSON(c, http.StatusForbidden, CodeForbidden, "Forbidden")
+		}
+		return next(c)
+	}
+}
+
+// SelfMiddleware only lets a user act on the account in the :id path
+// parameter when it is their own, admins included.
+// It must run after JWTMiddleware.
+func SelfMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, ok := c.Get("claims").(*helpers.Claims)
+		if !ok {
+			return errorJSON(c, http.StatusUnauthorized, CodeUnauthorized, "Missing or invalid token")
+		}
+		if claims.ID != c.Param("id") {
+			return errorJSON(c, http.StatusForbidden, CodeForbidden, "Forbidden")
+		}
+		return next(c)
+	}
+}
 
+// AdminMiddleware only lets admins through. It must run after JWTMiddleware.
+func AdminMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, ok := c.Get("claims").(*helpers.Claims)
+		if !ok {
+			return errorJSON(c, http.StatusUnauthorized, CodeUnauthorized, "Missing or invalid token")
+		}
+		if claims.Role != domain.RoleAdmin {
+			return errorJSON(c, http.StatusForbidden, CodeForbidden, "Forbidden")
+		}
+		return next(c)
+	}
+}
+
+// VerifiedEmailMiddleware keeps users whose email isn't verified out of every
+// account but their own, when required. Tokens carry the verification state
+// they were issued with, so a user refreshes their token after verifying.
+// It must run after JWTMiddleware.
+func VerifiedEmailMiddleware(required bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !required {
+				return next(c)
+			}
+			claims, ok := c.Get("claims").(*helpers.Claims)
+			if !ok {
+				return errorJSON(c, http.StatusUnauthorized, CodeUnauthorized, "Missing or invalid token")
+			}
+			if !claims.EmailVerified && claims.ID != c.Param("id") {
+				return errorJSON(c, http.StatusForbidden, CodeEmailNotVerified, "Email is not verified")
+			}
 			return next(c)
 		}
 	}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"one1-be-chal/internal/adapters/helpers"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// RateLimitKey picks the bucket a request takes its token from.
+type RateLimitKey func(c echo.Context) string
+
+// KeyByIP keys requests by client IP.
+func KeyByIP(trustProxy bool) RateLimitKey {
+	return func(c echo.Context) string {
+		return "ip:" + clientIP(c, trustProxy)
+	}
+}
+
+// clientIP is the peer address of the request. X-Forwarded-For and X-Real-IP
+// are only believed behind a proxy that sets them, otherwise anyone could
+// pick their own IP.
+func clientIP(c echo.Context, trustProxy bool) string {
+	if trustProxy {
+		return c.RealIP()
+	}
+	host, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		return c.Request().RemoteAddr
+	}
+	return host
+}
+
+// KeyByUser keys requests by the JWT subject, so it must run after
+// JWTMiddleware. Requests without claims fall back to the fallback key.
+func KeyByUser(fallback RateLimitKey) RateLimitKey {
+	return func(c echo.Context) string {
+		if claims, ok := c.Get("claims").(*helpers.Claims); ok {
+			return "user:" + claims.ID
+		}
+		return fallback(c)
+	}
+}
+
+// RateLimitMiddleware takes a token from the bucket of the request's key under
+// name, and answers 429 once the bucket is empty. A nil limit disables it. When
+// the store fails the request is let through, an outage of the limiter
+// shouldn't take the API down with it.
+func RateLimitMiddleware(store ports.RateLimitStore, name string, limit *domain.RateLimit, key RateLimitKey) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if limit == nil {
+			return next
+		}
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			result, err := store.Take(ctx, name+":"+key(c), *limit)
+			if err != nil {
+				slog.ErrorContext(ctx, "rate limit store failed, allowing request", "limit", name, "error", err)
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set("RateLimit-Policy", strconv.Itoa(limit.Burst)+";w="+seconds(limit.Period))
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", seconds(result.Reset))
+			if !result.Allowed {
+				header.Set("Retry-After", seconds(result.RetryAfter))
+				return errorJSON(c, http.StatusTooManyRequests, CodeRateLimited, "Too many requests, retry later")
+			}
+			return next(c)
+		}
+	}
+}
+
+// seconds formats d as whole seconds, rounded up so clients never retry early.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"one1-be-chal/internal/adapters/helpers"
+	"one1-be-chal/internal/adapters/storages/memory"
+	"one1-be-chal/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	return domain.RateLimitResult{}, errors.New("connection refused")
+}
+
+func rateLimitedRequest(handler echo.HandlerFunc, remoteAddr string, claims *helpers.Claims) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/register", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set(echo.HeaderXForwardedFor, "9.9.9.9")
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	if claims != nil {
+		c.Set("claims", claims)
+	}
+	_ = handler(c)
+	return rec
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limit := &domain.RateLimit{Burst: 2, Period: time.Minute}
+	handler := RateLimitMiddleware(memory.NewRateLimitStore(), "register", limit, KeyByIP(false))(mockHandler)
+
+	rec := rateLimitedRequest(handler, "1.2.3.4:5678", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2;w=60", rec.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("RateLimit-Reset"))
+
+	rateLimitedRequest(handler, "1.2.3.4:5678", nil)
+	rec = rateLimitedRequest(handler, "1.2.3.4:9999", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"Too many requests, retry later","code":"rate_limited"}`, rec.Body.String())
+
+	rec = rateLimitedRequest(handler, "5.6.7.8:5678", nil)
+	assert.Equal(t, http.StatusOK, rec.Code, "other IPs have their own bucket, X-Forwarded-For is ignored")
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.Request().RemoteAddr = "1.2.3.4:5678"
+	c.Request().Header.Set(echo.HeaderXForwardedFor, "9.9.9.9")
+
+	assert.Equal(t, "ip:1.2.3.4", KeyByIP(false)(c))
+	assert.Equal(t, "ip:9.9.9.9", KeyByIP(true)(c))
+	assert.Equal(t, "ip:1.2.3.4", KeyByUser(KeyByIP(false))(c))
+
+	c.Set("claims", &helpers.Claims{ID: "user-1"})
+	assert.Equal(t, "user:user-1", KeyByUser(KeyByIP(false))(c))
+}
+
+func TestRateLimitMiddlewareByUser(t *testing.T) {
+	limit := &domain.RateLimit{Burst: 1, Period: time.Minute}
+	handler := RateLimitMiddleware(memory.NewRateLimitStore(), "user", limit, KeyByUser(KeyByIP(false)))(mockHandler)
+	claims := &helpers.Claims{ID: "user-1"}
+
+	assert.Equal(t, http.StatusOK, rateLimitedRequest(handler, "1.2.3.4:1", claims).Code)
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitedRequest(handler, "5.6.7.8:1", claims).Code, "the user's bucket follows them across IPs")
+	assert.Equal(t, http.StatusOK, rateLimitedRequest(handler, "1.2.3.4:1", &helpers.Claims{ID: "user-2"}).Code)
+}
+
+func TestRateLimitMiddlewareDisabledOrFailing(t *testing.T) {
+	disabled := RateLimitMiddleware(failingRateLimitStore{}, "register", nil, KeyByIP(false))(mockHandler)
+	rec := rateLimitedRequest(disabled, "1.2.3.4:1", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+
+	limit := &domain.RateLimit{Burst: 1, Period: time.Minute}
+	failing := RateLimitMiddleware(failingRateLimitStore{}, "register", limit, KeyByIP(false))(mockHandler)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, rateLimitedRequest(failing, "1.2.3.4:1", nil).Code)
+	}
+}
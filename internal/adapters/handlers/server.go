@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// Serve runs app on address until ctx is cancelled. It then calls shuttingDown,
+// if set, and keeps serving for drainDelay so readiness probes notice before
+// it stops accepting connections. In-flight requests get up to drainTimeout
+// to finish after that.
+func Serve(
+	ctx context.Context,
+	app *echo.Echo,
+	address string,
+	shuttingDown func(),
+	drainDelay, drainTimeout time.Duration,
+) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- app.Start(address)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	if shuttingDown != nil {
+		shuttingDown()
+	}
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(drainDelay):
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := app.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowServer serves GET /slow, which signals started and then takes delay to respond.
+func slowServer(t *testing.T, delay time.Duration) (*echo.Echo, string, chan struct{}) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	app := echo.New()
+	app.HideBanner = true
+	app.HidePort = true
+	app.Listener = listener
+	app.GET("/slow", func(c echo.Context) error {
+		close(started)
+		time.Sleep(delay)
+		return c.String(http.StatusOK, "done")
+	})
+	return app, "http://" + listener.Addr().String() + "/slow", started
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	app, url, started := slowServer(t, 200*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, app, "", nil, 0, time.Second)
+	}()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	response := <-responses
+	require.NoError(t, response.err)
+	assert.Equal(t, http.StatusOK, response.status)
+	assert.Equal(t, "done", response.body)
+	assert.NoError(t, <-served)
+
+	_, err := http.Get(url)
+	assert.Error(t, err)
+}
+
+func TestServeGivesUpAfterDrainTimeout(t *testing.T) {
+	app, url, started := slowServer(t, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, app, "", nil, 0, 50*time.Millisecond)
+	}()
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	assert.ErrorIs(t, <-served, context.DeadlineExceeded)
+}
+
+func TestServeReturnsStartErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	app := echo.New()
+	app.HideBanner = true
+	app.HidePort = true
+
+	assert.Error(t, Serve(context.Background(), app, listener.Addr().String(), nil, 0, time.Second))
+}
+
+func TestServeFailsReadinessDuringDrainDelay(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	app := echo.New()
+	app.HideBanner = true
+	app.HidePort = true
+	app.Listener = listener
+	health := NewHealthHandler(time.Second)
+	app.GET("/readyz", health.Readiness)
+	url := "http://" + listener.Addr().String() + "/readyz"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, app, "", health.ShuttingDown, 300*time.Millisecond, time.Second)
+	}()
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode == http.StatusServiceUnavailable && strings.Contains(string(body), healthStatusShuttingDown)
+	}, 200*time.Millisecond, 10*time.Millisecond, "probes see 503 while the server still accepts connections")
+
+	assert.NoError(t, <-served)
+	_, err = http.Get(url)
+	assert.Error(t, err, "the server stops after the delay")
+}
@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"context"
 	"net/http"
 	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/adapters/helpers"
 	"one1-be-chal/internal/core/domain"
 	"one1-be-chal/internal/core/ports"
 
@@ -25,90 +25,290 @@ func NewHttpUserHandler(service ports.UserService, config *config.Container) *Ht
 func (u *HttpUserHandler) Register(c echo.Context) error {
 	var user domain.User
 	if err := c.Bind(&user); err != nil {
-		return c.JSON(
-			echo.ErrBadRequest.Code,
-			echo.Map{"error": err.Error()},
-		)
+		return bindErrorResponse(c, err)
 	}
 
 	if err := c.Validate(user); err != nil {
-		return c.JSON(
-			http.StatusBadRequest,
-			echo.Map{"error": err.Error()},
-		)
+		return validationErrorResponse(c, err)
 	}
 
-	jwt, err := u.service.Register(context.Background(), user, *u.config)
+	tokens, err := u.service.Register(c.Request().Context(), user, *u.config)
 	if err != nil {
-		return c.JSON(
-			echo.ErrInternalServerError.Code,
-			echo.Map{"error": err.Error()},
-		)
+		return errorResponse(c, err)
 	}
 
-	return c.JSON(
-		http.StatusOK,
-		echo.Map{"jwToken": jwt},
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (u *HttpUserHandler) Login(c echo.Context) error {
+	var credentials domain.LoginUser
+	if err := c.Bind(&credentials); err != nil {
+		return bindErrorResponse(c, err)
+	}
+
+	if err := c.Validate(credentials); err != nil {
+		return validationErrorResponse(c, err)
+	}
+
+	tokens, err := u.service.Login(
+		c.Request().Context(),
+		credentials.Email,
+		credentials.Password,
+		clientIP(c, u.config.Server.TrustProxy),
+		*u.config,
 	)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// LoginTwoFactor finishes a login that was answered with an mfaToken.
+func (u *HttpUserHandler) LoginTwoFactor(c echo.Context) error {
+	var request domain.LoginTwoFactorRequest
+	if err := c.Bind(&request); err != nil {
+		return bindErrorResponse(c, err)
+	}
+
+	if err := c.Validate(request); err != nil {
+		return validationErrorResponse(c, err)
+	}
+
+	tokens, err := u.service.LoginTwoFactor(
+		c.Request().Context(),
+		request.MFAToken,
+		request.Code,
+		clientIP(c, u.config.Server.TrustProxy),
+		*u.config,
+	)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (u *HttpUserHandler) RefreshToken(c echo.Context) error {
+	var request domain.RefreshTokenRequest
+	if err := c.Bind(&request); err != nil {
+		return bindErrorResponse(c, err)
+	}
+
+	if err := c.Validate(request); err != nil {
+		return validationErrorResponse(c, err)
+	}
+
+	tokens, err := u.service.RefreshToken(c.Request().Context(), request.RefreshToken, *u.config)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// ForgotPassword answers the same whether the email is registered or not.
+func (u *HttpUserHandler) ForgotPassword(c echo.Context) error {
+	var request domain.ForgotPasswordRequest
+	if err := c.Bind(&request); err != nil {
+		return bindErrorResponse(c, err)
+	}
+
+	if err := c.Validate(request); err != nil {
+		return validationErrorResponse(c, err)
+	}
+
+	if err := u.service.ForgotPassword(c.Request().Context(), request.Email, *u.config); err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusAccepted, echo.Map{"message": "If the email is registered, a password reset token has been sent"})
+}
+
+func (u *HttpUserHandler) ResetPassword(c echo.Context) error {
+	var request domain.ResetPasswordRequest
+	if err := c.Bind(&request); err != nil {
+		return bindErrorResponse(c, err)
+	}
+
+	if err := c.Validate(request); err != nil {
+		return validationErrorResponse(c, err)
+	}
+
+	if err := u.service.ResetPassword(c.Request().Context(), request.Token, request.Password, *u.config); err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "Password reset successfully"})
+}
+
+// ChangePassword answers with a new token pair, the tokens the user had
+// before stop working.
+func (u *HttpUserHandler) ChangePassword(c echo.Context) error {
+	id := c.Param("id")
+	var request domain.ChangePasswordRequest
+	if err := c.Bind(&request); err != nil {
+		return bindErrorResponse(c, err)
+	}
+
+	if err := c.Validate(request); err != nil {
+		return validationErrorResponse(c, err)
+	}
+
+	tokens, err := u.service.ChangePassword(c.Request().Context(), id, request.CurrentPassword, request.NewPassword, *u.config)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// VerifyEmail takes the token from the token query parameter on GET, so the
+// link in the message works on its own, and from the JSON body on POST.
+func (u *HttpUserHandler) VerifyEmail(c echo.Context) error {
+	var request domain.VerifyEmailRequest
+	if err := c.Bind(&request); err != nil {
+		return bindErrorResponse(c, err)
+	}
+
+	if err := c.Validate(request); err != nil {
+		return validationErrorResponse(c, err)
+	}
+
+	if err := u.service.VerifyEmail(c.Request().Context(), request.Token); err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "Email verified successfully"})
+}
+
+// ResendVerification sends the authenticated user a new verification token.
+func (u *HttpUserHandler) ResendVerification(c echo.Context) error {
+	claims := c.Get("claims").(*helpers.Claims)
+	if err := u.service.ResendVerification(c.Request().Context(), claims.ID, *u.config); err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusAccepted, echo.Map{"message": "A verification token has been sent"})
+}
+
+// EnrollTwoFactor answers with the secret and otpauth:// URI to add to an
+// authenticator app, ConfirmTwoFactor turns it on.
+func (u *HttpUserHandler) EnrollTwoFactor(c echo.Context) error {
+	enrollment, err := u.service.EnrollTwoFactor(c.Request().Context(), c.Param("id"), *u.config)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactor answers with the recovery codes, they are never shown again.
+func (u *HttpUserHandler) ConfirmTwoFactor(c echo.Context) error {
+	id := c.Param("id")
+	var request domain.ConfirmTwoFactorRequest
+	if err := c.Bind(&request); err != nil {
+		return bindErrorResponse(c, err)
+	}
+
+	if err := c.Validate(request); err != nil {
+		return validationErrorResponse(c, err)
+	}
+
+	recoveryCodes, err := u.service.ConfirmTwoFactor(c.Request().Context(), id, request.Password, request.Code, *u.config)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"recovery_codes": recoveryCodes})
+}
+
+func (u *HttpUserHandler) DisableTwoFactor(c echo.Context) error {
+	id := c.Param("id")
+	var request domain.DisableTwoFactorRequest
+	if err := c.Bind(&request); err != nil {
+		return bindErrorResponse(c, err)
+	}
+
+	if err := c.Validate(request); err != nil {
+		return validationErrorResponse(c, err)
+	}
+
+	if err := u.service.DisableTwoFactor(c.Request().Context(), id, request.Password, request.Code, *u.config); err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "Two-factor authentication disabled"})
+}
+
+func (u *HttpUserHandler) Logout(c echo.Context) error {
+	claims := c.Get("claims").(*helpers.Claims)
+
+	var request domain.LogoutRequest
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&request); err != nil {
+			return bindErrorResponse(c, err)
+		}
+	}
+
+	err := u.service.Logout(
+		c.Request().Context(),
+		claims.ID,
+		claims.TokenID(),
+		claims.ExpiresAt.Time,
+		request.RefreshToken,
+	)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "Logged out successfully"})
 }
 
 func (u *HttpUserHandler) GetUserByID(c echo.Context) error {
 	id := c.Param("id")
-	user, err := u.service.GetUserByID(context.Background(), id)
+	user, err := u.service.GetUserByID(c.Request().Context(), id)
 	if err != nil {
-		return c.JSON(
-			echo.ErrInternalServerError.Code,
-			echo.Map{"error": err.Error()},
-		)
+		return errorResponse(c, err)
 	}
-	return c.JSON(http.StatusOK, user)
+	return c.JSON(http.StatusOK, NewUserResponse(user))
 }
 
 func (u *HttpUserHandler) GetAllUsers(c echo.Context) error {
-	users, err := u.service.GetAllUsers(context.Background())
+	query, err := parseUserQuery(c)
+	if err != nil {
+		return errorResponse(c, err)
+	}
+
+	page, err := u.service.GetAllUsers(c.Request().Context(), query)
 	if err != nil {
-		return c.JSON(
-			echo.ErrInternalServerError.Code,
-			echo.Map{"error": err.Error()},
-		)
+		return errorResponse(c, err)
 	}
 
-	return c.JSON(http.StatusOK, users)
+	return c.JSON(http.StatusOK, NewUserPageResponse(page))
 }
 
 func (u *HttpUserHandler) UpdateUser(c echo.Context) error {
 	id := c.Param("id")
 	var user domain.EditUser
 	if err := c.Bind(&user); err != nil {
-		return c.JSON(
-			echo.ErrBadRequest.Code,
-			echo.Map{"error": err.Error()},
-		)
+		return bindErrorResponse(c, err)
 	}
 
 	if err := c.Validate(user); err != nil {
-		return c.JSON(
-			http.StatusBadRequest,
-			echo.Map{"error": err.Error()},
-		)
+		return validationErrorResponse(c, err)
 	}
 
-	if err := u.service.UpdateUser(context.Background(), id, domain.User{Email: user.Email, Name: user.Name}); err != nil {
-		return c.JSON(
-			echo.ErrInternalServerError.Code,
-			echo.Map{"error": err.Error()},
-		)
+	if err := u.service.UpdateUser(c.Request().Context(), id, domain.User{Email: user.Email, Name: user.Name}, *u.config); err != nil {
+		return errorResponse(c, err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{"message": "User updated successfully"})
 }
 
 func (u *HttpUserHandler) DeleteUser(c echo.Context) error {
 	id := c.Param("id")
-	if err := u.service.DeleteUser(context.Background(), id); err != nil {
-		return c.JSON(
-			echo.ErrInternalServerError.Code,
-			echo.Map{"error": err.Error()},
-		)
+	if err := u.service.DeleteUser(c.Request().Context(), id); err != nil {
+		return errorResponse(c, err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{"message": "User deleted successfully"})
 }
+
+// UnlockUser lifts the login lockout of the user in the :id path parameter.
+func (u *HttpUserHandler) UnlockUser(c echo.Context) error {
+	id := c.Param("id")
+	if err := u.service.UnlockUser(c.Request().Context(), id); err != nil {
+		return errorResponse(c, err)
+	}
+	return c.JSON(http.StatusOK, echo.Map{"message": "User unlocked successfully"})
+}
@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/adapters/helpers"
 	"one1-be-chal/internal/core/domain"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -19,9 +23,28 @@ type MockUserService struct {
 	mock.Mock
 }
 
-func (m *MockUserService) Register(ctx context.Context, user domain.User, config config.Container) (string, error) {
+func (m *MockUserService) Register(ctx context.Context, user domain.User, config config.Container) (domain.AuthTokens, error) {
 	args := m.Called(ctx, user, config)
-	return args.String(0), args.Error(1)
+	return args.Get(0).(domain.AuthTokens), args.Error(1)
+}
+
+func (m *MockUserService) Login(ctx context.Context, email, password, clientIP string, config config.Container) (domain.AuthTokens, error) {
+	args := m.Called(ctx, email, password, clientIP, config)
+	return args.Get(0).(domain.AuthTokens), args.Error(1)
+}
+
+func (m *MockUserService) LoginTwoFactor(ctx context.Context, mfaToken, code, clientIP string, config config.Container) (domain.AuthTokens, error) {
+	args := m.Called(ctx, mfaToken, code, clientIP, config)
+	return args.Get(0).(domain.AuthTokens), args.Error(1)
+}
+
+func (m *MockUserService) RefreshToken(ctx context.Context, refreshToken string, config config.Container) (domain.AuthTokens, error) {
+	args := m.Called(ctx, refreshToken, config)
+	return args.Get(0).(domain.AuthTokens), args.Error(1)
+}
+
+func (m *MockUserService) Logout(ctx context.Context, userID, tokenID string, expiresAt time.Time, refreshToken string) error {
+	return m.Called(ctx, userID, tokenID, expiresAt, refreshToken).Error(0)
 }
 
 func (m *MockUserService) GetUserByID(ctx context.Context, id string) (domain.User, error) {
@@ -29,20 +52,57 @@ func (m *MockUserService) GetUserByID(ctx context.Context, id string) (domain.Us
 	return args.Get(0).(domain.User), args.Error(1)
 }
 
-func (m *MockUserService) GetAllUsers(ctx context.Context) ([]domain.User, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]domain.User), args.Error(1)
+func (m *MockUserService) GetAllUsers(ctx context.Context, query domain.UserQuery) (domain.UserPage, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(domain.UserPage), args.Error(1)
 }
 
-func (m *MockUserService) UpdateUser(ctx context.Context, id string, user domain.User) error {
-	return m.Called(ctx, id, user).Error(0)
+func (m *MockUserService) UpdateUser(ctx context.Context, id string, user domain.User, config config.Container) error {
+	return m.Called(ctx, id, user, config).Error(0)
 }
 
 func (m *MockUserService) DeleteUser(ctx context.Context, id string) error {
 	return m.Called(ctx, id).Error(0)
 }
-func (m *MockUserService) LogTotalUser(ctx context.Context) {
-	m.Called(ctx)
+
+func (m *MockUserService) UnlockUser(ctx context.Context, id string) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+func (m *MockUserService) ForgotPassword(ctx context.Context, email string, config config.Container) error {
+	return m.Called(ctx, email, config).Error(0)
+}
+
+func (m *MockUserService) ResetPassword(ctx context.Context, token, password string, config config.Container) error {
+	return m.Called(ctx, token, password, config).Error(0)
+}
+
+func (m *MockUserService) ChangePassword(ctx context.Context, id, currentPassword, newPassword string, config config.Container) (domain.AuthTokens, error) {
+	args := m.Called(ctx, id, currentPassword, newPassword, config)
+	return args.Get(0).(domain.AuthTokens), args.Error(1)
+}
+
+func (m *MockUserService) VerifyEmail(ctx context.Context, token string) error {
+	return m.Called(ctx, token).Error(0)
+}
+
+func (m *MockUserService) ResendVerification(ctx context.Context, id string, config config.Container) error {
+	return m.Called(ctx, id, config).Error(0)
+}
+
+func (m *MockUserService) EnrollTwoFactor(ctx context.Context, id string, config config.Container) (domain.TwoFactorEnrollment, error) {
+	args := m.Called(ctx, id, config)
+	return args.Get(0).(domain.TwoFactorEnrollment), args.Error(1)
+}
+
+func (m *MockUserService) ConfirmTwoFactor(ctx context.Context, id, password, code string, config config.Container) ([]string, error) {
+	args := m.Called(ctx, id, password, code, config)
+	codes, _ := args.Get(0).([]string)
+	return codes, args.Error(1)
+}
+
+func (m *MockUserService) DisableTwoFactor(ctx context.Context, id, password, code string, config config.Container) error {
+	return m.Called(ctx, id, password, code, config).Error(0)
 }
 
 func TestRegisterUser(t *testing.T) {
@@ -62,7 +122,7 @@ func TestRegisterUser(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	mockService.On("Register", mock.Anything, mock.Anything, mock.Anything).Return("token", nil)
+	mockService.On("Register", mock.Anything, mock.Anything, mock.Anything).Return(domain.AuthTokens{AccessToken: "token", RefreshToken: "refresh"}, nil)
 
 	err := handler.Register(c)
 	assert.NoError(t, err)
@@ -85,6 +145,220 @@ func TestRegisterUserBadRequest(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Body           string
+		ServiceError   error
+		ExpectedStatus int
+	}{
+		{
+			Name:           "valid credentials",
+			Body:           `{"email": "test@gmail.com", "password": "123456Test!"}`,
+			ServiceError:   nil,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "invalid credentials",
+			Body:           `{"email": "test@gmail.com", "password": "wrong"}`,
+			ServiceError:   domain.ErrInvalidCredentials,
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "too many failed attempts",
+			Body:           `{"email": "test@gmail.com", "password": "wrong"}`,
+			ServiceError:   &domain.LoginThrottledError{RetryAfter: 1500 * time.Millisecond},
+			ExpectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			Name:           "missing password",
+			Body:           `{"email": "test@gmail.com"}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			mockService := new(MockUserService)
+			mockConfig := &config.Container{JWT: &config.JWT{SecretKey: []byte("secret")}, Server: &config.Server{}}
+			handler := NewHttpUserHandler(mockService, mockConfig)
+
+			req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "1.2.3.4:5678"
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			tokens := domain.AuthTokens{AccessToken: "token", RefreshToken: "refresh"}
+			if test.ServiceError != nil {
+				tokens = domain.AuthTokens{}
+			}
+			mockService.On("Login", mock.Anything, mock.Anything, mock.Anything, "1.2.3.4", mock.Anything).Return(tokens, test.ServiceError)
+
+			err := handler.Login(c)
+			assert.NoError(t, err)
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+			if test.ExpectedStatus == http.StatusTooManyRequests {
+				assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+				assert.Contains(t, rec.Body.String(), CodeLoginThrottled)
+			}
+		})
+	}
+}
+
+func TestLoginNeedsTwoFactor(t *testing.T) {
+	e := echo.New()
+	e.Validator = NewRequestValidator()
+	mockService := new(MockUserService)
+	handler := NewHttpUserHandler(mockService, &config.Container{Server: &config.Server{}})
+	mockService.On("Login", mock.Anything, "test@gmail.com", "passwordkrub", mock.Anything, mock.Anything).
+		Return(domain.AuthTokens{MFAToken: "pending"}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email": "test@gmail.com", "password": "passwordkrub"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	assert.NoError(t, handler.Login(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"mfaToken": "pending"}`, rec.Body.String())
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Body           string
+		ServiceError   error
+		ExpectedStatus int
+		ExpectedCode   string
+	}{
+		{Name: "valid code", Body: `{"mfaToken": "pending", "code": "123456"}`, ExpectedStatus: http.StatusOK},
+		{Name: "wrong code", Body: `{"mfaToken": "pending", "code": "123456"}`, ServiceError: domain.ErrInvalidTwoFactorCode, ExpectedStatus: http.StatusForbidden, ExpectedCode: CodeInvalidTwoFactor},
+		{Name: "expired mfa token", Body: `{"mfaToken": "pending", "code": "123456"}`, ServiceError: domain.ErrInvalidMFAToken, ExpectedStatus: http.StatusUnauthorized, ExpectedCode: CodeInvalidMFAToken},
+		{Name: "too many failed attempts", Body: `{"mfaToken": "pending", "code": "123456"}`, ServiceError: &domain.LoginThrottledError{RetryAfter: time.Minute}, ExpectedStatus: http.StatusTooManyRequests, ExpectedCode: CodeLoginThrottled},
+		{Name: "missing code", Body: `{"mfaToken": "pending"}`, ExpectedStatus: http.StatusUnprocessableEntity, ExpectedCode: CodeValidationFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{Server: &config.Server{}})
+			mockService.On("LoginTwoFactor", mock.Anything, "pending", "123456", "1.2.3.4", mock.Anything).
+				Return(domain.AuthTokens{AccessToken: "access", RefreshToken: "refresh"}, test.ServiceError)
+
+			req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "1.2.3.4:5678"
+			rec := httptest.NewRecorder()
+
+			assert.NoError(t, handler.LoginTwoFactor(e.NewContext(req, rec)))
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+			if test.ExpectedCode != "" {
+				assert.Contains(t, rec.Body.String(), `"code":"`+test.ExpectedCode+`"`)
+			} else {
+				assert.JSONEq(t, `{"jwToken": "access", "refreshToken": "refresh"}`, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Body           string
+		ServiceError   error
+		ExpectedStatus int
+	}{
+		{
+			Name:           "valid refresh token",
+			Body:           `{"refreshToken": "refresh"}`,
+			ServiceError:   nil,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "invalid refresh token",
+			Body:           `{"refreshToken": "unknown"}`,
+			ServiceError:   domain.ErrInvalidRefreshToken,
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "reused refresh token",
+			Body:           `{"refreshToken": "rotated"}`,
+			ServiceError:   domain.ErrRefreshTokenReused,
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		{
+			Name:           "missing refresh token",
+			Body:           `{}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+
+			req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			mockService.On("RefreshToken", mock.Anything, mock.Anything, mock.Anything).Return(domain.AuthTokens{}, test.ServiceError)
+
+			err := handler.RefreshToken(c)
+			assert.NoError(t, err)
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	claims := &helpers.Claims{
+		ID: "123",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti-1",
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	tests := []struct {
+		Name         string
+		Body         string
+		RefreshToken string
+	}{
+		{Name: "without body", Body: "", RefreshToken: ""},
+		{Name: "with refresh token", Body: `{"refreshToken": "refresh"}`, RefreshToken: "refresh"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+
+			req := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.Set("claims", claims)
+
+			mockService.On("Logout", mock.Anything, "123", "jti-1", expiresAt, test.RefreshToken).Return(nil)
+
+			err := handler.Logout(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestGetUserByID(t *testing.T) {
 	e := echo.New()
 	mockService := new(MockUserService)
@@ -105,6 +379,164 @@ func TestGetUserByID(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 }
 
+func TestHandlersUseRequestContext(t *testing.T) {
+	e := echo.New()
+	mockService := new(MockUserService)
+	handler := NewHttpUserHandler(mockService, &config.Container{})
+
+	type contextKey struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "request"))
+	cancel()
+	fromRequest := mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Value(contextKey{}) == "request" && ctx.Err() == context.Canceled
+	})
+	mockService.On("GetUserByID", fromRequest, "123").Return(domain.User{}, context.Canceled)
+
+	req := httptest.NewRequest(http.MethodGet, "/user/123", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("123")
+
+	assert.NoError(t, handler.GetUserByID(c))
+	mockService.AssertExpectations(t)
+}
+
+func TestGetAllUsersEmpty(t *testing.T) {
+	e := echo.New()
+	mockService := new(MockUserService)
+	handler := NewHttpUserHandler(mockService, &config.Container{})
+	mockService.On("GetAllUsers", mock.Anything, mock.Anything).Return(domain.UserPage{Limit: 20}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := handler.GetAllUsers(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data": [], "paging": {"limit": 20, "has_more": false}}`, rec.Body.String())
+}
+
+func TestGetAllUsersQuery(t *testing.T) {
+	cursor := domain.UserCursor{SortBy: domain.SortByName, Name: "one1", ID: "123"}.Encode()
+
+	tests := []struct {
+		Name           string
+		Query          string
+		ExpectedStatus int
+		ExpectedQuery  domain.UserQuery
+	}{
+		{
+			Name:           "no parameters",
+			Query:          "",
+			ExpectedStatus: http.StatusOK,
+			ExpectedQuery:  domain.UserQuery{},
+		},
+		{
+			Name:           "every parameter",
+			Query:          "?limit=5&sort=name&order=desc&name=on&email_domain=gmail.com&created_from=2025-06-01T00:00:00Z&created_to=2025-07-01T00:00:00Z",
+			ExpectedStatus: http.StatusOK,
+			ExpectedQuery: domain.UserQuery{
+				Limit:       5,
+				SortBy:      domain.SortByName,
+				Descending:  true,
+				NamePrefix:  "on",
+				EmailDomain: "gmail.com",
+				CreatedFrom: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+				CreatedTo:   time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			Name:           "cursor",
+			Query:          "?cursor=" + cursor,
+			ExpectedStatus: http.StatusOK,
+			ExpectedQuery:  domain.UserQuery{After: &domain.UserCursor{SortBy: domain.SortByName, Name: "one1", ID: "123"}},
+		},
+		{Name: "invalid limit", Query: "?limit=ten", ExpectedStatus: http.StatusUnprocessableEntity},
+		{Name: "invalid order", Query: "?order=up", ExpectedStatus: http.StatusUnprocessableEntity},
+		{Name: "invalid date", Query: "?created_from=yesterday", ExpectedStatus: http.StatusUnprocessableEntity},
+		{Name: "invalid cursor", Query: "?cursor=garbage", ExpectedStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+			mockService.On("GetAllUsers", mock.Anything, mock.Anything).Return(domain.UserPage{}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/user"+test.Query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := handler.GetAllUsers(c)
+			assert.NoError(t, err)
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+			if test.ExpectedStatus == http.StatusOK {
+				mockService.AssertCalled(t, "GetAllUsers", mock.Anything, test.ExpectedQuery)
+			} else {
+				mockService.AssertNotCalled(t, "GetAllUsers", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+// Responses must never expose credentials, whatever the service returns.
+func TestUserEndpointsNeverExposePassword(t *testing.T) {
+	hashedPassword := "$2a$10$Rlx6CFM57Oq.5woHDqow6.i96LK6Cm86NIobkh.RSskXGKtiM92g2"
+	user := domain.User{
+		ID:        "123",
+		Name:      "One1 Yean",
+		Email:     "test@gmail.com",
+		Password:  hashedPassword,
+		Role:      domain.RoleUser,
+		CreatedAt: time.Now(),
+	}
+	tokens := domain.AuthTokens{AccessToken: "token", RefreshToken: "refresh"}
+
+	mockService := new(MockUserService)
+	mockService.On("Register", mock.Anything, mock.Anything, mock.Anything).Return(tokens, nil)
+	mockService.On("Login", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tokens, nil)
+	mockService.On("RefreshToken", mock.Anything, mock.Anything, mock.Anything).Return(tokens, nil)
+	mockService.On("GetUserByID", mock.Anything, "123").Return(user, nil)
+	mockService.On("GetAllUsers", mock.Anything, mock.Anything).Return(domain.UserPage{Users: []domain.User{user, user}, Limit: 20}, nil)
+	handler := NewHttpUserHandler(mockService, &config.Container{Server: &config.Server{}})
+
+	tests := []struct {
+		Name    string
+		Method  string
+		Path    string
+		Body    string
+		Handler echo.HandlerFunc
+	}{
+		{Name: "register", Method: http.MethodPost, Path: "/register", Body: `{"name": "One1", "email": "test@gmail.com", "password": "123456Test!"}`, Handler: handler.Register},
+		{Name: "login", Method: http.MethodPost, Path: "/login", Body: `{"email": "test@gmail.com", "password": "123456Test!"}`, Handler: handler.Login},
+		{Name: "refresh token", Method: http.MethodPost, Path: "/token/refresh", Body: `{"refreshToken": "refresh"}`, Handler: handler.RefreshToken},
+		{Name: "get user by id", Method: http.MethodGet, Path: "/user/123", Handler: handler.GetUserByID},
+		{Name: "get all users", Method: http.MethodGet, Path: "/user", Handler: handler.GetAllUsers},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			req := httptest.NewRequest(test.Method, test.Path, strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("123")
+
+			err := test.Handler(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.NotContains(t, rec.Body.String(), "password")
+			assert.NotContains(t, rec.Body.String(), hashedPassword)
+		})
+	}
+}
+
 func TestUpdateUser(t *testing.T) {
 	e := echo.New()
 	e.Validator = NewRequestValidator()
@@ -120,7 +552,7 @@ func TestUpdateUser(t *testing.T) {
 	c.SetParamNames("id")
 	c.SetParamValues("123")
 
-	mockService.On("UpdateUser", mock.Anything, "123", mock.Anything).Return(nil)
+	mockService.On("UpdateUser", mock.Anything, "123", mock.Anything, mock.Anything).Return(nil)
 
 	err := handler.UpdateUser(c)
 	assert.NoError(t, err)
@@ -145,3 +577,294 @@ func TestDeleteUser(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
 }
+
+func TestUnlockUser(t *testing.T) {
+	e := echo.New()
+	mockService := new(MockUserService)
+	handler := NewHttpUserHandler(mockService, &config.Container{})
+
+	mockService.On("UnlockUser", mock.Anything, "123").Return(nil)
+	mockService.On("UnlockUser", mock.Anything, "456").Return(domain.ErrUserNotFound)
+
+	for id, status := range map[string]int{"123": http.StatusOK, "456": http.StatusNotFound} {
+		req := httptest.NewRequest(http.MethodPost, "/user/"+id+"/unlock", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+
+		assert.NoError(t, handler.UnlockUser(c))
+		assert.Equal(t, status, rec.Code, id)
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Body           string
+		ServiceError   error
+		ExpectedStatus int
+	}{
+		{Name: "known or unknown email", Body: `{"email": "test@gmail.com"}`, ExpectedStatus: http.StatusAccepted},
+		{Name: "invalid email", Body: `{"email": "test"}`, ExpectedStatus: http.StatusUnprocessableEntity},
+		{Name: "notifier down", Body: `{"email": "test@gmail.com"}`, ServiceError: errors.New("smtp: connection refused"), ExpectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+			mockService.On("ForgotPassword", mock.Anything, "test@gmail.com", mock.Anything).Return(test.ServiceError)
+
+			req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			assert.NoError(t, handler.ForgotPassword(e.NewContext(req, rec)))
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Body           string
+		ServiceError   error
+		ExpectedStatus int
+		ExpectedCode   string
+	}{
+		{Name: "valid token", Body: `{"token": "abc", "password": "newpassword"}`, ExpectedStatus: http.StatusOK},
+		{Name: "used or expired token", Body: `{"token": "abc", "password": "newpassword"}`, ServiceError: domain.ErrInvalidPasswordResetToken, ExpectedStatus: http.StatusBadRequest, ExpectedCode: CodeInvalidResetToken},
+		{Name: "missing password", Body: `{"token": "abc"}`, ExpectedStatus: http.StatusUnprocessableEntity, ExpectedCode: CodeValidationFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+			mockService.On("ResetPassword", mock.Anything, "abc", "newpassword", mock.Anything).Return(test.ServiceError)
+
+			req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			assert.NoError(t, handler.ResetPassword(e.NewContext(req, rec)))
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+			if test.ExpectedCode != "" {
+				assert.Contains(t, rec.Body.String(), `"code":"`+test.ExpectedCode+`"`)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Body           string
+		ServiceError   error
+		ExpectedStatus int
+		ExpectedCode   string
+	}{
+		{Name: "valid change", Body: `{"current_password": "passwordkrub", "new_password": "newpasswordkrub"}`, ExpectedStatus: http.StatusOK},
+		{Name: "wrong current password", Body: `{"current_password": "passwordkrub", "new_password": "newpasswordkrub"}`, ServiceError: domain.ErrIncorrectPassword, ExpectedStatus: http.StatusForbidden, ExpectedCode: CodeIncorrectPassword},
+		{Name: "weak new password", Body: `{"current_password": "passwordkrub", "new_password": "newpasswordkrub"}`, ServiceError: &domain.WeakPasswordError{}, ExpectedStatus: http.StatusUnprocessableEntity, ExpectedCode: CodeWeakPassword},
+		{Name: "missing current password", Body: `{"new_password": "newpasswordkrub"}`, ExpectedStatus: http.StatusUnprocessableEntity, ExpectedCode: CodeValidationFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+			mockService.On("ChangePassword", mock.Anything, "123", "passwordkrub", "newpasswordkrub", mock.Anything).
+				Return(domain.AuthTokens{AccessToken: "access", RefreshToken: "refresh"}, test.ServiceError)
+
+			req := httptest.NewRequest(http.MethodPut, "/user/123/password", strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("123")
+
+			assert.NoError(t, handler.ChangePassword(c))
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+			if test.ExpectedCode != "" {
+				assert.Contains(t, rec.Body.String(), `"code":"`+test.ExpectedCode+`"`)
+			} else {
+				assert.JSONEq(t, `{"jwToken": "access", "refreshToken": "refresh"}`, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Method         string
+		Target         string
+		Body           string
+		ServiceError   error
+		ExpectedStatus int
+		ExpectedCode   string
+	}{
+		{Name: "link", Method: http.MethodGet, Target: "/verify-email?token=abc", ExpectedStatus: http.StatusOK},
+		{Name: "json body", Method: http.MethodPost, Target: "/verify-email", Body: `{"token": "abc"}`, ExpectedStatus: http.StatusOK},
+		{Name: "used or expired token", Method: http.MethodGet, Target: "/verify-email?token=abc", ServiceError: domain.ErrInvalidEmailVerificationToken, ExpectedStatus: http.StatusBadRequest, ExpectedCode: CodeInvalidVerifyToken},
+		{Name: "missing token", Method: http.MethodGet, Target: "/verify-email", ExpectedStatus: http.StatusUnprocessableEntity, ExpectedCode: CodeValidationFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+			mockService.On("VerifyEmail", mock.Anything, "abc").Return(test.ServiceError)
+
+			req := httptest.NewRequest(test.Method, test.Target, strings.NewReader(test.Body))
+			if test.Body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rec := httptest.NewRecorder()
+
+			assert.NoError(t, handler.VerifyEmail(e.NewContext(req, rec)))
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+			if test.ExpectedCode != "" {
+				assert.Contains(t, rec.Body.String(), `"code":"`+test.ExpectedCode+`"`)
+			}
+		})
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	e := echo.New()
+	mockService := new(MockUserService)
+	handler := NewHttpUserHandler(mockService, &config.Container{})
+
+	mockService.On("ResendVerification", mock.Anything, "123", mock.Anything).Return(nil)
+	mockService.On("ResendVerification", mock.Anything, "456", mock.Anything).Return(domain.ErrEmailAlreadyVerified)
+
+	for id, status := range map[string]int{"123": http.StatusAccepted, "456": http.StatusConflict} {
+		req := httptest.NewRequest(http.MethodPost, "/verify-email/resend", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("claims", &helpers.Claims{ID: id})
+
+		assert.NoError(t, handler.ResendVerification(c))
+		assert.Equal(t, status, rec.Code, id)
+	}
+}
+
+func TestEnrollTwoFactor(t *testing.T) {
+	e := echo.New()
+	mockService := new(MockUserService)
+	handler := NewHttpUserHandler(mockService, &config.Container{})
+	mockService.On("EnrollTwoFactor", mock.Anything, "123", mock.Anything).
+		Return(domain.TwoFactorEnrollment{Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/x"}, nil)
+	mockService.On("EnrollTwoFactor", mock.Anything, "456", mock.Anything).
+		Return(domain.TwoFactorEnrollment{}, domain.ErrTwoFactorAlreadyEnabled)
+
+	req := httptest.NewRequest(http.MethodPost, "/user/123/2fa", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("123")
+	assert.NoError(t, handler.EnrollTwoFactor(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"secret": "JBSWY3DPEHPK3PXP", "otpauth_uri": "otpauth://totp/x"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodPost, "/user/456/2fa", nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues("456")
+	assert.NoError(t, handler.EnrollTwoFactor(c))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), CodeTwoFactorEnabled)
+}
+
+func TestConfirmTwoFactor(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Body           string
+		ServiceError   error
+		ExpectedStatus int
+		ExpectedCode   string
+	}{
+		{Name: "valid password and code", Body: `{"password": "passwordkrub", "code": "123456"}`, ExpectedStatus: http.StatusOK},
+		{Name: "wrong password", Body: `{"password": "passwordkrub", "code": "123456"}`, ServiceError: domain.ErrIncorrectPassword, ExpectedStatus: http.StatusForbidden, ExpectedCode: CodeIncorrectPassword},
+		{Name: "wrong code", Body: `{"password": "passwordkrub", "code": "123456"}`, ServiceError: domain.ErrInvalidTwoFactorCode, ExpectedStatus: http.StatusForbidden, ExpectedCode: CodeInvalidTwoFactor},
+		{Name: "not enrolled", Body: `{"password": "passwordkrub", "code": "123456"}`, ServiceError: domain.ErrTwoFactorNotEnabled, ExpectedStatus: http.StatusConflict, ExpectedCode: CodeTwoFactorNotEnabled},
+		{Name: "missing password", Body: `{"code": "123456"}`, ExpectedStatus: http.StatusUnprocessableEntity, ExpectedCode: CodeValidationFailed},
+		{Name: "missing code", Body: `{"password": "passwordkrub"}`, ExpectedStatus: http.StatusUnprocessableEntity, ExpectedCode: CodeValidationFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+			mockService.On("ConfirmTwoFactor", mock.Anything, "123", "passwordkrub", "123456", mock.Anything).
+				Return([]string{"abcde-fghij"}, test.ServiceError)
+
+			req := httptest.NewRequest(http.MethodPost, "/user/123/2fa/confirm", strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("123")
+
+			assert.NoError(t, handler.ConfirmTwoFactor(c))
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+			if test.ExpectedCode != "" {
+				assert.Contains(t, rec.Body.String(), `"code":"`+test.ExpectedCode+`"`)
+			} else {
+				assert.JSONEq(t, `{"recovery_codes": ["abcde-fghij"]}`, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Body           string
+		ServiceError   error
+		ExpectedStatus int
+		ExpectedCode   string
+	}{
+		{Name: "valid password and code", Body: `{"password": "passwordkrub", "code": "123456"}`, ExpectedStatus: http.StatusOK},
+		{Name: "wrong password", Body: `{"password": "passwordkrub", "code": "123456"}`, ServiceError: domain.ErrIncorrectPassword, ExpectedStatus: http.StatusForbidden, ExpectedCode: CodeIncorrectPassword},
+		{Name: "wrong code", Body: `{"password": "passwordkrub", "code": "123456"}`, ServiceError: domain.ErrInvalidTwoFactorCode, ExpectedStatus: http.StatusForbidden, ExpectedCode: CodeInvalidTwoFactor},
+		{Name: "missing password", Body: `{"code": "123456"}`, ExpectedStatus: http.StatusUnprocessableEntity, ExpectedCode: CodeValidationFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			e := echo.New()
+			e.Validator = NewRequestValidator()
+			mockService := new(MockUserService)
+			handler := NewHttpUserHandler(mockService, &config.Container{})
+			mockService.On("DisableTwoFactor", mock.Anything, "123", "passwordkrub", "123456", mock.Anything).Return(test.ServiceError)
+
+			req := httptest.NewRequest(http.MethodPost, "/user/123/2fa/disable", strings.NewReader(test.Body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("123")
+
+			assert.NoError(t, handler.DisableTwoFactor(c))
+			assert.Equal(t, test.ExpectedStatus, rec.Code)
+			if test.ExpectedCode != "" {
+				assert.Contains(t, rec.Body.String(), `"code":"`+test.ExpectedCode+`"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"one1-be-chal/internal/core/domain"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// parseUserQuery reads the GET /user query parameters:
+// limit, cursor, sort (created_at|name), order (asc|desc), name (prefix),
+// email_domain, created_from and created_to (RFC 3339).
+func parseUserQuery(c echo.Context) (domain.UserQuery, error) {
+	query := domain.UserQuery{
+		SortBy:      c.QueryParam("sort"),
+		NamePrefix:  c.QueryParam("name"),
+		EmailDomain: c.QueryParam("email_domain"),
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			return domain.UserQuery{}, domain.NewValidationError("limit must be a number")
+		}
+		query.Limit = value
+	}
+
+	switch order := c.QueryParam("order"); order {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return domain.UserQuery{}, domain.NewValidationError("order must be one of asc, desc")
+	}
+	// An explicit order pins the sort, otherwise a cursor brings its own.
+	if query.SortBy == "" && query.Descending {
+		query.SortBy = domain.SortByCreatedAt
+	}
+
+	var err error
+	if query.CreatedFrom, err = parseTimeParam(c, "created_from"); err != nil {
+		return domain.UserQuery{}, err
+	}
+	if query.CreatedTo, err = parseTimeParam(c, "created_to"); err != nil {
+		return domain.UserQuery{}, err
+	}
+
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		if query.After, err = domain.DecodeUserCursor(cursor); err != nil {
+			return domain.UserQuery{}, err
+		}
+	}
+	return query, nil
+}
+
+func parseTimeParam(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, domain.NewValidationError(name + " must be an RFC 3339 timestamp")
+	}
+	return parsed, nil
+}
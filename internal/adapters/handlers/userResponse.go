@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"one1-be-chal/internal/core/domain"
+	"time"
+)
+
+// UserResponse is the public representation of a user. It never carries
+// credentials, so handlers must map domain.User to it before responding.
+type UserResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+
+	EmailVerified    bool `json:"email_verified"`
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+}
+
+func NewUserResponse(user domain.User) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Role:      user.UserRole(),
+		CreatedAt: user.CreatedAt,
+
+		EmailVerified:    user.EmailVerified,
+		TwoFactorEnabled: user.TwoFactorEnabled,
+	}
+}
+
+func NewUserResponses(users []domain.User) []UserResponse {
+	responses := make([]UserResponse, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, NewUserResponse(user))
+	}
+	return responses
+}
+
+type PagingResponse struct {
+	Limit   int    `json:"limit"`
+	Next    string `json:"next,omitempty"`
+	HasMore bool   `json:"has_more"`
+}
+
+type UserPageResponse struct {
+	Data   []UserResponse `json:"data"`
+	Paging PagingResponse `json:"paging"`
+}
+
+func NewUserPageResponse(page domain.UserPage) UserPageResponse {
+	return UserPageResponse{
+		Data: NewUserResponses(page.Users),
+		Paging: PagingResponse{
+			Limit:   page.Limit,
+			Next:    page.NextCursor,
+			HasMore: page.NextCursor != "",
+		},
+	}
+}
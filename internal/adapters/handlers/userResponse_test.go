@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"one1-be-chal/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUserResponse(t *testing.T) {
+	createdAt := time.Now()
+	user := domain.User{
+		ID:        "123",
+		Name:      "One1 Yean",
+		Email:     "test@gmail.com",
+		Password:  "hashed",
+		CreatedAt: createdAt,
+
+		TwoFactorEnabled: true,
+		TOTPSecret:       "JBSWY3DPEHPK3PXP",
+		RecoveryCodes:    []string{"hashed"},
+	}
+
+	response := NewUserResponse(user)
+
+	assert.Equal(t, UserResponse{
+		ID:        "123",
+		Name:      "One1 Yean",
+		Email:     "test@gmail.com",
+		Role:      domain.RoleUser,
+		CreatedAt: createdAt,
+
+		TwoFactorEnabled: true,
+	}, response)
+}
+
+func TestNewUserResponses(t *testing.T) {
+	assert.Equal(t, []UserResponse{}, NewUserResponses(nil))
+	assert.Len(t, NewUserResponses([]domain.User{{ID: "1"}, {ID: "2"}}), 2)
+}
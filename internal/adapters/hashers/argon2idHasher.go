@@ -0,0 +1,89 @@
+package hashers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/core/ports"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+	// argon2idMaxMemory catches typos such as a memory cost given in bytes.
+	argon2idMaxMemory = 4 * 1024 * 1024
+)
+
+// Argon2idHasher hashes passwords with Argon2id into PHC strings such as
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>, salt and key in unpadded base64.
+type Argon2idHasher struct {
+	params config.Argon2id
+}
+
+func NewArgon2idHasher(config *config.Argon2id) (ports.PasswordHasher, error) {
+	switch {
+	case config.Iterations < 1:
+		return nil, errors.New("argon2id: iterations must be at least 1")
+	case config.Parallelism < 1:
+		return nil, errors.New("argon2id: parallelism must be at least 1")
+	case config.Memory < 8*uint32(config.Parallelism) || config.Memory > argon2idMaxMemory:
+		return nil, fmt.Errorf("argon2id: memory must be between %d and %d KiB", 8*uint32(config.Parallelism), argon2idMaxMemory)
+	}
+	return &Argon2idHasher{params: *config}, nil
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, argon2idKeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(password, hash string) (bool, bool, error) {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, false, err
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, false, nil
+	}
+	return true, params != h.params || len(salt) != argon2idSaltLength || len(key) != argon2idKeyLength, nil
+}
+
+func parseArgon2id(hash string) (params config.Argon2id, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) < 2 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnsupportedHash
+	}
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("argon2id: malformed hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("argon2id: unsupported version %q", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("argon2id: malformed parameters %q", parts[3])
+	}
+	if params.Iterations < 1 || params.Parallelism < 1 || params.Memory > argon2idMaxMemory {
+		return params, nil, nil, fmt.Errorf("argon2id: invalid parameters %q", parts[3])
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("argon2id: malformed salt: %w", err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, errors.New("argon2id: malformed key")
+	}
+	return params, salt, key, nil
+}
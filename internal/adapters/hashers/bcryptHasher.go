@@ -0,0 +1,45 @@
+package hashers
+
+import (
+	"errors"
+	"fmt"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/core/ports"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHasher hashes passwords with bcrypt, whose $2a$<cost>$ strings already
+// carry their parameters. bcrypt only uses the first 72 bytes of a password
+// and rejects longer ones.
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcryptHasher(config *config.Bcrypt) (ports.PasswordHasher, error) {
+	if config.Cost < bcrypt.MinCost || config.Cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt: cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return &BcryptHasher{cost: config.Cost}, nil
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	return string(hash), err
+}
+
+func (h *BcryptHasher) Verify(password, hash string) (bool, bool, error) {
+	if !strings.HasPrefix(hash, "$2") {
+		return false, false, ErrUnsupportedHash
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, cost != h.cost, err
+}
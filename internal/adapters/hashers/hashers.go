@@ -0,0 +1,62 @@
+package hashers
+
+import (
+	"errors"
+	"fmt"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/core/ports"
+)
+
+// ErrUnsupportedHash is returned by Verify for hashes of another algorithm.
+var ErrUnsupportedHash = errors.New("unsupported password hash")
+
+// New returns a hasher that hashes new passwords with config.Algorithm and
+// still verifies hashes of the other algorithm, flagging them for a rehash.
+func New(config *config.PasswordHashing) (ports.PasswordHasher, error) {
+	argon2id, err := NewArgon2idHasher(config.Argon2id)
+	if err != nil {
+		return nil, err
+	}
+	bcrypt, err := NewBcryptHasher(config.Bcrypt)
+	if err != nil {
+		return nil, err
+	}
+	switch config.Algorithm {
+	case "argon2id":
+		return NewUpgradingHasher(argon2id, bcrypt), nil
+	case "bcrypt":
+		return NewUpgradingHasher(bcrypt, argon2id), nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q, want argon2id or bcrypt", config.Algorithm)
+	}
+}
+
+// UpgradingHasher hashes with its preferred hasher and verifies with whichever
+// hasher understands a hash. Hashes the preferred hasher didn't make always
+// need a rehash.
+type UpgradingHasher struct {
+	preferred ports.PasswordHasher
+	others    []ports.PasswordHasher
+}
+
+func NewUpgradingHasher(preferred ports.PasswordHasher, others ...ports.PasswordHasher) ports.PasswordHasher {
+	return &UpgradingHasher{preferred: preferred, others: others}
+}
+
+func (h *UpgradingHasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *UpgradingHasher) Verify(password, hash string) (bool, bool, error) {
+	ok, needsRehash, err := h.preferred.Verify(password, hash)
+	if !errors.Is(err, ErrUnsupportedHash) {
+		return ok, needsRehash, err
+	}
+	for _, other := range h.others {
+		ok, _, err := other.Verify(password, hash)
+		if !errors.Is(err, ErrUnsupportedHash) {
+			return ok, ok, err
+		}
+	}
+	return false, false, ErrUnsupportedHash
+}
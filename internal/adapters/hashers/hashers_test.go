@@ -0,0 +1,152 @@
+package hashers
+
+import (
+	"one1-be-chal/internal/adapters/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters keep the tests fast, they are far too weak for production.
+var (
+	testArgon2id = &config.Argon2id{Memory: 64, Iterations: 1, Parallelism: 1}
+	testBcrypt   = &config.Bcrypt{Cost: bcrypt.MinCost}
+)
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher, err := NewArgon2idHasher(testArgon2id)
+	require.NoError(t, err)
+
+	hash, err := hasher.Hash("passwordkrub")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"), hash)
+	other, err := hasher.Hash("passwordkrub")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "every hash has its own salt")
+
+	ok, needsRehash, err := hasher.Verify("passwordkrub", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, needsRehash)
+
+	ok, _, err = hasher.Verify("wrongpassword", hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	stronger, err := NewArgon2idHasher(&config.Argon2id{Memory: 128, Iterations: 2, Parallelism: 1})
+	require.NoError(t, err)
+	ok, needsRehash, err = stronger.Verify("passwordkrub", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok, "hashes keep their own parameters")
+	assert.True(t, needsRehash)
+}
+
+func TestArgon2idHasherRejectsHashes(t *testing.T) {
+	hasher, err := NewArgon2idHasher(testArgon2id)
+	require.NoError(t, err)
+
+	_, _, err = hasher.Verify("passwordkrub", "$2a$10$abcdefghijklmnopqrstuv")
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+	for _, hash := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5",
+	} {
+		_, _, err = hasher.Verify("passwordkrub", hash)
+		assert.Error(t, err, hash)
+		assert.NotErrorIs(t, err, ErrUnsupportedHash, hash)
+	}
+}
+
+func TestNewArgon2idHasherValidates(t *testing.T) {
+	for _, params := range []config.Argon2id{
+		{Memory: 64, Iterations: 0, Parallelism: 1},
+		{Memory: 64, Iterations: 1, Parallelism: 0},
+		{Memory: 4, Iterations: 1, Parallelism: 1},
+		{Memory: 1 << 30, Iterations: 1, Parallelism: 1},
+	} {
+		_, err := NewArgon2idHasher(&params)
+		assert.Error(t, err, params)
+	}
+}
+
+func TestBcryptHasher(t *testing.T) {
+	hasher, err := NewBcryptHasher(testBcrypt)
+	require.NoError(t, err)
+
+	hash, err := hasher.Hash("passwordkrub")
+	require.NoError(t, err)
+	ok, needsRehash, err := hasher.Verify("passwordkrub", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, needsRehash)
+
+	ok, _, err = hasher.Verify("wrongpassword", hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	stronger, err := NewBcryptHasher(&config.Bcrypt{Cost: bcrypt.MinCost + 1})
+	require.NoError(t, err)
+	ok, needsRehash, err = stronger.Verify("passwordkrub", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash)
+
+	_, _, err = hasher.Verify("passwordkrub", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5")
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+	_, err = NewBcryptHasher(&config.Bcrypt{Cost: bcrypt.MaxCost + 1})
+	assert.Error(t, err)
+}
+
+func TestUpgradingHasher(t *testing.T) {
+	argon2id, err := NewArgon2idHasher(testArgon2id)
+	require.NoError(t, err)
+	legacy, err := NewBcryptHasher(testBcrypt)
+	require.NoError(t, err)
+	hasher := NewUpgradingHasher(argon2id, legacy)
+
+	hash, err := hasher.Hash("passwordkrub")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+	ok, needsRehash, err := hasher.Verify("passwordkrub", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, needsRehash)
+
+	legacyHash, err := legacy.Hash("passwordkrub")
+	require.NoError(t, err)
+	ok, needsRehash, err = hasher.Verify("passwordkrub", legacyHash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash, "bcrypt hashes get upgraded")
+	ok, needsRehash, err = hasher.Verify("wrongpassword", legacyHash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, needsRehash)
+
+	_, _, err = hasher.Verify("passwordkrub", "plaintext")
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+}
+
+func TestNew(t *testing.T) {
+	for _, algorithm := range []string{"argon2id", "bcrypt"} {
+		hasher, err := New(&config.PasswordHashing{Algorithm: algorithm, Argon2id: testArgon2id, Bcrypt: testBcrypt})
+		require.NoError(t, err, algorithm)
+		hash, err := hasher.Hash("passwordkrub")
+		require.NoError(t, err)
+		if algorithm == "argon2id" {
+			assert.True(t, strings.HasPrefix(hash, "$argon2id$"), hash)
+		} else {
+			assert.True(t, strings.HasPrefix(hash, "$2a$"), hash)
+		}
+	}
+
+	_, err := New(&config.PasswordHashing{Algorithm: "md5", Argon2id: testArgon2id, Bcrypt: testBcrypt})
+	assert.Error(t, err)
+	_, err = New(&config.PasswordHashing{Algorithm: "argon2id", Argon2id: &config.Argon2id{}, Bcrypt: testBcrypt})
+	assert.Error(t, err)
+}
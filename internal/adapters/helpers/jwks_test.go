@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"one1-be-chal/internal/adapters/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicJWKS(t *testing.T) {
+	rsaKey := newSigningKey(t, "rsa-key", "rsa")
+	edKey := newSigningKey(t, "ed-key", "ed25519")
+	mockConfig := asymmetricConfig(rsaKey, edKey)
+
+	jwks := PublicJWKS(mockConfig)
+
+	assert.Len(t, jwks.Keys, 2)
+
+	ed := jwks.Keys[0]
+	assert.Equal(t, "ed-key", ed.Kid)
+	assert.Equal(t, "OKP", ed.Kty)
+	assert.Equal(t, "Ed25519", ed.Crv)
+	assert.Equal(t, "EdDSA", ed.Alg)
+	x, err := base64.RawURLEncoding.DecodeString(ed.X)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(edKey.Key.Public().(ed25519.PublicKey)), x)
+
+	rs := jwks.Keys[1]
+	publicKey := rsaKey.Key.Public().(*rsa.PublicKey)
+	assert.Equal(t, "rsa-key", rs.Kid)
+	assert.Equal(t, "RSA", rs.Kty)
+	assert.Equal(t, "RS256", rs.Alg)
+	n, err := base64.RawURLEncoding.DecodeString(rs.N)
+	assert.NoError(t, err)
+	assert.Equal(t, publicKey.N, new(big.Int).SetBytes(n))
+	e, err := base64.RawURLEncoding.DecodeString(rs.E)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(publicKey.E), new(big.Int).SetBytes(e).Int64())
+}
+
+func TestPublicJWKSWithoutKeys(t *testing.T) {
+	jwks := PublicJWKS(config.Container{JWT: &config.JWT{SecretKey: []byte("secret")}})
+
+	body, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"keys": []}`, string(body))
+}
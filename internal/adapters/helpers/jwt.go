@@ -1,41 +1,197 @@
 package helpers
 
 import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"one1-be-chal/internal/adapters/config"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// AccessTokenTTL is how long a token from GenerateJWT stays valid.
+const AccessTokenTTL = 1 * time.Hour
+
+// MFAPendingScope marks tokens from GenerateMFAToken, which only prove the
+// password step of a login with two-factor authentication.
+const MFAPendingScope = "mfa_pending"
+
 type Claims struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	Role  string `json:"role"`
+	// EmailVerified is false until the user confirmed their email address.
+	EmailVerified bool `json:"email_verified"`
+	// Scope limits what the token is good for, access tokens have none.
+	Scope string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(id, name, email string, config config.Container) (string, error) {
+// GenerateJWT returns an access token valid for AccessTokenTTL after
+// issuedAt. Like every date in the token, its iat is in whole seconds.
+func GenerateJWT(id, name, email, role string, emailVerified bool, issuedAt time.Time, config config.Container) (string, error) {
+	claims := Claims{
+		ID:            id,
+		Name:          name,
+		Email:         email,
+		Role:          role,
+		EmailVerified: emailVerified,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+		},
+	}
+	return signClaims(claims, config)
+}
+
+// GenerateMFAToken returns a token for the second step of a login, valid for
+// config.TwoFactor.PendingTokenTTL after issuedAt. It is signed with mfaKey,
+// so ParseJWT and anyone verifying access tokens with the JWKS reject it.
+func GenerateMFAToken(id string, issuedAt time.Time, config config.Container) (string, error) {
 	claims := Claims{
 		ID:    id,
-		Name:  name,
-		Email: email,
+		Scope: MFAPendingScope,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(config.TwoFactor.PendingTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims) //Use HMAC (HS256) with a secret key.
-	return token.SignedString(config.JWT.SecretKey)
+	key, err := mfaKey(config.JWT)
+	if err != nil {
+		return "", err
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
 }
 
+// mfaKey is the HS256 key of MFA pending tokens. It is derived from the
+// private signing key, or the HS256 secret of access tokens, and never
+// published, so a password alone never yields a token other services accept.
+func mfaKey(config *config.JWT) ([]byte, error) {
+	secret := config.SecretKey
+	if config.SigningKey != nil {
+		der, err := x509.MarshalPKCS8PrivateKey(config.SigningKey.Key)
+		if err != nil {
+			return nil, err
+		}
+		secret = der
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("no signing key to derive the mfa token key from")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(MFAPendingScope))
+	return mac.Sum(nil), nil
+}
+
+func signClaims(claims Claims, config config.Container) (string, error) {
+	signingKey := config.JWT.SigningKey
+	if signingKey == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims) //Use HMAC (HS256) with a secret key.
+		return token.SignedString(config.JWT.SecretKey)
+	}
+
+	method, err := signingMethod(signingKey)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = signingKey.ID
+	return token.SignedString(signingKey.Key)
+}
+
+// TokenID returns the jti claim, which identifies the token for revocation.
+func (c *Claims) TokenID() string {
+	return c.RegisteredClaims.ID
+}
+
+// ParseJWT verifies tokens carrying a kid header against the matching
+// verification key, and tokens without one against the HS256 secret. Only
+// access tokens are accepted, not tokens limited to a scope.
 func ParseJWT(tokenStr string, config config.Container) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{},
+	claims, err := parseClaims(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		return verificationKey(token, config.JWT)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.Scope != "" {
+		return nil, fmt.Errorf("token is limited to scope %q", claims.Scope)
+	}
+	return claims, nil
+}
+
+// ParseMFAToken verifies a token from GenerateMFAToken, checking its expiry against now.
+func ParseMFAToken(tokenStr string, now time.Time, config config.Container) (*Claims, error) {
+	claims, err := parseClaims(tokenStr,
 		func(token *jwt.Token) (interface{}, error) {
-			return config.JWT.SecretKey, nil
+			return mfaKey(config.JWT)
 		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithTimeFunc(func() time.Time { return now }),
 	)
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	if err != nil {
+		return nil, err
+	}
+	if claims.Scope != MFAPendingScope {
+		return nil, errors.New("not an mfa pending token")
+	}
+	return claims, nil
+}
+
+func parseClaims(tokenStr string, keyFunc jwt.Keyfunc, options ...jwt.ParserOption) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, keyFunc, options...)
+	if token != nil {
+		if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+			return claims, nil
+		}
 	}
 	return nil, err
 }
+
+func signingMethod(signingKey *config.SigningKey) (jwt.SigningMethod, error) {
+	switch signingKey.Key.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", signingKey.Key)
+	}
+}
+
+// verificationKey only hands out a key whose type matches the token's alg, so
+// an attacker can't pick the algorithm the key is interpreted with.
+func verificationKey(token *jwt.Token, config *config.JWT) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(config.SecretKey) == 0 {
+			return nil, errors.New("token is missing kid header")
+		}
+		return config.SecretKey, nil
+	}
+
+	key, ok := config.VerificationKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); ok {
+			return key, nil
+		}
+	case ed25519.PublicKey:
+		if _, ok := token.Method.(*jwt.SigningMethodEd25519); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected signing method %q for kid %q", token.Method.Alg(), kid)
+}
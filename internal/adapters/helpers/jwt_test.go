@@ -1,12 +1,44 @@
 package helpers
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
 	"one1-be-chal/internal/adapters/config"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
+func newSigningKey(t *testing.T, id string, keyType string) *config.SigningKey {
+	t.Helper()
+	switch keyType {
+	case "rsa":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+		return &config.SigningKey{ID: id, Key: key}
+	default:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+		return &config.SigningKey{ID: id, Key: key}
+	}
+}
+
+func asymmetricConfig(signingKey *config.SigningKey, retired ...*config.SigningKey) config.Container {
+	verificationKeys := map[string]crypto.PublicKey{signingKey.ID: signingKey.Key.Public()}
+	for _, key := range retired {
+		verificationKeys[key.ID] = key.Key.Public()
+	}
+	return config.Container{
+		JWT: &config.JWT{SigningKey: signingKey, VerificationKeys: verificationKeys},
+	}
+}
+
 func TestGenerateJWT(t *testing.T) {
 	mockConfig := config.Container{
 		JWT: &config.JWT{SecretKey: []byte("secret")},
@@ -16,7 +48,7 @@ func TestGenerateJWT(t *testing.T) {
 	name := "One1 yean"
 	email := "test@gmail.com"
 
-	token, err := GenerateJWT(id, name, email, mockConfig)
+	token, err := GenerateJWT(id, name, email, "user", true, time.Now(), mockConfig)
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
@@ -31,7 +63,7 @@ func TestParseJWT(t *testing.T) {
 	name := "One1 yean"
 	email := "test@gmail.com"
 
-	token, err := GenerateJWT(id, name, email, mockConfig)
+	token, err := GenerateJWT(id, name, email, "user", true, time.Now(), mockConfig)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 
@@ -40,6 +72,94 @@ func TestParseJWT(t *testing.T) {
 	assert.Equal(t, id, claims.ID)
 	assert.Equal(t, name, claims.Name)
 	assert.Equal(t, email, claims.Email)
+	assert.Equal(t, "user", claims.Role)
+	assert.True(t, claims.EmailVerified)
+	assert.NotEmpty(t, claims.TokenID())
+}
+
+func TestGenerateJWTWholeSecondDates(t *testing.T) {
+	mockConfig := config.Container{
+		JWT: &config.JWT{SecretKey: []byte("secret")},
+	}
+
+	token, err := GenerateJWT("123", "One1 yean", "test@gmail.com", "user", true, time.Unix(1735689600, 999_000_000), mockConfig)
+	assert.NoError(t, err)
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	assert.NoError(t, err)
+	assert.Contains(t, string(payload), `"iat":1735689600,`, "strict verifiers expect integer NumericDates")
+	assert.Contains(t, string(payload), `"exp":1735693200,`)
+}
+
+func TestGenerateJWTUniqueTokenID(t *testing.T) {
+	mockConfig := config.Container{
+		JWT: &config.JWT{SecretKey: []byte("secret")},
+	}
+
+	first, _ := GenerateJWT("123", "One1 yean", "test@gmail.com", "user", true, time.Now(), mockConfig)
+	second, _ := GenerateJWT("123", "One1 yean", "test@gmail.com", "user", true, time.Now(), mockConfig)
+
+	firstClaims, err := ParseJWT(first, mockConfig)
+	assert.NoError(t, err)
+	secondClaims, err := ParseJWT(second, mockConfig)
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstClaims.TokenID(), secondClaims.TokenID())
+}
+
+func TestMFAToken(t *testing.T) {
+	mockConfig := config.Container{
+		JWT:       &config.JWT{SecretKey: []byte("secret")},
+		TwoFactor: &config.TwoFactor{PendingTokenTTL: 5 * time.Minute},
+	}
+	issuedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	token, err := GenerateMFAToken("123", issuedAt, mockConfig)
+	assert.NoError(t, err)
+
+	claims, err := ParseMFAToken(token, issuedAt.Add(4*time.Minute), mockConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, "123", claims.ID)
+	assert.Equal(t, MFAPendingScope, claims.Scope)
+	_, err = ParseMFAToken(token, issuedAt.Add(6*time.Minute), mockConfig)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+
+	_, err = ParseJWT(token, mockConfig)
+	assert.Error(t, err, "mfa pending tokens are no access tokens")
+	accessToken, err := GenerateJWT("123", "One1 yean", "test@gmail.com", "user", true, time.Now(), mockConfig)
+	assert.NoError(t, err)
+	_, err = ParseMFAToken(accessToken, time.Now(), mockConfig)
+	assert.Error(t, err, "access tokens are no mfa pending tokens")
+}
+
+func TestMFATokenFailsAccessTokenVerification(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Config config.Container
+	}{
+		{Name: "HS256", Config: config.Container{JWT: &config.JWT{SecretKey: []byte("secret")}}},
+		{Name: "RS256", Config: asymmetricConfig(newSigningKey(t, "key-1", "rsa"))},
+		{Name: "EdDSA", Config: asymmetricConfig(newSigningKey(t, "key-1", "ed25519"))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			test.Config.TwoFactor = &config.TwoFactor{PendingTokenTTL: 5 * time.Minute}
+			token, err := GenerateMFAToken("123", time.Now(), test.Config)
+			assert.NoError(t, err)
+
+			// A verifier with the access token key, that knows nothing of the scope claim.
+			var accessKey interface{} = test.Config.JWT.SecretKey
+			if signingKey := test.Config.JWT.SigningKey; signingKey != nil {
+				accessKey = signingKey.Key.Public()
+			}
+			_, err = jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+				return accessKey, nil
+			})
+			assert.Error(t, err)
+			_, err = ParseJWT(token, test.Config)
+			assert.Error(t, err)
+		})
+	}
 }
 
 func TestParseJWTInvalidToken(t *testing.T) {
@@ -51,3 +171,84 @@ func TestParseJWTInvalidToken(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, claims)
 }
+
+func TestAsymmetricJWT(t *testing.T) {
+	tests := []struct {
+		Name    string
+		KeyType string
+		Alg     string
+	}{
+		{Name: "RS256", KeyType: "rsa", Alg: "RS256"},
+		{Name: "EdDSA", KeyType: "ed25519", Alg: "EdDSA"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			mockConfig := asymmetricConfig(newSigningKey(t, "key-1", test.KeyType))
+
+			token, err := GenerateJWT("123", "One1 yean", "test@gmail.com", "user", true, time.Now(), mockConfig)
+			assert.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			assert.NoError(t, err)
+			assert.Equal(t, "key-1", parsed.Header["kid"])
+			assert.Equal(t, test.Alg, parsed.Method.Alg())
+
+			claims, err := ParseJWT(token, mockConfig)
+			assert.NoError(t, err)
+			assert.Equal(t, "123", claims.ID)
+		})
+	}
+}
+
+func TestParseJWTKeyRotation(t *testing.T) {
+	oldKey := newSigningKey(t, "2025-01", "rsa")
+	newKey := newSigningKey(t, "2025-06", "ed25519")
+
+	oldToken, err := GenerateJWT("123", "One1 yean", "test@gmail.com", "user", true, time.Now(), asymmetricConfig(oldKey))
+	assert.NoError(t, err)
+
+	t.Run("retired key still verifies", func(t *testing.T) {
+		claims, err := ParseJWT(oldToken, asymmetricConfig(newKey, oldKey))
+		assert.NoError(t, err)
+		assert.Equal(t, "123", claims.ID)
+	})
+
+	t.Run("removed key no longer verifies", func(t *testing.T) {
+		claims, err := ParseJWT(oldToken, asymmetricConfig(newKey))
+		assert.Error(t, err)
+		assert.Nil(t, claims)
+	})
+}
+
+func TestParseJWTRejectsAlgorithmConfusion(t *testing.T) {
+	signingKey := newSigningKey(t, "key-1", "ed25519")
+	mockConfig := asymmetricConfig(signingKey)
+
+	t.Run("HS256 token with a kid", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+			ID: "123",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		})
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString([]byte(signingKey.Key.Public().(ed25519.PublicKey)))
+		assert.NoError(t, err)
+
+		claims, err := ParseJWT(signed, mockConfig)
+		assert.Error(t, err)
+		assert.Nil(t, claims)
+	})
+
+	t.Run("HS256 token without secret configured", func(t *testing.T) {
+		token, err := GenerateJWT("123", "One1 yean", "test@gmail.com", "user", true, time.Now(), config.Container{
+			JWT: &config.JWT{SecretKey: []byte("secret")},
+		})
+		assert.NoError(t, err)
+
+		claims, err := ParseJWT(token, mockConfig)
+		assert.Error(t, err)
+		assert.Nil(t, claims)
+	})
+}
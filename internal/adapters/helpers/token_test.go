@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateRandomToken(t *testing.T) {
+	first, err := GenerateRandomToken()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, first)
+
+	second, err := GenerateRandomToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func TestHashToken(t *testing.T) {
+	token := "refreshtokenkrub"
+
+	assert.Equal(t, HashToken(token), HashToken(token))
+	assert.NotEqual(t, token, HashToken(token))
+	assert.NotEqual(t, HashToken(token), HashToken("othertoken"))
+}
+
+func TestGenerateRecoveryCode(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	assert.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`), code)
+
+	other, err := GenerateRecoveryCode()
+	assert.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	assert.Equal(t, "abcdefghij", NormalizeRecoveryCode("abcde-fghij"))
+	assert.Equal(t, "abcdefghij", NormalizeRecoveryCode(" ABCDE FGHIJ"))
+	assert.Equal(t, "abcdefghij", NormalizeRecoveryCode("abcdefghij"))
+}
@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890" in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC lists 8 digit codes, these are their last 6 digits.
+	tests := []struct {
+		Unix int64
+		Code string
+	}{
+		{Unix: 59, Code: "287082"},
+		{Unix: 1111111109, Code: "081804"},
+		{Unix: 1111111111, Code: "050471"},
+		{Unix: 1234567890, Code: "005924"},
+		{Unix: 2000000000, Code: "279037"},
+	}
+	for _, test := range tests {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(test.Unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, test.Code, code, test.Unix)
+	}
+
+	_, err := TOTPCode("not base32!", time.Unix(59, 0))
+	assert.Error(t, err)
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := TOTPCode(rfc6238Secret, now)
+	require.NoError(t, err)
+
+	for _, offset := range []time.Duration{-30 * time.Second, 0, 30 * time.Second} {
+		step, ok, err := ValidateTOTP(rfc6238Secret, code, now.Add(offset))
+		assert.NoError(t, err)
+		assert.True(t, ok, offset)
+		assert.Equal(t, TOTPStep(now), step)
+	}
+	for _, offset := range []time.Duration{-90 * time.Second, 90 * time.Second} {
+		_, ok, err := ValidateTOTP(rfc6238Secret, code, now.Add(offset))
+		assert.NoError(t, err)
+		assert.False(t, ok, offset)
+	}
+	_, ok, err := ValidateTOTP(rfc6238Secret, "000000", now)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+	other, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+
+	_, err = TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Backend Challenge", "test@gmail.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Backend Challenge:test@gmail.com", uri.Path)
+	assert.Equal(t, url.Values{
+		"secret":    {"JBSWY3DPEHPK3PXP"},
+		"issuer":    {"Backend Challenge"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, uri.Query())
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAddsContextIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	require.NoError(t, err)
+
+	ctx := WithUserID(WithRequestID(context.Background(), "req-1"), "user-1")
+	logger.With("component", "test").InfoContext(ctx, "hello", "count", 2)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "hello", record["msg"])
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "user-1", record["user_id"])
+	assert.Equal(t, "test", record["component"])
+	assert.Equal(t, 2.0, record["count"])
+}
+
+func TestNewWithoutContextIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "text")
+	require.NoError(t, err)
+
+	logger.InfoContext(context.Background(), "hello")
+
+	assert.Contains(t, buf.String(), "msg=hello")
+	assert.NotContains(t, buf.String(), "request_id")
+}
+
+func TestNewLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "WARN", "json")
+	require.NoError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "shown")
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "verbose", "json")
+	assert.Error(t, err)
+
+	_, err = New(&bytes.Buffer{}, "info", "xml")
+	assert.Error(t, err)
+}
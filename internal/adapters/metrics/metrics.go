@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics owns the Prometheus registry and every collector of the service.
+// It uses its own registry rather than the global one so tests can create as
+// many as they need.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	repositoryDuration *prometheus.HistogramVec
+	repositoryErrors   *prometheus.CounterVec
+
+	usersTotal    prometheus.Gauge
+	registrations prometheus.Counter
+	logins        *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		repositoryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "user_repository_duration_seconds",
+			Help:    "User repository call latency by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		repositoryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "user_repository_errors_total",
+			Help: "User repository calls that failed, by method.",
+		}, []string{"method"}),
+		usersTotal: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "users_total",
+			Help: "Number of registered users.",
+		}),
+		registrations: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "user_registrations_total",
+			Help: "Successful user registrations.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "user_logins_total",
+			Help: "Login attempts by result, success, failure, throttled or mfa_required.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.repositoryDuration,
+		m.repositoryErrors,
+		m.usersTotal,
+		m.registrations,
+		m.logins,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records one HTTP request. route is the route pattern, such as
+// "/user/:id", never the raw path, to keep the number of series bounded.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	labels := prometheus.Labels{"method": method, "route": route, "status": strconv.Itoa(status)}
+	m.httpRequests.With(labels).Inc()
+	m.httpDuration.With(labels).Observe(duration.Seconds())
+}
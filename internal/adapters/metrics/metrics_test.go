@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/adapters/storages/memory"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+func TestHandlerServesPrometheusText(t *testing.T) {
+	m := New()
+	m.ObserveRequest(http.MethodGet, "/user/:id", http.StatusOK, 20*time.Millisecond)
+
+	body := scrape(t, m)
+
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/user/:id",status="200"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/user/:id",status="200",le="0.025"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+// failingCountRepository fails GetUserCount and delegates everything else.
+type failingCountRepository struct {
+	ports.UserRepository
+}
+
+func (failingCountRepository) GetUserCount(ctx context.Context) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestInstrumentUserRepository(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+	repo := InstrumentUserRepository(failingCountRepository{memory.NewUserRepository()}, m)
+
+	require.NoError(t, repo.Save(ctx, domain.User{ID: "1", Email: "test@gmail.com"}))
+	assert.ErrorIs(t, repo.Save(ctx, domain.User{ID: "2", Email: "test@gmail.com"}), domain.ErrEmailTaken)
+	_, err := repo.GetUserByID(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	_, err = repo.GetUserCount(ctx)
+	assert.Error(t, err)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `user_repository_duration_seconds_count{method="Save"} 2`)
+	assert.Contains(t, body, `user_repository_duration_seconds_count{method="GetUserByID"} 1`)
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.repositoryErrors.WithLabelValues("Save")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.repositoryErrors.WithLabelValues("GetUserByID")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.repositoryErrors.WithLabelValues("GetUserCount")))
+}
+
+// stubUserService answers Register and both login steps with tokens and err.
+type stubUserService struct {
+	ports.UserService
+	tokens domain.AuthTokens
+	err    error
+}
+
+func (s stubUserService) Register(ctx context.Context, user domain.User, config config.Container) (domain.AuthTokens, error) {
+	return domain.AuthTokens{}, s.err
+}
+
+func (s stubUserService) Login(ctx context.Context, email, password, clientIP string, config config.Container) (domain.AuthTokens, error) {
+	return s.tokens, s.err
+}
+
+func (s stubUserService) LoginTwoFactor(ctx context.Context, mfaToken, code, clientIP string, config config.Container) (domain.AuthTokens, error) {
+	return s.tokens, s.err
+}
+
+func TestInstrumentUserService(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+
+	ok := InstrumentUserService(stubUserService{}, m)
+	_, _ = ok.Register(ctx, domain.User{}, config.Container{})
+	_, _ = ok.Login(ctx, "test@gmail.com", "passwordkrub", "1.2.3.4", config.Container{})
+	_, _ = ok.Login(ctx, "test@gmail.com", "passwordkrub", "1.2.3.4", config.Container{})
+
+	_, _ = InstrumentUserService(stubUserService{err: domain.ErrEmailTaken}, m).Register(ctx, domain.User{}, config.Container{})
+	_, _ = InstrumentUserService(stubUserService{err: domain.ErrInvalidCredentials}, m).Login(ctx, "test@gmail.com", "wrong", "1.2.3.4", config.Container{})
+	_, _ = InstrumentUserService(stubUserService{err: &domain.LoginThrottledError{RetryAfter: time.Second}}, m).Login(ctx, "test@gmail.com", "wrong", "1.2.3.4", config.Container{})
+	_, _ = InstrumentUserService(stubUserService{err: errors.New("database down")}, m).Login(ctx, "test@gmail.com", "wrong", "1.2.3.4", config.Container{})
+
+	pending := InstrumentUserService(stubUserService{tokens: domain.AuthTokens{MFAToken: "pending"}}, m)
+	_, _ = pending.Login(ctx, "test@gmail.com", "passwordkrub", "1.2.3.4", config.Container{})
+	_, _ = ok.LoginTwoFactor(ctx, "pending", "123456", "1.2.3.4", config.Container{})
+	_, _ = InstrumentUserService(stubUserService{err: domain.ErrInvalidTwoFactorCode}, m).LoginTwoFactor(ctx, "pending", "000000", "1.2.3.4", config.Container{})
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.registrations))
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.logins.WithLabelValues("success")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.logins.WithLabelValues("failure")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.logins.WithLabelValues("throttled")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.logins.WithLabelValues("mfa_required")))
+}
+
+func TestTrackUserCount(t *testing.T) {
+	m := New()
+	repo := memory.NewUserRepository()
+	require.NoError(t, repo.Save(context.Background(), domain.User{ID: "1", Email: "first@gmail.com"}))
+	require.NoError(t, repo.Save(context.Background(), domain.User{ID: "2", Email: "second@gmail.com"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.TrackUserCount(ctx, repo, 10*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(m.usersTotal) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("TrackUserCount kept running after its context was cancelled")
+	}
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"one1-be-chal/internal/core/ports"
+	"time"
+)
+
+// TrackUserCount refreshes users_total every interval until ctx is cancelled.
+func (m *Metrics) TrackUserCount(ctx context.Context, repository ports.UserRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		count, err := repository.GetUserCount(ctx)
+		if err == nil {
+			m.usersTotal.Set(float64(count))
+		} else if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "getting user count", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
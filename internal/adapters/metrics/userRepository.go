@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"time"
+)
+
+type instrumentedUserRepository struct {
+	next    ports.UserRepository
+	metrics *Metrics
+}
+
+// InstrumentUserRepository records the latency and errors of every call to repository.
+func InstrumentUserRepository(repository ports.UserRepository, metrics *Metrics) ports.UserRepository {
+	return &instrumentedUserRepository{
+		next:    repository,
+		metrics: metrics,
+	}
+}
+
+// observe records a call that started at start. Not found and email taken are
+// answers rather than failures, so they don't count as errors.
+func (r *instrumentedUserRepository) observe(method string, start time.Time, err error) {
+	r.metrics.repositoryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) && !errors.Is(err, domain.ErrEmailTaken) {
+		r.metrics.repositoryErrors.WithLabelValues(method).Inc()
+	}
+}
+
+func (r *instrumentedUserRepository) Save(ctx context.Context, user domain.User) (err error) {
+	defer func(start time.Time) { r.observe("Save", start, err) }(time.Now())
+	return r.next.Save(ctx, user)
+}
+
+func (r *instrumentedUserRepository) GetUserByID(ctx context.Context, id string) (user domain.User, err error) {
+	defer func(start time.Time) { r.observe("GetUserByID", start, err) }(time.Now())
+	return r.next.GetUserByID(ctx, id)
+}
+
+func (r *instrumentedUserRepository) GetAllUsers(ctx context.Context, query domain.UserQuery) (users []domain.User, err error) {
+	defer func(start time.Time) { r.observe("GetAllUsers", start, err) }(time.Now())
+	return r.next.GetAllUsers(ctx, query)
+}
+
+func (r *instrumentedUserRepository) UpdateUser(ctx context.Context, id string, patch domain.UserPatch) (err error) {
+	defer func(start time.Time) { r.observe("UpdateUser", start, err) }(time.Now())
+	return r.next.UpdateUser(ctx, id, patch)
+}
+
+func (r *instrumentedUserRepository) DeleteUser(ctx context.Context, id string) (err error) {
+	defer func(start time.Time) { r.observe("DeleteUser", start, err) }(time.Now())
+	return r.next.DeleteUser(ctx, id)
+}
+
+func (r *instrumentedUserRepository) GetUserByEmail(ctx context.Context, email string) (user *domain.User, err error) {
+	defer func(start time.Time) { r.observe("GetUserByEmail", start, err) }(time.Now())
+	return r.next.GetUserByEmail(ctx, email)
+}
+
+func (r *instrumentedUserRepository) GetUserCount(ctx context.Context) (count int64, err error) {
+	defer func(start time.Time) { r.observe("GetUserCount", start, err) }(time.Now())
+	return r.next.GetUserCount(ctx)
+}
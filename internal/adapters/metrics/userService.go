@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+)
+
+// instrumentedUserService counts registrations and logins, including the
+// second factor step, every other call goes straight to the embedded service.
+type instrumentedUserService struct {
+	ports.UserService
+	metrics *Metrics
+}
+
+func InstrumentUserService(service ports.UserService, metrics *Metrics) ports.UserService {
+	return &instrumentedUserService{
+		UserService: service,
+		metrics:     metrics,
+	}
+}
+
+func (s *instrumentedUserService) Register(ctx context.Context, user domain.User, config config.Container) (domain.AuthTokens, error) {
+	tokens, err := s.UserService.Register(ctx, user, config)
+	if err == nil {
+		s.metrics.registrations.Inc()
+	}
+	return tokens, err
+}
+
+func (s *instrumentedUserService) Login(ctx context.Context, email, password, clientIP string, config config.Container) (domain.AuthTokens, error) {
+	tokens, err := s.UserService.Login(ctx, email, password, clientIP, config)
+	switch {
+	case err == nil && tokens.MFAToken != "":
+		s.metrics.logins.WithLabelValues("mfa_required").Inc()
+	case err == nil:
+		s.metrics.logins.WithLabelValues("success").Inc()
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		s.metrics.logins.WithLabelValues("failure").Inc()
+	case errors.Is(err, domain.ErrLoginThrottled):
+		s.metrics.logins.WithLabelValues("throttled").Inc()
+	}
+	return tokens, err
+}
+
+func (s *instrumentedUserService) LoginTwoFactor(ctx context.Context, mfaToken, code, clientIP string, config config.Container) (domain.AuthTokens, error) {
+	tokens, err := s.UserService.LoginTwoFactor(ctx, mfaToken, code, clientIP, config)
+	switch {
+	case err == nil:
+		s.metrics.logins.WithLabelValues("success").Inc()
+	case errors.Is(err, domain.ErrInvalidTwoFactorCode), errors.Is(err, domain.ErrInvalidMFAToken):
+		s.metrics.logins.WithLabelValues("failure").Inc()
+	case errors.Is(err, domain.ErrLoginThrottled):
+		s.metrics.logins.WithLabelValues("throttled").Inc()
+	}
+	return tokens, err
+}
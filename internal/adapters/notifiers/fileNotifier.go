@@ -0,0 +1,41 @@
+package notifiers
+
+import (
+	"context"
+	"fmt"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileNotifier appends messages to a file, a local stand-in for a mailbox.
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+	now  func() time.Time
+}
+
+func NewFileNotifier(path string) ports.Notifier {
+	return &FileNotifier{
+		path: path,
+		now:  time.Now,
+	}
+}
+
+func (n *FileNotifier) Notify(ctx context.Context, message domain.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(file, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		n.now().Format(time.RFC1123Z), message.To, message.Subject, message.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,25 @@
+package notifiers
+
+import (
+	"context"
+	"log/slog"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+)
+
+// LogNotifier writes messages to the log instead of sending them. Messages
+// carry secrets such as reset tokens, so it is meant for local development.
+type LogNotifier struct{}
+
+func NewLogNotifier() ports.Notifier {
+	return LogNotifier{}
+}
+
+func (LogNotifier) Notify(ctx context.Context, message domain.Message) error {
+	slog.InfoContext(ctx, "notification",
+		"to", message.To,
+		"subject", message.Subject,
+		"body", message.Body,
+	)
+	return nil
+}
@@ -0,0 +1,24 @@
+package notifiers
+
+import (
+	"errors"
+	"fmt"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/core/ports"
+)
+
+// New returns the notifier selected by config.Driver.
+func New(config *config.Notifier) (ports.Notifier, error) {
+	switch config.Driver {
+	case "log":
+		return NewLogNotifier(), nil
+	case "file":
+		return NewFileNotifier(config.FilePath), nil
+	case "smtp":
+		return NewSMTPNotifier(config.SMTP)
+	case "":
+		return nil, errors.New("NOTIFIER is not set, want log, file or smtp")
+	default:
+		return nil, fmt.Errorf("unknown notifier %q, want log, file or smtp", config.Driver)
+	}
+}
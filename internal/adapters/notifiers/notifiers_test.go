@@ -0,0 +1,366 @@
+package notifiers
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/textproto"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/core/domain"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var resetMessage = domain.Message{
+	To:      "test@gmail.com",
+	Subject: "Reset your password",
+	Body:    "Use this token:\n\nabc123\n",
+}
+
+func TestNew(t *testing.T) {
+	for _, driver := range []string{"log", "file"} {
+		notifier, err := New(&config.Notifier{Driver: driver, FilePath: "notifications.log", SMTP: &config.SMTP{}})
+		assert.NoError(t, err, driver)
+		assert.NotNil(t, notifier, driver)
+	}
+
+	_, err := New(&config.Notifier{Driver: "smtp", SMTP: &config.SMTP{}})
+	assert.Error(t, err, "smtp needs a host and a sender")
+	_, err = New(&config.Notifier{Driver: "pigeon"})
+	assert.Error(t, err)
+	_, err = New(&config.Notifier{SMTP: &config.SMTP{}})
+	assert.Error(t, err, "the notifier has no default")
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.log")
+	notifier := NewFileNotifier(path).(*FileNotifier)
+	notifier.now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage))
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	entry := "Date: Wed, 01 Jan 2025 00:00:00 +0000\nTo: test@gmail.com\nSubject: Reset your password\n\nUse this token:\n\nabc123\n\n\n"
+	assert.Equal(t, entry+entry, string(content))
+}
+
+// fakeSMTPServer accepts one delivery and records the conversation.
+type fakeSMTPServer struct {
+	address  string
+	auth     bool
+	received chan fakeDelivery
+}
+
+type fakeDelivery struct {
+	Auth string
+	From string
+	To   string
+	Data string
+}
+
+func newFakeSMTPServer(t *testing.T, auth bool) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeSMTPServer{address: listener.Addr().String(), auth: auth, received: make(chan fakeDelivery, 1)}
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		server.serve(textproto.NewConn(conn))
+	}()
+	return server
+}
+
+func (s *fakeSMTPServer) serve(conn *textproto.Conn) {
+	var delivery fakeDelivery
+	_ = conn.PrintfLine("220 localhost ESMTP fake")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO":
+			if s.auth {
+				_ = conn.PrintfLine("250-localhost")
+				_ = conn.PrintfLine("250 AUTH PLAIN")
+			} else {
+				_ = conn.PrintfLine("250 localhost")
+			}
+		case "AUTH":
+			delivery.Auth = argument
+			_ = conn.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			delivery.From = argument
+			_ = conn.PrintfLine("250 OK")
+		case "RCPT":
+			delivery.To = argument
+			_ = conn.PrintfLine("250 OK")
+		case "DATA":
+			_ = conn.PrintfLine("354 Go ahead")
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			delivery.Data = string(data)
+			_ = conn.PrintfLine("250 OK")
+		case "QUIT":
+			_ = conn.PrintfLine("221 Bye")
+			s.received <- delivery
+			return
+		default:
+			_ = conn.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func smtpConfig(t *testing.T, address string) *config.SMTP {
+	host, port, err := net.SplitHostPort(address)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	// The fake server doesn't speak TLS.
+	return &config.SMTP{Host: host, Port: portNumber, From: "no-reply@example.com"}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	server := newFakeSMTPServer(t, false)
+	notifier, err := NewSMTPNotifier(smtpConfig(t, server.address))
+	require.NoError(t, err)
+
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage))
+
+	delivery := <-server.received
+	assert.Empty(t, delivery.Auth)
+	assert.Equal(t, "FROM:<no-reply@example.com>", delivery.From)
+	assert.Equal(t, "TO:<test@gmail.com>", delivery.To)
+
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(delivery.Data)))
+	header, err := reader.ReadMIMEHeader()
+	require.NoError(t, err)
+	assert.Equal(t, "no-reply@example.com", header.Get("From"))
+	assert.Equal(t, "test@gmail.com", header.Get("To"))
+	assert.Equal(t, "Reset your password", header.Get("Subject"))
+	assert.Equal(t, "text/plain; charset=utf-8", header.Get("Content-Type"))
+	assert.True(t, strings.HasSuffix(header.Get("Message-ID"), "@example.com>"))
+	// ReadDotBytes turned the CRLF line endings into LF.
+	_, body, _ := strings.Cut(delivery.Data, "\n\n")
+	assert.Equal(t, "Use this token:\n\nabc123\n", body)
+}
+
+func TestSMTPNotifierAuthenticates(t *testing.T) {
+	server := newFakeSMTPServer(t, true)
+	smtpConfig := smtpConfig(t, server.address)
+	smtpConfig.Username = "mailer"
+	smtpConfig.Password = "secret"
+	notifier, err := NewSMTPNotifier(smtpConfig)
+	require.NoError(t, err)
+
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage))
+
+	delivery := <-server.received
+	assert.Equal(t, "PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00mailer\x00secret")), delivery.Auth)
+}
+
+func TestSMTPNotifierRequiresTLS(t *testing.T) {
+	server := newFakeSMTPServer(t, true)
+	smtpConfig := smtpConfig(t, server.address)
+	smtpConfig.Username = "mailer"
+	smtpConfig.Password = "secret"
+	smtpConfig.RequireTLS = true
+	notifier, err := NewSMTPNotifier(smtpConfig)
+	require.NoError(t, err)
+
+	assert.Error(t, notifier.Notify(context.Background(), resetMessage))
+	select {
+	case delivery := <-server.received:
+		t.Fatalf("delivered without TLS: %+v", delivery)
+	default:
+	}
+}
+
+func TestSMTPNotifierKeepsHeadersOnOneLine(t *testing.T) {
+	notifier, err := NewSMTPNotifier(&config.SMTP{Host: "localhost", Port: 25, From: "no-reply@example.com"})
+	require.NoError(t, err)
+
+	email := string(notifier.(*SMTPNotifier).format(domain.Message{
+		To:      "test@gmail.com\r\nBcc: victim@gmail.com",
+		Subject: "hi",
+		Body:    "line 1\nline 2",
+	}))
+
+	assert.Contains(t, email, "To: test@gmail.comBcc: victim@gmail.com\r\n")
+	assert.NotContains(t, email, "\nBcc:")
+	assert.True(t, strings.HasSuffix(email, "\r\n\r\nline 1\r\nline 2"))
+}
+
+func TestSMTPNotifierFailsWithoutServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	notifier, err := NewSMTPNotifier(smtpConfig(t, address))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Error(t, notifier.Notify(ctx, resetMessage))
+}
+
+func TestSMTPNotifierStopsWhenCancelled(t *testing.T) {
+	// The server accepts connections but never greets.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	notifier, err := NewSMTPNotifier(smtpConfig(t, listener.Addr().String()))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	assert.Error(t, notifier.Notify(ctx, resetMessage))
+	assert.Less(t, time.Since(start), 5*time.Second, "cancelling doesn't wait for the SMTP timeout")
+}
+
+// recordingNotifier records the messages it is asked to deliver, unless ctx
+// is already cancelled.
+type recordingNotifier struct {
+	delivered chan domain.Message
+	err       error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, message domain.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	n.delivered <- message
+	return n.err
+}
+
+func TestQueueNotifier(t *testing.T) {
+	next := &recordingNotifier{delivered: make(chan domain.Message, 10)}
+	notifier := NewQueueNotifier(next, 2, time.Second)
+
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage))
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage))
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage), "a full queue drops the message without failing")
+	assert.Empty(t, next.delivered, "nothing is delivered before Run")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	notifier.Run(ctx)
+	assert.Len(t, next.delivered, 2, "queued messages are delivered before Run returns")
+}
+
+func TestQueueNotifierDeliversInBackground(t *testing.T) {
+	next := &recordingNotifier{delivered: make(chan domain.Message, 10), err: errors.New("smtp: connection refused")}
+	notifier := NewQueueNotifier(next, 10, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(ctx)
+		close(done)
+	}()
+
+	requestCtx, cancelRequest := context.WithCancel(context.Background())
+	require.NoError(t, notifier.Notify(requestCtx, resetMessage))
+	cancelRequest()
+	select {
+	case message := <-next.delivered:
+		assert.Equal(t, resetMessage, message, "delivery outlives the request")
+	case <-time.After(time.Second):
+		t.Fatal("the message wasn't delivered")
+	}
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage), "failed deliveries are only logged")
+	<-next.delivered
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run kept running after its context was cancelled")
+	}
+}
+
+func TestQueueNotifierDeliversAfterServerStops(t *testing.T) {
+	next := &recordingNotifier{delivered: make(chan domain.Message, 10)}
+	notifier := NewQueueNotifier(next, 10, time.Second)
+	runCtx, stopRun := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(runCtx)
+		close(done)
+	}()
+
+	// Requests keep coming in while the server drains its connections.
+	serverCtx, stopServer := context.WithCancel(context.Background())
+	stopServer()
+	require.NoError(t, notifier.Notify(serverCtx, resetMessage))
+	select {
+	case message := <-next.delivered:
+		assert.Equal(t, resetMessage, message)
+	case <-time.After(time.Second):
+		t.Fatal("the message wasn't delivered")
+	}
+
+	stopRun()
+	<-done
+}
+
+// blockingNotifier never finishes a delivery before ctx is done.
+type blockingNotifier struct {
+	attempts chan domain.Message
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, message domain.Message) error {
+	n.attempts <- message
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestQueueNotifierBoundsDrain(t *testing.T) {
+	next := &blockingNotifier{attempts: make(chan domain.Message, 10)}
+	notifier := NewQueueNotifier(next, 10, 50*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		notifier.Run(ctx)
+		close(done)
+	}()
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage))
+	<-next.attempts
+	require.NoError(t, notifier.Notify(context.Background(), resetMessage))
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run waited for deliveries past the drain timeout")
+	}
+	assert.Len(t, next.attempts, 1, "the message still queued is tried with its delivery already cancelled")
+}
@@ -0,0 +1,85 @@
+package notifiers
+
+import (
+	"context"
+	"log/slog"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"time"
+)
+
+// QueueNotifier hands messages to a background worker, see Run. Callers don't
+// wait for the delivery, so answering a request that sends a message takes as
+// long as answering one that doesn't.
+type QueueNotifier struct {
+	next         ports.Notifier
+	queue        chan queuedMessage
+	drainTimeout time.Duration
+}
+
+type queuedMessage struct {
+	ctx     context.Context
+	message domain.Message
+}
+
+// NewQueueNotifier delivers messages through next, keeping up to size of them
+// waiting. Once Run is stopped, deliveries get drainTimeout to finish.
+func NewQueueNotifier(next ports.Notifier, size int, drainTimeout time.Duration) *QueueNotifier {
+	return &QueueNotifier{
+		next:         next,
+		queue:        make(chan queuedMessage, size),
+		drainTimeout: drainTimeout,
+	}
+}
+
+// Notify queues message. When the queue is full the message is dropped and
+// logged rather than failing, so the caller's answer stays the same.
+func (n *QueueNotifier) Notify(ctx context.Context, message domain.Message) error {
+	select {
+	case n.queue <- queuedMessage{ctx: context.WithoutCancel(ctx), message: message}:
+	default:
+		slog.WarnContext(ctx, "notification queue full, message dropped", "subject", message.Subject)
+	}
+	return nil
+}
+
+// Run delivers queued messages until ctx is done, then delivers the ones still
+// waiting before it returns. Deliveries still going on the drain timeout after
+// ctx is done are cancelled, so Run returns in time.
+func (n *QueueNotifier) Run(ctx context.Context) {
+	deliveries, cancelDeliveries := context.WithCancel(context.Background())
+	defer cancelDeliveries()
+	stopDrainTimer := context.AfterFunc(ctx, func() {
+		time.AfterFunc(n.drainTimeout, cancelDeliveries)
+	})
+	defer stopDrainTimer()
+
+	for {
+		select {
+		case queued := <-n.queue:
+			n.deliver(deliveries, queued)
+		case <-ctx.Done():
+			for {
+				select {
+				case queued := <-n.queue:
+					n.deliver(deliveries, queued)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver sends queued with the values of the request that queued it, but
+// cancelled along with deliveries.
+func (n *QueueNotifier) deliver(deliveries context.Context, queued queuedMessage) {
+	ctx, cancel := context.WithCancel(queued.ctx)
+	defer cancel()
+	stop := context.AfterFunc(deliveries, cancel)
+	defer stop()
+
+	if err := n.next.Notify(ctx, queued.message); err != nil {
+		slog.WarnContext(ctx, "delivering notification failed", "subject", queued.message.Subject, "error", err)
+	}
+}
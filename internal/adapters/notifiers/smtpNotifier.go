@@ -0,0 +1,143 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// smtpTimeout bounds a delivery when the context has no deadline.
+const smtpTimeout = 30 * time.Second
+
+// SMTPNotifier emails messages through an SMTP server, upgrading to TLS with
+// STARTTLS. Servers that don't offer it are refused unless TLS isn't required.
+type SMTPNotifier struct {
+	config    config.SMTP
+	tlsConfig *tls.Config
+	now       func() time.Time
+}
+
+func NewSMTPNotifier(config *config.SMTP) (ports.Notifier, error) {
+	if config.Host == "" || config.From == "" {
+		return nil, errors.New("smtp: SMTP_HOST and SMTP_FROM are required")
+	}
+	return &SMTPNotifier{
+		config:    *config,
+		tlsConfig: &tls.Config{ServerName: config.Host},
+		now:       time.Now,
+	}, nil
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, message domain.Message) error {
+	address := net.JoinHostPort(n.config.Host, strconv.Itoa(n.config.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = n.now().Add(smtpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	// net/smtp doesn't take a context, cancelling ctx cuts the connection short instead.
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer client.Close()
+	if err := n.send(client, message); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return nil
+}
+
+func (n *SMTPNotifier) send(client *smtp.Client, message domain.Message) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(n.tlsConfig); err != nil {
+			return err
+		}
+	} else if n.config.RequireTLS {
+		return errors.New("server doesn't offer STARTTLS, set SMTP_REQUIRE_TLS=false to send in plaintext")
+	}
+	if n.config.Username != "" {
+		// PlainAuth refuses to send the password unencrypted, except to localhost.
+		auth := smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(n.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return err
+	}
+	body, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := body.Write(n.format(message)); err != nil {
+		body.Close()
+		return err
+	}
+	if err := body.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// format renders message as a plain text email.
+func (n *SMTPNotifier) format(message domain.Message) []byte {
+	domainPart := n.config.Host
+	if at := strings.LastIndex(n.config.From, "@"); at >= 0 {
+		domainPart = n.config.From[at+1:]
+	}
+
+	var email bytes.Buffer
+	headers := [][2]string{
+		{"From", n.config.From},
+		{"To", message.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", n.now().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + uuid.NewString() + "@" + domainPart + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, header := range headers {
+		email.WriteString(header[0] + ": " + stripNewlines(header[1]) + "\r\n")
+	}
+	email.WriteString("\r\n")
+	body := strings.ReplaceAll(message.Body, "\r\n", "\n")
+	email.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return email.Bytes()
+}
+
+// stripNewlines keeps header values on one line, so they can't inject headers.
+func stripNewlines(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"sync"
+	"time"
+)
+
+type MemoryEmailVerificationTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]domain.EmailVerificationToken // by hash
+}
+
+func NewEmailVerificationTokenRepository() ports.EmailVerificationTokenRepository {
+	return &MemoryEmailVerificationTokenRepository{
+		tokens: map[string]domain.EmailVerificationToken{},
+	}
+}
+
+func (r *MemoryEmailVerificationTokenRepository) Save(ctx context.Context, token domain.EmailVerificationToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *MemoryEmailVerificationTokenRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (*domain.EmailVerificationToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, domain.ErrEmailVerificationTokenNotFound
+	}
+	delete(r.tokens, tokenHash)
+	if !now.Before(token.ExpiresAt) {
+		return nil, domain.ErrEmailVerificationTokenNotFound
+	}
+	return &token, nil
+}
+
+func (r *MemoryEmailVerificationTokenRepository) DeleteUserTokens(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, token := range r.tokens {
+		if token.UserID == userID {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
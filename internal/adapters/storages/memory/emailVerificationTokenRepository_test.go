@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailVerificationTokenRepository(t *testing.T) {
+	repo := NewEmailVerificationTokenRepository()
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, token := range []domain.EmailVerificationToken{
+		{ID: "1", UserID: "user-1", Email: "test@gmail.com", TokenHash: "hash-1", ExpiresAt: now.Add(time.Hour)},
+		{ID: "2", UserID: "user-1", Email: "test@gmail.com", TokenHash: "hash-2", ExpiresAt: now.Add(time.Hour)},
+		{ID: "3", UserID: "user-2", Email: "test@gmail.com", TokenHash: "hash-3", ExpiresAt: now},
+	} {
+		require.NoError(t, repo.Save(ctx, token))
+	}
+
+	token, err := repo.Consume(ctx, "hash-1", now)
+	require.NoError(t, err)
+	assert.Equal(t, "1", token.ID)
+	_, err = repo.Consume(ctx, "hash-1", now)
+	assert.ErrorIs(t, err, domain.ErrEmailVerificationTokenNotFound, "tokens work once")
+
+	_, err = repo.Consume(ctx, "hash-3", now)
+	assert.ErrorIs(t, err, domain.ErrEmailVerificationTokenNotFound, "expired")
+	_, err = repo.Consume(ctx, "unknown", now)
+	assert.ErrorIs(t, err, domain.ErrEmailVerificationTokenNotFound)
+
+	require.NoError(t, repo.DeleteUserTokens(ctx, "user-1"))
+	_, err = repo.Consume(ctx, "hash-2", now)
+	assert.ErrorIs(t, err, domain.ErrEmailVerificationTokenNotFound)
+}
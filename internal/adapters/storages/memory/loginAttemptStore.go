@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"sync"
+	"time"
+)
+
+type loginAttemptsEntry struct {
+	domain.LoginAttempts
+	// forgetAt is when the policy forgets the failures.
+	forgetAt time.Time
+}
+
+// MemoryLoginAttemptStore keeps failed logins in process memory, so every
+// replica counts its own.
+type MemoryLoginAttemptStore struct {
+	mu        sync.Mutex
+	attempts  map[string]loginAttemptsEntry
+	lastPurge time.Time
+}
+
+// loginAttemptPurgeInterval bounds how often RecordFailure scans every entry.
+const loginAttemptPurgeInterval = time.Minute
+
+func NewLoginAttemptStore() ports.LoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		attempts: map[string]loginAttemptsEntry{},
+	}
+}
+
+func (s *MemoryLoginAttemptStore) Get(ctx context.Context, key string) (domain.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[key].LoginAttempts, nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordFailure(ctx context.Context, key string, policy domain.LockoutPolicy, now time.Time) (domain.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeForgotten(now)
+
+	attempts := policy.Fail(s.attempts[key].LoginAttempts, now)
+	s.attempts[key] = loginAttemptsEntry{LoginAttempts: attempts, forgetAt: now.Add(policy.LockDuration)}
+	return attempts, nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// purgeForgotten drops entries the policy no longer counts. Callers must hold mu.
+func (s *MemoryLoginAttemptStore) purgeForgotten(now time.Time) {
+	if now.Sub(s.lastPurge) < loginAttemptPurgeInterval {
+		return
+	}
+	s.lastPurge = now
+	for key, entry := range s.attempts {
+		if !now.Before(entry.forgetAt) {
+			delete(s.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginAttemptStore(t *testing.T) {
+	store := NewLoginAttemptStore().(*MemoryLoginAttemptStore)
+	ctx := context.Background()
+	policy := domain.LockoutPolicy{LockAfter: 3, LockDuration: time.Hour}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	attempts, err := store.Get(ctx, "account:test@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoginAttempts{}, attempts)
+
+	for i := 1; i <= 2; i++ {
+		attempts, err = store.RecordFailure(ctx, "account:test@gmail.com", policy, now)
+		require.NoError(t, err)
+		assert.Equal(t, domain.LoginAttempts{Failures: i, LastFailureAt: now}, attempts)
+	}
+	attempts, err = store.Get(ctx, "account:test@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts.Failures)
+
+	_, err = store.RecordFailure(ctx, "ip:1.2.3.4", policy, now)
+	require.NoError(t, err)
+	require.NoError(t, store.Reset(ctx, "account:test@gmail.com"))
+	attempts, err = store.Get(ctx, "account:test@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoginAttempts{}, attempts)
+
+	later := now.Add(2 * time.Hour)
+	attempts, err = store.RecordFailure(ctx, "account:other@gmail.com", policy, later)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+	assert.Len(t, store.attempts, 1, "forgotten failures are purged")
+}
+
+func TestLoginAttemptStoreConcurrentFailures(t *testing.T) {
+	store := NewLoginAttemptStore()
+	policy := domain.LockoutPolicy{LockAfter: 100, LockDuration: time.Hour}
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.RecordFailure(context.Background(), "ip:1.2.3.4", policy, now)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	attempts, err := store.Get(context.Background(), "ip:1.2.3.4")
+	require.NoError(t, err)
+	assert.Equal(t, 50, attempts.Failures)
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"sync"
+	"time"
+)
+
+type MemoryPasswordResetTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]domain.PasswordResetToken // by hash
+}
+
+func NewPasswordResetTokenRepository() ports.PasswordResetTokenRepository {
+	return &MemoryPasswordResetTokenRepository{
+		tokens: map[string]domain.PasswordResetToken{},
+	}
+}
+
+func (r *MemoryPasswordResetTokenRepository) Save(ctx context.Context, token domain.PasswordResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *MemoryPasswordResetTokenRepository) Get(ctx context.Context, tokenHash string, now time.Time) (*domain.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok || !now.Before(token.ExpiresAt) {
+		return nil, domain.ErrPasswordResetTokenNotFound
+	}
+	return &token, nil
+}
+
+func (r *MemoryPasswordResetTokenRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (*domain.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, domain.ErrPasswordResetTokenNotFound
+	}
+	delete(r.tokens, tokenHash)
+	if !now.Before(token.ExpiresAt) {
+		return nil, domain.ErrPasswordResetTokenNotFound
+	}
+	return &token, nil
+}
+
+func (r *MemoryPasswordResetTokenRepository) DeleteUserTokens(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, token := range r.tokens {
+		if token.UserID == userID {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
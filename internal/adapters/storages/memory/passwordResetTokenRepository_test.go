@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordResetTokenRepository(t *testing.T) {
+	repo := NewPasswordResetTokenRepository()
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, token := range []domain.PasswordResetToken{
+		{ID: "1", UserID: "user-1", TokenHash: "hash-1", ExpiresAt: now.Add(time.Hour)},
+		{ID: "2", UserID: "user-1", TokenHash: "hash-2", ExpiresAt: now.Add(time.Hour)},
+		{ID: "3", UserID: "user-2", TokenHash: "hash-3", ExpiresAt: now},
+	} {
+		require.NoError(t, repo.Save(ctx, token))
+	}
+
+	token, err := repo.Get(ctx, "hash-1", now)
+	require.NoError(t, err)
+	assert.Equal(t, "1", token.ID)
+	_, err = repo.Get(ctx, "hash-3", now)
+	assert.ErrorIs(t, err, domain.ErrPasswordResetTokenNotFound, "expired")
+
+	token, err = repo.Consume(ctx, "hash-1", now)
+	require.NoError(t, err, "getting doesn't use the token up")
+	assert.Equal(t, "1", token.ID)
+	_, err = repo.Consume(ctx, "hash-1", now)
+	assert.ErrorIs(t, err, domain.ErrPasswordResetTokenNotFound, "tokens work once")
+
+	_, err = repo.Consume(ctx, "hash-3", now)
+	assert.ErrorIs(t, err, domain.ErrPasswordResetTokenNotFound, "expired")
+	_, err = repo.Consume(ctx, "unknown", now)
+	assert.ErrorIs(t, err, domain.ErrPasswordResetTokenNotFound)
+
+	require.NoError(t, repo.DeleteUserTokens(ctx, "user-1"))
+	_, err = repo.Consume(ctx, "hash-2", now)
+	assert.ErrorIs(t, err, domain.ErrPasswordResetTokenNotFound)
+}
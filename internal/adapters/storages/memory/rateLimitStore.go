@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"sync"
+	"time"
+)
+
+type rateLimitBucket struct {
+	domain.TokenBucket
+	// fullAt is when the bucket is full again and can be forgotten.
+	fullAt time.Time
+}
+
+// MemoryRateLimitStore keeps buckets in process memory, so every replica
+// enforces its own limits.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]rateLimitBucket
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+// rateLimitPurgeInterval bounds how often Take scans every bucket, a busy
+// limiter holds one bucket per client.
+const rateLimitPurgeInterval = time.Minute
+
+func NewRateLimitStore() ports.RateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: map[string]rateLimitBucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.purgeFull(now)
+
+	bucket, result := limit.Take(s.buckets[key].TokenBucket, now)
+	s.buckets[key] = rateLimitBucket{TokenBucket: bucket, fullAt: now.Add(result.Reset)}
+	return result, nil
+}
+
+// purgeFull drops buckets that have refilled, a missing bucket is a full one.
+// Callers must hold mu.
+func (s *MemoryRateLimitStore) purgeFull(now time.Time) {
+	if now.Sub(s.lastPurge) < rateLimitPurgeInterval {
+		return
+	}
+	s.lastPurge = now
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
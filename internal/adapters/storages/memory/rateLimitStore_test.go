@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitStoreTake(t *testing.T) {
+	store := NewRateLimitStore().(*MemoryRateLimitStore)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+	limit := domain.RateLimit{Burst: 2, Period: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		result, err := store.Take(ctx, "ip:1.2.3.4", limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	}
+	result, err := store.Take(ctx, "ip:1.2.3.4", limit)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 30*time.Second, result.RetryAfter)
+
+	result, err = store.Take(ctx, "ip:5.6.7.8", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed, "keys have their own buckets")
+
+	now = now.Add(2 * time.Minute)
+	result, err = store.Take(ctx, "ip:1.2.3.4", limit)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Len(t, store.buckets, 1, "full buckets are purged")
+}
+
+func TestRateLimitStoreConcurrentTake(t *testing.T) {
+	store := NewRateLimitStore()
+	limit := domain.RateLimit{Burst: 5, Period: time.Hour}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.Take(context.Background(), "user:1", limit)
+			assert.NoError(t, err)
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, allowed)
+}
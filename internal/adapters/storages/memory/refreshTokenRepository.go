@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"sync"
+	"time"
+)
+
+type MemoryRefreshTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]domain.RefreshToken // by hash
+	hashes map[string]string              // token hash by id
+	now    func() time.Time
+}
+
+func NewRefreshTokenRepository() ports.RefreshTokenRepository {
+	return &MemoryRefreshTokenRepository{
+		tokens: map[string]domain.RefreshToken{},
+		hashes: map[string]string{},
+		now:    time.Now,
+	}
+}
+
+func (r *MemoryRefreshTokenRepository) Save(ctx context.Context, token domain.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.purgeExpired()
+	r.tokens[token.TokenHash] = token
+	r.hashes[token.ID] = token.TokenHash
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, domain.ErrRefreshTokenNotFound
+	}
+	return &token, nil
+}
+
+func (r *MemoryRefreshTokenRepository) Revoke(ctx context.Context, id string, replacedBy string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hash := r.hashes[id]
+	token, ok := r.tokens[hash]
+	if !ok || token.Revoked {
+		return false, nil
+	}
+	token.Revoked = true
+	token.ReplacedBy = replacedBy
+	r.tokens[hash] = token
+	return true, nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	r.revokeWhere(func(token domain.RefreshToken) bool { return token.FamilyID == familyID })
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeUser(ctx context.Context, userID string) error {
+	r.revokeWhere(func(token domain.RefreshToken) bool { return token.UserID == userID })
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) revokeWhere(match func(domain.RefreshToken) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, token := range r.tokens {
+		if match(token) {
+			token.Revoked = true
+			r.tokens[hash] = token
+		}
+	}
+}
+
+// purgeExpired drops tokens that can no longer be used, rotated or not, like
+// the TTL index does in Mongo. Callers must hold mu.
+func (r *MemoryRefreshTokenRepository) purgeExpired() {
+	now := r.now()
+	for hash, token := range r.tokens {
+		if !now.Before(token.ExpiresAt) {
+			delete(r.tokens, hash)
+			delete(r.hashes, token.ID)
+		}
+	}
+}
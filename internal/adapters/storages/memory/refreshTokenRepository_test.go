@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshTokenRepository(t *testing.T) {
+	repo := NewRefreshTokenRepository()
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	assert.NoError(t, repo.Save(ctx, domain.RefreshToken{ID: "1", UserID: "123", FamilyID: "1", TokenHash: "hash-1", ExpiresAt: expiresAt}))
+	assert.NoError(t, repo.Save(ctx, domain.RefreshToken{ID: "2", UserID: "123", FamilyID: "2", TokenHash: "hash-2", ExpiresAt: expiresAt}))
+
+	token, err := repo.GetByHash(ctx, "hash-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", token.ID)
+
+	_, err = repo.GetByHash(ctx, "unknown")
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenNotFound)
+
+	revoked, err := repo.Revoke(ctx, "1", "3")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = repo.Revoke(ctx, "1", "4")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	token, _ = repo.GetByHash(ctx, "hash-1")
+	assert.Equal(t, "3", token.ReplacedBy)
+
+	assert.NoError(t, repo.RevokeUser(ctx, "123"))
+	token, _ = repo.GetByHash(ctx, "hash-2")
+	assert.True(t, token.Revoked)
+}
+
+func TestRefreshTokenRepositoryPurgesExpired(t *testing.T) {
+	repo := NewRefreshTokenRepository().(*MemoryRefreshTokenRepository)
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+	assert.NoError(t, repo.Save(ctx, domain.RefreshToken{ID: "1", FamilyID: "1", TokenHash: "hash-1", ExpiresAt: now.Add(time.Hour)}))
+	revoked, err := repo.Revoke(ctx, "1", "2")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	now = now.Add(2 * time.Hour)
+	assert.NoError(t, repo.Save(ctx, domain.RefreshToken{ID: "2", FamilyID: "1", TokenHash: "hash-2", ExpiresAt: now.Add(time.Hour)}))
+	_, err = repo.GetByHash(ctx, "hash-1")
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenNotFound, "expired tokens are purged, rotated ones too")
+	assert.Len(t, repo.tokens, 1)
+	assert.Len(t, repo.hashes, 1)
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"one1-be-chal/internal/core/ports"
+	"sync"
+	"time"
+)
+
+type userRevocation struct {
+	revokedAt time.Time
+	expiresAt time.Time
+}
+
+type MemoryTokenRevocationStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[string]userRevocation
+	now    func() time.Time
+}
+
+func NewTokenRevocationStore() ports.TokenRevocationStore {
+	return &MemoryTokenRevocationStore{
+		tokens: map[string]time.Time{},
+		users:  map[string]userRevocation{},
+		now:    time.Now,
+	}
+}
+
+func (s *MemoryTokenRevocationStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpired()
+	s.tokens[tokenID] = expiresAt
+	return nil
+}
+
+func (s *MemoryTokenRevocationStore) RevokeUserTokens(
+	ctx context.Context,
+	userID string,
+	revokedAt time.Time,
+	expiresAt time.Time,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpired()
+	s.users[userID] = userRevocation{revokedAt: revokedAt, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryTokenRevocationStore) IsRevoked(
+	ctx context.Context,
+	tokenID string,
+	userID string,
+	issuedAt time.Time,
+) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := s.now()
+	if expiresAt, ok := s.tokens[tokenID]; ok && now.Before(expiresAt) {
+		return true, nil
+	}
+	if revocation, ok := s.users[userID]; ok && now.Before(revocation.expiresAt) {
+		return issuedAt.Before(revocation.revokedAt), nil
+	}
+	return false, nil
+}
+
+// purgeExpired drops entries whose tokens have expired on their own. Callers must hold mu.
+func (s *MemoryTokenRevocationStore) purgeExpired() {
+	now := s.now()
+	for tokenID, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, tokenID)
+		}
+	}
+	for userID, revocation := range s.users {
+		if !now.Before(revocation.expiresAt) {
+			delete(s.users, userID)
+		}
+	}
+}
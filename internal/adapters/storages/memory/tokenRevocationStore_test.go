@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevokeToken(t *testing.T) {
+	store := NewTokenRevocationStore()
+	ctx := context.Background()
+	issuedAt := time.Now()
+
+	revoked, err := store.IsRevoked(ctx, "jti-1", "123", issuedAt)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, store.RevokeToken(ctx, "jti-1", time.Now().Add(time.Hour)))
+
+	revoked, err = store.IsRevoked(ctx, "jti-1", "123", issuedAt)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, "jti-2", "123", issuedAt)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	store := NewTokenRevocationStore()
+	ctx := context.Background()
+	revokedAt := time.Now()
+
+	assert.NoError(t, store.RevokeUserTokens(ctx, "123", revokedAt, revokedAt.Add(time.Hour)))
+
+	tests := []struct {
+		Name     string
+		UserID   string
+		IssuedAt time.Time
+		Revoked  bool
+	}{
+		{Name: "issued before revocation", UserID: "123", IssuedAt: revokedAt.Add(-time.Minute), Revoked: true},
+		{Name: "issued after revocation", UserID: "123", IssuedAt: revokedAt.Add(time.Minute), Revoked: false},
+		{Name: "other user", UserID: "456", IssuedAt: revokedAt.Add(-time.Minute), Revoked: false},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			revoked, err := store.IsRevoked(ctx, "jti", test.UserID, test.IssuedAt)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Revoked, revoked)
+		})
+	}
+}
+
+func TestRevocationExpires(t *testing.T) {
+	store := NewTokenRevocationStore().(*MemoryTokenRevocationStore)
+	ctx := context.Background()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	assert.NoError(t, store.RevokeToken(ctx, "jti-1", now.Add(time.Minute)))
+	assert.NoError(t, store.RevokeUserTokens(ctx, "123", now, now.Add(time.Minute)))
+
+	now = now.Add(2 * time.Minute)
+	revoked, err := store.IsRevoked(ctx, "jti-1", "123", now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, store.RevokeToken(ctx, "jti-2", now.Add(time.Minute)))
+	assert.Len(t, store.tokens, 1)
+	assert.Len(t, store.users, 0)
+}
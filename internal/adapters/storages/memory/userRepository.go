@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// MemoryUserRepository keeps users in process memory, for local development
+// and tests. It enforces the same email uniqueness and not-found errors as the
+// Mongo repository.
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]domain.User
+	order []string
+}
+
+func NewUserRepository() ports.UserRepository {
+	return &MemoryUserRepository{
+		users: map[string]domain.User{},
+	}
+}
+
+func (r *MemoryUserRepository) Save(ctx context.Context, user domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[user.ID]; ok {
+		return errors.New("user id already exist")
+	}
+	if r.findByEmail(user.Email) != nil {
+		return domain.ErrEmailTaken
+	}
+	r.users[user.ID] = user
+	r.order = append(r.order, user.ID)
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUserByID(ctx context.Context, id string) (domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[id]
+	if !ok {
+		return domain.User{}, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *MemoryUserRepository) GetAllUsers(ctx context.Context, query domain.UserQuery) ([]domain.User, error) {
+	r.mu.RLock()
+	users := make([]domain.User, 0, len(r.order))
+	for _, id := range r.order {
+		if user := r.users[id]; query.Matches(user) {
+			users = append(users, user)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return query.Less(users[i], users[j])
+	})
+	if query.Limit > 0 && len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) UpdateUser(ctx context.Context, id string, patch domain.UserPatch) error {
+	if patch.IsEmpty() {
+		return domain.NewValidationError("name and email cannot be empty")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok || !matchesExpected(user, patch) {
+		return domain.ErrUserNotFound
+	}
+	if patch.Email != nil {
+		if existing := r.findByEmail(*patch.Email); existing != nil && existing.ID != id {
+			return domain.ErrEmailTaken
+		}
+		user.Email = *patch.Email
+	}
+	if patch.Name != nil {
+		user.Name = *patch.Name
+	}
+	if patch.Password != nil {
+		user.Password = *patch.Password
+	}
+	if patch.PasswordChangedAt != nil {
+		user.PasswordChangedAt = *patch.PasswordChangedAt
+	}
+	if patch.EmailVerified != nil {
+		user.EmailVerified = *patch.EmailVerified
+	}
+	if patch.TwoFactorEnabled != nil {
+		user.TwoFactorEnabled = *patch.TwoFactorEnabled
+	}
+	if patch.TOTPSecret != nil {
+		user.TOTPSecret = *patch.TOTPSecret
+	}
+	if patch.TOTPLastStep != nil {
+		user.TOTPLastStep = *patch.TOTPLastStep
+	}
+	if patch.RecoveryCodes != nil {
+		user.RecoveryCodes = append([]string(nil), *patch.RecoveryCodes...)
+	}
+	r.users[id] = user
+	return nil
+}
+
+// matchesExpected reports whether user still has the values a conditional
+// patch expects.
+func matchesExpected(user domain.User, patch domain.UserPatch) bool {
+	if patch.ExpectedPassword != nil && user.Password != *patch.ExpectedPassword {
+		return false
+	}
+	if patch.ExpectedTOTPLastStep != nil && user.TOTPLastStep != *patch.ExpectedTOTPLastStep {
+		return false
+	}
+	if patch.ExpectedRecoveryCodes != nil && !slices.Equal(user.RecoveryCodes, *patch.ExpectedRecoveryCodes) {
+		return false
+	}
+	return true
+}
+
+func (r *MemoryUserRepository) DeleteUser(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return domain.ErrUserNotFound
+	}
+	delete(r.users, id)
+	for i, orderedID := range r.order {
+		if orderedID == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user := r.findByEmail(email)
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *MemoryUserRepository) GetUserCount(ctx context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return int64(len(r.users)), nil
+}
+
+// findByEmail returns a copy of the user with the email. Callers must hold mu.
+func (r *MemoryUserRepository) findByEmail(email string) *domain.User {
+	normalized := domain.NormalizeEmail(email)
+	for _, user := range r.users {
+		if domain.NormalizeEmail(user.Email) == normalized {
+			return &user
+		}
+	}
+	return nil
+}
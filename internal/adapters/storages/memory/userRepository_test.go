@@ -0,0 +1,206 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"one1-be-chal/internal/core/domain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserRepositorySaveAndGet(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+	user := domain.User{ID: "123", Name: "One1 yean", Email: "test@gmail.com"}
+
+	assert.NoError(t, repo.Save(ctx, user))
+
+	byID, err := repo.GetUserByID(ctx, "123")
+	assert.NoError(t, err)
+	assert.Equal(t, user, byID)
+
+	byEmail, err := repo.GetUserByEmail(ctx, "test@gmail.com")
+	assert.NoError(t, err)
+	assert.Equal(t, user, *byEmail)
+
+	count, err := repo.GetUserCount(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestUserRepositoryNotFound(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+	name := "One1"
+
+	_, err := repo.GetUserByID(ctx, "404")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+
+	user, err := repo.GetUserByEmail(ctx, "unknown@gmail.com")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	assert.Nil(t, user)
+
+	assert.ErrorIs(t, repo.UpdateUser(ctx, "404", domain.UserPatch{Name: &name}), domain.ErrUserNotFound)
+	assert.ErrorIs(t, repo.DeleteUser(ctx, "404"), domain.ErrUserNotFound)
+}
+
+func TestUserRepositoryEmailUniqueness(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+	assert.NoError(t, repo.Save(ctx, domain.User{ID: "1", Email: "first@gmail.com"}))
+	assert.NoError(t, repo.Save(ctx, domain.User{ID: "2", Email: "second@gmail.com"}))
+
+	err := repo.Save(ctx, domain.User{ID: "3", Email: "first@gmail.com"})
+	assert.ErrorIs(t, err, domain.ErrEmailTaken)
+
+	taken := "first@gmail.com"
+	err = repo.UpdateUser(ctx, "2", domain.UserPatch{Email: &taken})
+	assert.ErrorIs(t, err, domain.ErrEmailTaken)
+
+	err = repo.UpdateUser(ctx, "1", domain.UserPatch{Email: &taken})
+	assert.NoError(t, err)
+
+	err = repo.Save(ctx, domain.User{ID: "4", Email: " First@Gmail.com"})
+	assert.ErrorIs(t, err, domain.ErrEmailTaken)
+	found, err := repo.GetUserByEmail(ctx, "FIRST@gmail.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", found.ID)
+}
+
+func TestUserRepositoryUpdateAndDelete(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+	for _, id := range []string{"1", "2", "3"} {
+		assert.NoError(t, repo.Save(ctx, domain.User{ID: id, Name: "user " + id, Email: id + "@gmail.com"}))
+	}
+
+	name := "renamed"
+	assert.NoError(t, repo.UpdateUser(ctx, "2", domain.UserPatch{Name: &name}))
+	user, _ := repo.GetUserByID(ctx, "2")
+	assert.Equal(t, "renamed", user.Name)
+	assert.Equal(t, "2@gmail.com", user.Email)
+
+	hash := "new hash"
+	changedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, repo.UpdateUser(ctx, "2", domain.UserPatch{Password: &hash, PasswordChangedAt: &changedAt}))
+	user, _ = repo.GetUserByID(ctx, "2")
+	assert.Equal(t, "new hash", user.Password)
+	assert.Equal(t, changedAt, user.PasswordChangedAt)
+	assert.Equal(t, "renamed", user.Name)
+
+	stale, rehashed := "old hash", "rehashed"
+	err := repo.UpdateUser(ctx, "2", domain.UserPatch{Password: &rehashed, ExpectedPassword: &stale})
+	assert.ErrorIs(t, err, domain.ErrUserNotFound, "the password changed in between")
+	assert.NoError(t, repo.UpdateUser(ctx, "2", domain.UserPatch{Password: &rehashed, ExpectedPassword: &hash}))
+	user, _ = repo.GetUserByID(ctx, "2")
+	assert.Equal(t, "rehashed", user.Password)
+
+	verified := true
+	assert.NoError(t, repo.UpdateUser(ctx, "2", domain.UserPatch{EmailVerified: &verified}))
+	user, _ = repo.GetUserByID(ctx, "2")
+	assert.True(t, user.EmailVerified)
+
+	enabled, secret, step, codes := true, "SECRET", int64(42), []string{"first", "second"}
+	assert.NoError(t, repo.UpdateUser(ctx, "2", domain.UserPatch{TwoFactorEnabled: &enabled, TOTPSecret: &secret, TOTPLastStep: &step, RecoveryCodes: &codes}))
+	codes[0] = "changed"
+	user, _ = repo.GetUserByID(ctx, "2")
+	assert.True(t, user.TwoFactorEnabled)
+	assert.Equal(t, "SECRET", user.TOTPSecret)
+	assert.Equal(t, int64(42), user.TOTPLastStep)
+	assert.Equal(t, []string{"first", "second"}, user.RecoveryCodes, "the repository keeps its own copy")
+
+	staleStep, nextStep := int64(41), int64(43)
+	err = repo.UpdateUser(ctx, "2", domain.UserPatch{TOTPLastStep: &nextStep, ExpectedTOTPLastStep: &staleStep})
+	assert.ErrorIs(t, err, domain.ErrUserNotFound, "another code was used in between")
+	assert.NoError(t, repo.UpdateUser(ctx, "2", domain.UserPatch{TOTPLastStep: &nextStep, ExpectedTOTPLastStep: &step}))
+	staleCodes, remaining := []string{"first"}, []string{"second"}
+	err = repo.UpdateUser(ctx, "2", domain.UserPatch{RecoveryCodes: &remaining, ExpectedRecoveryCodes: &staleCodes})
+	assert.ErrorIs(t, err, domain.ErrUserNotFound, "another recovery code was used in between")
+	assert.NoError(t, repo.UpdateUser(ctx, "2", domain.UserPatch{RecoveryCodes: &remaining, ExpectedRecoveryCodes: &[]string{"first", "second"}}))
+	user, _ = repo.GetUserByID(ctx, "2")
+	assert.Equal(t, int64(43), user.TOTPLastStep)
+	assert.Equal(t, []string{"second"}, user.RecoveryCodes)
+
+	err = repo.UpdateUser(ctx, "2", domain.UserPatch{})
+	assert.ErrorIs(t, err, domain.ErrValidation)
+
+	assert.NoError(t, repo.DeleteUser(ctx, "2"))
+	users, err := repo.GetAllUsers(ctx, domain.UserQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "3"}, []string{users[0].ID, users[1].ID})
+}
+
+func TestUserRepositoryConcurrentSave(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.Save(ctx, domain.User{ID: fmt.Sprint(i), Email: "same@gmail.com"})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	saved := 0
+	for err := range errs {
+		if err == nil {
+			saved++
+		} else {
+			assert.ErrorIs(t, err, domain.ErrEmailTaken)
+		}
+	}
+	assert.Equal(t, 1, saved)
+}
+
+func TestUserRepositoryGetAllUsersQuery(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+	base := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	seed := []domain.User{
+		{ID: "1", Name: "alice", Email: "alice@gmail.com", CreatedAt: base},
+		{ID: "2", Name: "bob", Email: "bob@company.com", CreatedAt: base.Add(time.Hour)},
+		{ID: "3", Name: "alan", Email: "alan@Company.com", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: "4", Name: "carol", Email: "carol@gmail.com", CreatedAt: base.Add(3 * time.Hour)},
+	}
+	for _, user := range seed {
+		assert.NoError(t, repo.Save(ctx, user))
+	}
+
+	ids := func(users []domain.User) []string {
+		result := []string{}
+		for _, user := range users {
+			result = append(result, user.ID)
+		}
+		return result
+	}
+
+	tests := []struct {
+		Name     string
+		Query    domain.UserQuery
+		Expected []string
+	}{
+		{Name: "created_at ascending", Query: domain.UserQuery{SortBy: domain.SortByCreatedAt}, Expected: []string{"1", "2", "3", "4"}},
+		{Name: "created_at descending", Query: domain.UserQuery{SortBy: domain.SortByCreatedAt, Descending: true}, Expected: []string{"4", "3", "2", "1"}},
+		{Name: "name ascending", Query: domain.UserQuery{SortBy: domain.SortByName}, Expected: []string{"3", "1", "2", "4"}},
+		{Name: "limit", Query: domain.UserQuery{SortBy: domain.SortByName, Limit: 2}, Expected: []string{"3", "1"}},
+		{Name: "name prefix", Query: domain.UserQuery{SortBy: domain.SortByName, NamePrefix: "al"}, Expected: []string{"3", "1"}},
+		{Name: "email domain", Query: domain.UserQuery{SortBy: domain.SortByCreatedAt, EmailDomain: "company.com"}, Expected: []string{"2", "3"}},
+		{Name: "created range", Query: domain.UserQuery{SortBy: domain.SortByCreatedAt, CreatedFrom: base.Add(time.Hour), CreatedTo: base.Add(3 * time.Hour)}, Expected: []string{"2", "3"}},
+		{Name: "after cursor", Query: domain.UserQuery{SortBy: domain.SortByName, After: &domain.UserCursor{SortBy: domain.SortByName, Name: "alice", ID: "1"}}, Expected: []string{"2", "4"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			users, err := repo.GetAllUsers(ctx, test.Query)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Expected, ids(users))
+		})
+	}
+}
@@ -2,11 +2,12 @@ package mongo
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"one1-be-chal/internal/adapters/config"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type DB struct {
@@ -19,17 +20,17 @@ func New(ctx context.Context, config *config.UserDB) (*DB, error) {
 
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
-		log.Println("Error connecting to MongoDB")
+		slog.ErrorContext(ctx, "connecting to MongoDB", "error", err)
 		return nil, err
 	}
 
 	err = client.Ping(ctx, nil)
 	if err != nil {
-		log.Println("Error pinging MongoDB")
+		slog.ErrorContext(ctx, "pinging MongoDB", "error", err)
 		return nil, err
 	}
 
-	log.Println("Successfully connected to MongoDB")
+	slog.InfoContext(ctx, "connected to MongoDB")
 
 	return &DB{
 		Client: client,
@@ -40,9 +41,18 @@ func New(ctx context.Context, config *config.UserDB) (*DB, error) {
 func (db *DB) Close(ctx context.Context) error {
 	err := db.Client.Disconnect(ctx)
 	if err != nil {
-		log.Println("Error disconnecting from MongoDB")
+		slog.ErrorContext(ctx, "disconnecting from MongoDB", "error", err)
 		return err
 	}
-	log.Println("Disconnected from MongoDB")
+	slog.InfoContext(ctx, "disconnected from MongoDB")
 	return nil
 }
+
+func (db *DB) Name() string {
+	return "mongo"
+}
+
+// Check pings the primary, bounded by ctx, for the readiness probe.
+func (db *DB) Check(ctx context.Context) error {
+	return db.Client.Ping(ctx, readpref.Primary())
+}
@@ -0,0 +1,22 @@
+package mongo
+
+import (
+	"context"
+	"one1-be-chal/internal/adapters/storages/mongo/mongotest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	db := &DB{Client: mongotest.Database(t).Client()}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Equal(t, "mongo", db.Name())
+	assert.NoError(t, db.Check(ctx))
+
+	cancel()
+	assert.Error(t, db.Check(ctx))
+}
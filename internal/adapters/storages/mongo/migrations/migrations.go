@@ -0,0 +1,266 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"one1-be-chal/internal/adapters/storages/mongo/repositories"
+	"one1-be-chal/internal/core/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All is the schema history of the database. Append new migrations with the
+// next version, never edit or renumber ones that shipped.
+func All() []Migration {
+	return []Migration{
+		{
+			Version:     1,
+			Description: "backfill role on users",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.UsersCollection).UpdateMany(
+					ctx,
+					bson.M{"role": bson.M{"$in": bson.A{nil, ""}}},
+					bson.M{"$set": bson.M{"role": domain.RoleUser}},
+				)
+				return err
+			},
+			// Users without a role are read as RoleUser, so keeping the field is harmless.
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return nil
+			},
+		},
+		{
+			Version:     2,
+			Description: "backfill email_normalized on users",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.UsersCollection).UpdateMany(
+					ctx,
+					bson.M{"email_normalized": bson.M{"$exists": false}},
+					mongo.Pipeline{{{Key: "$set", Value: bson.M{
+						"email_normalized": bson.M{"$toLower": bson.M{"$trim": bson.M{"input": "$email"}}},
+					}}}},
+				)
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.UsersCollection).UpdateMany(
+					ctx,
+					bson.M{},
+					bson.M{"$unset": bson.M{"email_normalized": ""}},
+				)
+				return err
+			},
+		},
+		{
+			Version:     3,
+			Description: "unique indexes on users id and email_normalized",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.UsersCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "id", Value: 1}},
+						Options: options.Index().SetName(repositories.UserIDIndex).SetUnique(true),
+					},
+					{
+						Keys:    bson.D{{Key: "email_normalized", Value: 1}},
+						Options: options.Index().SetName(repositories.UserEmailIndex).SetUnique(true),
+					},
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(repositories.UsersCollection), repositories.UserIDIndex, repositories.UserEmailIndex)
+			},
+		},
+		{
+			Version:     4,
+			Description: "indexes on refresh_tokens and revoked_tokens",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.RefreshTokensCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "token_hash", Value: 1}},
+						Options: options.Index().SetName("token_hash_unique").SetUnique(true),
+					},
+					{
+						Keys:    bson.D{{Key: "family_id", Value: 1}},
+						Options: options.Index().SetName("family_id"),
+					},
+					{
+						Keys:    bson.D{{Key: "user_id", Value: 1}},
+						Options: options.Index().SetName("user_id"),
+					},
+				})
+				if err != nil {
+					return err
+				}
+				_, err = db.Collection(repositories.RevokedTokensCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys: bson.D{{Key: "expires_at", Value: 1}},
+					// Left at the default name, which matches the index earlier releases created.
+					Options: options.Index().SetExpireAfterSeconds(0),
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				err := dropIndexes(ctx, db.Collection(repositories.RefreshTokensCollection), "token_hash_unique", "family_id", "user_id")
+				if err != nil {
+					return err
+				}
+				return dropIndexes(ctx, db.Collection(repositories.RevokedTokensCollection), "expires_at_1")
+			},
+		},
+		{
+			Version:     5,
+			Description: "ttl index on rate_limits",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.RateLimitsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(repositories.RateLimitsCollection), "expires_at_ttl")
+			},
+		},
+		{
+			Version:     6,
+			Description: "ttl index on login_attempts",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.LoginAttemptsCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(repositories.LoginAttemptsCollection), "expires_at_ttl")
+			},
+		},
+		{
+			Version:     7,
+			Description: "indexes on password_reset_tokens",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.PasswordResetTokensCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "token_hash", Value: 1}},
+						Options: options.Index().SetName("token_hash_unique").SetUnique(true),
+					},
+					{
+						Keys:    bson.D{{Key: "user_id", Value: 1}},
+						Options: options.Index().SetName("user_id"),
+					},
+					{
+						Keys:    bson.D{{Key: "expires_at", Value: 1}},
+						Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+					},
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(repositories.PasswordResetTokensCollection), "token_hash_unique", "user_id", "expires_at_ttl")
+			},
+		},
+		{
+			Version:     8,
+			Description: "backfill email_verified on users",
+			// Accounts created before verification existed are trusted as they are.
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.UsersCollection).UpdateMany(
+					ctx,
+					bson.M{"email_verified": bson.M{"$exists": false}},
+					bson.M{"$set": bson.M{"email_verified": true}},
+				)
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.UsersCollection).UpdateMany(
+					ctx,
+					bson.M{},
+					bson.M{"$unset": bson.M{"email_verified": ""}},
+				)
+				return err
+			},
+		},
+		{
+			Version:     9,
+			Description: "indexes on email_verification_tokens",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.EmailVerificationTokensCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "token_hash", Value: 1}},
+						Options: options.Index().SetName("token_hash_unique").SetUnique(true),
+					},
+					{
+						Keys:    bson.D{{Key: "user_id", Value: 1}},
+						Options: options.Index().SetName("user_id"),
+					},
+					{
+						Keys:    bson.D{{Key: "expires_at", Value: 1}},
+						Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+					},
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(repositories.EmailVerificationTokensCollection), "token_hash_unique", "user_id", "expires_at_ttl")
+			},
+		},
+		{
+			Version:     10,
+			Description: "sort indexes on users",
+			// GET /user pages sort on the field and then the id, Mongo walks
+			// these backwards for descending order.
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.UsersCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "created_at", Value: 1}, {Key: "id", Value: 1}},
+						Options: options.Index().SetName("created_at_id"),
+					},
+					{
+						Keys:    bson.D{{Key: "name", Value: 1}, {Key: "id", Value: 1}},
+						Options: options.Index().SetName("name_id"),
+					},
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(repositories.UsersCollection), "created_at_id", "name_id")
+			},
+		},
+		{
+			Version:     11,
+			Description: "ttl index on refresh_tokens",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection(repositories.RefreshTokensCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				return dropIndexes(ctx, db.Collection(repositories.RefreshTokensCollection), "expires_at_ttl")
+			},
+		},
+	}
+}
+
+// dropIndexes drops the named indexes, ignoring ones that don't exist so Down can be rerun.
+func dropIndexes(ctx context.Context, collection *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		_, err := collection.Indexes().DropOne(ctx, name)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNotFound reports the "index not found" and "ns not found" server errors.
+func isNotFound(err error) bool {
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) {
+		return commandErr.Code == 26 || commandErr.Code == 27
+	}
+	return false
+}
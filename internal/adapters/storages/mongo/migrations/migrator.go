@@ -0,0 +1,151 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName is where applied migrations are recorded, one document per version.
+const CollectionName = "_migrations"
+
+// Migration is one versioned schema change. Up and Down may run again after a
+// crash between the change and its record, so both must be idempotent.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// AppliedMigration is the record of a migration in CollectionName.
+type AppliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+type Migrator struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	migrations []Migration
+}
+
+// NewMigrator sorts the migrations by version and rejects duplicate or
+// non-positive versions and missing steps.
+func NewMigrator(db *mongo.Database, migrations []Migration) (*Migrator, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	if err := validate(sorted); err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		collection: db.Collection(CollectionName),
+		migrations: sorted,
+	}, nil
+}
+
+func validate(sorted []Migration) error {
+	for i, migration := range sorted {
+		if migration.Version <= 0 {
+			return fmt.Errorf("migration %q: version must be positive", migration.Description)
+		}
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return fmt.Errorf("migration %d: duplicate version", migration.Version)
+		}
+		if migration.Up == nil || migration.Down == nil {
+			return fmt.Errorf("migration %d: up and down are required", migration.Version)
+		}
+	}
+	return nil
+}
+
+// Migrations returns every known migration, oldest first.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Applied returns the applied migrations keyed by version.
+func (m *Migrator) Applied(ctx context.Context) (map[int]AppliedMigration, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var records []AppliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]AppliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// Up applies every pending migration in version order and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := migration.Up(ctx, m.db); err != nil {
+			return done, fmt.Errorf("migration %d up: %w", migration.Version, err)
+		}
+		record := AppliedMigration{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now(),
+		}
+		// Upsert so an instance booting at the same time doesn't fail on the record.
+		_, err := m.collection.ReplaceOne(ctx, bson.M{"_id": migration.Version}, record, options.Replace().SetUpsert(true))
+		if err != nil {
+			return done, fmt.Errorf("migration %d record: %w", migration.Version, err)
+		}
+		slog.InfoContext(ctx, "applied migration", "version", migration.Version, "description", migration.Description)
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts up to steps of the most recently applied migrations and returns the ones it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := migration.Down(ctx, m.db); err != nil {
+			return done, fmt.Errorf("migration %d down: %w", migration.Version, err)
+		}
+		if _, err := m.collection.DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return done, fmt.Errorf("migration %d record: %w", migration.Version, err)
+		}
+		slog.InfoContext(ctx, "reverted migration", "version", migration.Version, "description", migration.Description)
+		done = append(done, migration)
+	}
+	return done, nil
+}
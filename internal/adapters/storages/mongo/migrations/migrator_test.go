@@ -0,0 +1,120 @@
+package migrations
+
+import (
+	"context"
+	"one1-be-chal/internal/adapters/storages/mongo/mongotest"
+	"one1-be-chal/internal/adapters/storages/mongo/repositories"
+	"one1-be-chal/internal/core/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func noop(ctx context.Context, db *mongo.Database) error {
+	return nil
+}
+
+func TestNewMigratorValidates(t *testing.T) {
+	_, err := NewMigrator(nil, []Migration{
+		{Version: 1, Up: noop, Down: noop},
+		{Version: 1, Up: noop, Down: noop},
+	})
+	assert.ErrorContains(t, err, "duplicate version")
+
+	_, err = NewMigrator(nil, []Migration{{Version: 0, Up: noop, Down: noop}})
+	assert.ErrorContains(t, err, "version must be positive")
+
+	_, err = NewMigrator(nil, []Migration{{Version: 1, Up: noop}})
+	assert.ErrorContains(t, err, "up and down are required")
+}
+
+func TestAllMigrationsAreValid(t *testing.T) {
+	require.NoError(t, validate(All()))
+	for i, migration := range All() {
+		assert.Equal(t, i+1, migration.Version)
+		assert.NotEmpty(t, migration.Description)
+	}
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	db := mongotest.Database(t)
+	ctx := context.Background()
+
+	var calls []string
+	step := func(name string) func(context.Context, *mongo.Database) error {
+		return func(ctx context.Context, db *mongo.Database) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	migrator, err := NewMigrator(db, []Migration{
+		{Version: 2, Description: "second", Up: step("up 2"), Down: step("down 2")},
+		{Version: 1, Description: "first", Up: step("up 1"), Down: step("down 1")},
+	})
+	require.NoError(t, err)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	reverted, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, 2, reverted[0].Version)
+
+	records, err := migrator.Applied(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, records, 1)
+	assert.NotContains(t, records, 2)
+	assert.Equal(t, []string{"up 1", "up 2", "down 2"}, calls)
+}
+
+func TestAllMigrationsBackfillAndRevert(t *testing.T) {
+	db := mongotest.Database(t)
+	ctx := context.Background()
+	users := db.Collection(repositories.UsersCollection)
+	_, err := users.InsertOne(ctx, bson.M{"id": "1", "email": " Legacy@Gmail.com"})
+	require.NoError(t, err)
+
+	migrator, err := NewMigrator(db, All())
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	var user bson.M
+	require.NoError(t, users.FindOne(ctx, bson.M{"id": "1"}).Decode(&user))
+	assert.Equal(t, "legacy@gmail.com", user["email_normalized"])
+	assert.Equal(t, domain.RoleUser, user["role"])
+
+	_, err = users.InsertOne(ctx, bson.M{"id": "2", "email_normalized": "legacy@gmail.com"})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+	indexes, err := users.Indexes().ListSpecifications(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, index := range indexes {
+		names = append(names, index.Name)
+	}
+	assert.Subset(t, names, []string{"created_at_id", "name_id"}, "GET /user sorts are served by indexes")
+	refreshTokenIndexes, err := db.Collection(repositories.RefreshTokensCollection).Indexes().ListSpecifications(ctx)
+	require.NoError(t, err)
+	var expiresAfter *int32
+	for _, index := range refreshTokenIndexes {
+		if index.Name == "expires_at_ttl" {
+			expiresAfter = index.ExpireAfterSeconds
+		}
+	}
+	require.NotNil(t, expiresAfter, "expired refresh tokens are removed")
+	assert.Equal(t, int32(0), *expiresAfter)
+
+	_, err = migrator.Down(ctx, len(All()))
+	require.NoError(t, err)
+	records, err := migrator.Applied(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
@@ -0,0 +1,16 @@
+package repositories
+
+// Collection and index names shared with the migrations that create them.
+const (
+	UsersCollection         = "users"
+	RefreshTokensCollection = "refresh_tokens"
+	RevokedTokensCollection = "revoked_tokens"
+	RateLimitsCollection    = "rate_limits"
+	LoginAttemptsCollection = "login_attempts"
+
+	PasswordResetTokensCollection     = "password_reset_tokens"
+	EmailVerificationTokensCollection = "email_verification_tokens"
+
+	UserIDIndex    = "id_unique"
+	UserEmailIndex = "email_normalized_unique"
+)
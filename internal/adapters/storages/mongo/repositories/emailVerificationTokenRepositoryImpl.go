@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoEmailVerificationTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewEmailVerificationTokenRepository expects the migrations to have created the
+// token_hash and user_id indexes, and the TTL index on expires_at.
+func NewEmailVerificationTokenRepository(db *mongo.Database, collectionName string) ports.EmailVerificationTokenRepository {
+	return &MongoEmailVerificationTokenRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (r *MongoEmailVerificationTokenRepository) Save(ctx context.Context, token domain.EmailVerificationToken) error {
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+// Consume deletes the token as it reads it, so two concurrent verifications can't
+// both use it.
+func (r *MongoEmailVerificationTokenRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (*domain.EmailVerificationToken, error) {
+	var token *domain.EmailVerificationToken
+	err := r.collection.FindOneAndDelete(ctx, bson.M{
+		"token_hash": tokenHash,
+		// The TTL monitor only runs every minute.
+		"expires_at": bson.M{"$gt": now},
+	}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrEmailVerificationTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *MongoEmailVerificationTokenRepository) DeleteUserTokens(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
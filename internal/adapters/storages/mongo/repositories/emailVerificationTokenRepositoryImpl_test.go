@@ -0,0 +1,51 @@
+package repositories_test
+
+import (
+	"context"
+	"one1-be-chal/internal/adapters/storages/mongo/mongotest"
+	"one1-be-chal/internal/adapters/storages/mongo/repositories"
+	"one1-be-chal/internal/core/domain"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailVerificationTokenRepository(t *testing.T) {
+	repo := repositories.NewEmailVerificationTokenRepository(mongotest.Database(t), repositories.EmailVerificationTokensCollection)
+	ctx := context.Background()
+	now := time.Now()
+	for _, token := range []domain.EmailVerificationToken{
+		{ID: "1", UserID: "user-1", Email: "test@gmail.com", TokenHash: "hash-1", ExpiresAt: now.Add(time.Hour)},
+		{ID: "2", UserID: "user-1", Email: "test@gmail.com", TokenHash: "hash-2", ExpiresAt: now.Add(time.Hour)},
+		{ID: "3", UserID: "user-2", Email: "test@gmail.com", TokenHash: "hash-3", ExpiresAt: now.Add(-time.Second)},
+	} {
+		require.NoError(t, repo.Save(ctx, token))
+	}
+
+	var (
+		wg       sync.WaitGroup
+		consumed atomic.Int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.Consume(ctx, "hash-1", now); err == nil {
+				consumed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), consumed.Load(), "tokens work once")
+
+	_, err := repo.Consume(ctx, "hash-3", now)
+	assert.ErrorIs(t, err, domain.ErrEmailVerificationTokenNotFound, "expired")
+
+	require.NoError(t, repo.DeleteUserTokens(ctx, "user-1"))
+	_, err = repo.Consume(ctx, "hash-2", now)
+	assert.ErrorIs(t, err, domain.ErrEmailVerificationTokenNotFound)
+}
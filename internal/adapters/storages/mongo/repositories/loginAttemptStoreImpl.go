@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoLoginAttemptStore struct {
+	collection *mongo.Collection
+}
+
+type loginAttemptsDocument struct {
+	Key           string    `bson:"_id"`
+	Failures      int       `bson:"failures"`
+	LastFailureAt time.Time `bson:"last_failure_at"`
+	// ExpiresAt is when the policy forgets the failures.
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// NewLoginAttemptStore expects the migrations to have created the TTL index on
+// expires_at that removes forgotten failures.
+func NewLoginAttemptStore(db *mongo.Database, collectionName string) ports.LoginAttemptStore {
+	return &MongoLoginAttemptStore{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (s *MongoLoginAttemptStore) Get(ctx context.Context, key string) (domain.LoginAttempts, error) {
+	var document loginAttemptsDocument
+	err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return domain.LoginAttempts{}, nil
+	}
+	if err != nil {
+		return domain.LoginAttempts{}, err
+	}
+	return domain.LoginAttempts{Failures: document.Failures, LastFailureAt: document.LastFailureAt}, nil
+}
+
+// RecordFailure runs domain.LockoutPolicy.Fail as a single pipeline update, so
+// concurrent failures on every replica are counted.
+func (s *MongoLoginAttemptStore) RecordFailure(ctx context.Context, key string, policy domain.LockoutPolicy, now time.Time) (domain.LoginAttempts, error) {
+	remembered := bson.M{"$gt": bson.A{"$expires_at", now}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures":        bson.M{"$cond": bson.A{remembered, bson.M{"$add": bson.A{"$failures", 1}}, 1}},
+			"last_failure_at": now,
+			"expires_at":      now.Add(policy.LockDuration),
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var document loginAttemptsDocument
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&document)
+	if mongo.IsDuplicateKeyError(err) {
+		// Two first failures raced to insert the document, the retry updates the winner's.
+		err = s.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&document)
+	}
+	if err != nil {
+		return domain.LoginAttempts{}, err
+	}
+	return domain.LoginAttempts{Failures: document.Failures, LastFailureAt: document.LastFailureAt}, nil
+}
+
+func (s *MongoLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,50 @@
+package repositories_test
+
+import (
+	"context"
+	"one1-be-chal/internal/adapters/storages/mongo/mongotest"
+	"one1-be-chal/internal/adapters/storages/mongo/repositories"
+	"one1-be-chal/internal/core/domain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginAttemptStore(t *testing.T) {
+	store := repositories.NewLoginAttemptStore(mongotest.Database(t), repositories.LoginAttemptsCollection)
+	ctx := context.Background()
+	policy := domain.LockoutPolicy{LockAfter: 100, LockDuration: time.Hour}
+	now := time.Now().Truncate(time.Millisecond)
+
+	attempts, err := store.Get(ctx, "account:test@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoginAttempts{}, attempts)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.RecordFailure(ctx, "account:test@gmail.com", policy, now)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	attempts, err = store.Get(ctx, "account:test@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, 20, attempts.Failures)
+	assert.True(t, now.Equal(attempts.LastFailureAt))
+
+	attempts, err = store.RecordFailure(ctx, "account:test@gmail.com", policy, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures, "forgotten failures start over")
+
+	require.NoError(t, store.Reset(ctx, "account:test@gmail.com"))
+	attempts, err = store.Get(ctx, "account:test@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, domain.LoginAttempts{}, attempts)
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoPasswordResetTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewPasswordResetTokenRepository expects the migrations to have created the
+// token_hash and user_id indexes, and the TTL index on expires_at.
+func NewPasswordResetTokenRepository(db *mongo.Database, collectionName string) ports.PasswordResetTokenRepository {
+	return &MongoPasswordResetTokenRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (r *MongoPasswordResetTokenRepository) Save(ctx context.Context, token domain.PasswordResetToken) error {
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+func (r *MongoPasswordResetTokenRepository) Get(ctx context.Context, tokenHash string, now time.Time) (*domain.PasswordResetToken, error) {
+	var token *domain.PasswordResetToken
+	err := r.collection.FindOne(ctx, bson.M{
+		"token_hash": tokenHash,
+		"expires_at": bson.M{"$gt": now},
+	}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrPasswordResetTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Consume deletes the token as it reads it, so two concurrent resets can't
+// both use it.
+func (r *MongoPasswordResetTokenRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (*domain.PasswordResetToken, error) {
+	var token *domain.PasswordResetToken
+	err := r.collection.FindOneAndDelete(ctx, bson.M{
+		"token_hash": tokenHash,
+		// The TTL monitor only runs every minute.
+		"expires_at": bson.M{"$gt": now},
+	}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrPasswordResetTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *MongoPasswordResetTokenRepository) DeleteUserTokens(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
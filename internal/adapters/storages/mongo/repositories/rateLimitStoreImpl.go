@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoRateLimitStore struct {
+	collection *mongo.Collection
+	now        func() time.Time
+}
+
+type rateLimitDocument struct {
+	Key       string    `bson:"_id"`
+	Tokens    float64   `bson:"tokens"`
+	Allowed   bool      `bson:"allowed"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// NewRateLimitStore expects the migrations to have created the TTL index on
+// expires_at that removes buckets once they have refilled.
+func NewRateLimitStore(db *mongo.Database, collectionName string) ports.RateLimitStore {
+	return &MongoRateLimitStore{
+		collection: db.Collection(collectionName),
+		now:        time.Now,
+	}
+}
+
+// Take runs domain.RateLimit.Take as a single pipeline update, so replicas
+// sharing the collection can't spend the same token.
+func (s *MongoRateLimitStore) Take(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error) {
+	now := s.now()
+	burst := float64(limit.Burst)
+	elapsedSeconds := bson.M{"$max": bson.A{0, bson.M{"$divide": bson.A{
+		bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", now}}}},
+		1000,
+	}}}}
+	refilled := bson.M{"$min": bson.A{burst, bson.M{"$add": bson.A{
+		bson.M{"$ifNull": bson.A{"$tokens", burst}},
+		bson.M{"$multiply": bson.A{elapsedSeconds, limit.RefillRate()}},
+	}}}}
+	hasToken := bson.M{"$gte": bson.A{"$tokens", 1}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": refilled}}},
+		// Every expression of a stage sees the document as it entered the stage.
+		{{Key: "$set", Value: bson.M{
+			"allowed":    hasToken,
+			"tokens":     bson.M{"$cond": bson.A{hasToken, bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"updated_at": now,
+			"expires_at": now.Add(limit.Period),
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var document rateLimitDocument
+	err := s.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&document)
+	if mongo.IsDuplicateKeyError(err) {
+		// Two first requests raced to insert the bucket, the retry updates the winner's.
+		err = s.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&document)
+	}
+	if err != nil {
+		return domain.RateLimitResult{}, err
+	}
+	return limit.Result(document.Tokens, document.Allowed), nil
+}
@@ -0,0 +1,48 @@
+package repositories_test
+
+import (
+	"context"
+	"one1-be-chal/internal/adapters/storages/mongo/mongotest"
+	"one1-be-chal/internal/adapters/storages/mongo/repositories"
+	"one1-be-chal/internal/core/domain"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitStoreConcurrentTake(t *testing.T) {
+	store := repositories.NewRateLimitStore(mongotest.Database(t), repositories.RateLimitsCollection)
+	limit := domain.RateLimit{Burst: 5, Period: time.Hour}
+
+	var (
+		wg      sync.WaitGroup
+		allowed atomic.Int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.Take(context.Background(), "ip:1.2.3.4", limit)
+			assert.NoError(t, err)
+			if result.Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(5), allowed.Load())
+
+	result, err := store.Take(context.Background(), "ip:1.2.3.4", limit)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 5, result.Limit)
+	assert.Greater(t, result.RetryAfter, time.Duration(0))
+
+	result, err = store.Take(context.Background(), "ip:5.6.7.8", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 4, result.Remaining)
+}
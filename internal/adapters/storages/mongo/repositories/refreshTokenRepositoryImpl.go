@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoRefreshTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewRefreshTokenRepository(db *mongo.Database, collectionName string) ports.RefreshTokenRepository {
+	return &MongoRefreshTokenRepository{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (r *MongoRefreshTokenRepository) Save(ctx context.Context, token domain.RefreshToken) error {
+	if _, err := r.collection.InsertOne(ctx, token); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *MongoRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	var token *domain.RefreshToken
+	if err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&token); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *MongoRefreshTokenRepository) Revoke(ctx context.Context, id string, replacedBy string) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"id": id, "revoked": false},
+		bson.M{"$set": bson.M{"revoked": true, "replaced_by": replacedBy}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"family_id": familyID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *MongoRefreshTokenRepository) RevokeUser(ctx context.Context, userID string) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package repositories_test
+
+import (
+	"context"
+	"one1-be-chal/internal/adapters/storages/mongo/mongotest"
+	"one1-be-chal/internal/adapters/storages/mongo/repositories"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokenRepository(t *testing.T) {
+	repo := repositories.NewRefreshTokenRepository(mongotest.Database(t), repositories.RefreshTokensCollection)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	for _, token := range []domain.RefreshToken{
+		{ID: "1", UserID: "user-1", FamilyID: "family-1", TokenHash: "hash-1", ExpiresAt: expiresAt},
+		{ID: "2", UserID: "user-1", FamilyID: "family-1", TokenHash: "hash-2", ExpiresAt: expiresAt},
+		{ID: "3", UserID: "user-1", FamilyID: "family-2", TokenHash: "hash-3", ExpiresAt: expiresAt},
+		{ID: "4", UserID: "user-2", FamilyID: "family-3", TokenHash: "hash-4", ExpiresAt: expiresAt},
+	} {
+		require.NoError(t, repo.Save(ctx, token))
+	}
+
+	token, err := repo.GetByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, "1", token.ID)
+	assert.False(t, token.Revoked)
+	_, err = repo.GetByHash(ctx, "unknown")
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenNotFound)
+
+	var (
+		wg      sync.WaitGroup
+		rotated atomic.Int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, err := repo.Revoke(ctx, "1", "2"); err == nil && ok {
+				rotated.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), rotated.Load(), "a token is rotated once")
+	token, err = repo.GetByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.True(t, token.Revoked)
+	assert.Equal(t, "2", token.ReplacedBy)
+
+	require.NoError(t, repo.RevokeFamily(ctx, "family-1"))
+	assert.True(t, revokedByHash(t, repo, "hash-2"), "the family is revoked")
+	assert.False(t, revokedByHash(t, repo, "hash-3"), "other logins of the user keep working")
+
+	require.NoError(t, repo.RevokeUser(ctx, "user-1"))
+	assert.True(t, revokedByHash(t, repo, "hash-3"))
+	assert.False(t, revokedByHash(t, repo, "hash-4"), "other users keep working")
+}
+
+func revokedByHash(t *testing.T, repo ports.RefreshTokenRepository, tokenHash string) bool {
+	t.Helper()
+	token, err := repo.GetByHash(context.Background(), tokenHash)
+	require.NoError(t, err)
+	return token.Revoked
+}
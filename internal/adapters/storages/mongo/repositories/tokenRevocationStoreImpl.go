@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"one1-be-chal/internal/core/ports"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoTokenRevocationStore struct {
+	collection *mongo.Collection
+}
+
+// NewTokenRevocationStore expects the migrations to have created the TTL index
+// on expires_at that removes entries once the tokens they cover have expired.
+func NewTokenRevocationStore(db *mongo.Database, collectionName string) ports.TokenRevocationStore {
+	return &MongoTokenRevocationStore{
+		collection: db.Collection(collectionName),
+	}
+}
+
+func (s *MongoTokenRevocationStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	_, err := s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": "token:" + tokenID},
+		bson.M{"$set": bson.M{"expires_at": expiresAt}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoTokenRevocationStore) RevokeUserTokens(
+	ctx context.Context,
+	userID string,
+	revokedAt time.Time,
+	expiresAt time.Time,
+) error {
+	_, err := s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": "user:" + userID},
+		bson.M{"$set": bson.M{"revoked_at": revokedAt, "expires_at": expiresAt}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MongoTokenRevocationStore) IsRevoked(
+	ctx context.Context,
+	tokenID string,
+	userID string,
+	issuedAt time.Time,
+) (bool, error) {
+	// The TTL monitor only runs once a minute, so expiry is checked here too.
+	count, err := s.collection.CountDocuments(
+		ctx,
+		bson.M{
+			"expires_at": bson.M{"$gt": time.Now()},
+			"$or": bson.A{
+				bson.M{"_id": "token:" + tokenID},
+				bson.M{"_id": "user:" + userID, "revoked_at": bson.M{"$gt": issuedAt}},
+			},
+		},
+		options.Count().SetLimit(1),
+	)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,67 @@
+package repositories_test
+
+import (
+	"context"
+	"one1-be-chal/internal/adapters/storages/mongo/mongotest"
+	"one1-be-chal/internal/adapters/storages/mongo/repositories"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRevocationStoreRevokeToken(t *testing.T) {
+	store := repositories.NewTokenRevocationStore(mongotest.Database(t), repositories.RevokedTokensCollection)
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, store.RevokeToken(ctx, "token-1", now.Add(time.Hour)))
+	require.NoError(t, store.RevokeToken(ctx, "token-1", now.Add(time.Hour)), "revoking twice is fine")
+	require.NoError(t, store.RevokeToken(ctx, "expired", now.Add(-time.Second)))
+
+	revoked, err := store.IsRevoked(ctx, "token-1", "user-1", now)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = store.IsRevoked(ctx, "token-2", "user-1", now)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = store.IsRevoked(ctx, "expired", "user-1", now)
+	require.NoError(t, err)
+	assert.False(t, revoked, "expired entries don't count before the TTL monitor removes them")
+}
+
+func TestTokenRevocationStoreRevokeUserTokens(t *testing.T) {
+	store := repositories.NewTokenRevocationStore(mongotest.Database(t), repositories.RevokedTokensCollection)
+	ctx := context.Background()
+	// Whole seconds, like the issue time in access tokens.
+	revokedAt := time.Now().Truncate(time.Second)
+
+	require.NoError(t, store.RevokeUserTokens(ctx, "user-1", revokedAt, revokedAt.Add(time.Hour)))
+	for _, test := range []struct {
+		Name     string
+		UserID   string
+		IssuedAt time.Time
+		Revoked  bool
+	}{
+		{Name: "issued before", UserID: "user-1", IssuedAt: revokedAt.Add(-time.Second), Revoked: true},
+		{Name: "issued in the same second", UserID: "user-1", IssuedAt: revokedAt, Revoked: false},
+		{Name: "issued after", UserID: "user-1", IssuedAt: revokedAt.Add(time.Second), Revoked: false},
+		{Name: "another user", UserID: "user-2", IssuedAt: revokedAt.Add(-time.Second), Revoked: false},
+	} {
+		revoked, err := store.IsRevoked(ctx, "token-1", test.UserID, test.IssuedAt)
+		require.NoError(t, err, test.Name)
+		assert.Equal(t, test.Revoked, revoked, test.Name)
+	}
+
+	later := revokedAt.Add(time.Minute)
+	require.NoError(t, store.RevokeUserTokens(ctx, "user-1", later, later.Add(time.Hour)))
+	revoked, err := store.IsRevoked(ctx, "token-1", "user-1", revokedAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.True(t, revoked, "a later revocation replaces the earlier one")
+
+	require.NoError(t, store.RevokeUserTokens(ctx, "user-2", revokedAt, revokedAt.Add(-time.Second)))
+	revoked, err = store.IsRevoked(ctx, "token-1", "user-2", revokedAt.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.False(t, revoked, "expired")
+}
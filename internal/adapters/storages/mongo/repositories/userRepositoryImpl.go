@@ -2,17 +2,30 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"one1-be-chal/internal/core/domain"
 	"one1-be-chal/internal/core/ports"
+	"regexp"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoUserRepository struct {
 	collection *mongo.Collection
 }
 
+// userDocument stores the normalized email next to the user, the unique index
+// on it is what keeps emails unique under concurrent writes.
+type userDocument struct {
+	domain.User     `bson:",inline"`
+	EmailNormalized string `bson:"email_normalized"`
+}
+
+// NewUserRepository expects the migrations to have created the unique indexes
+// on id and email_normalized.
 func NewUserRepository(db *mongo.Database, collectionName string) ports.UserRepository {
 	return &MongoUserRepository{
 		collection: db.Collection(collectionName),
@@ -20,8 +33,9 @@ func NewUserRepository(db *mongo.Database, collectionName string) ports.UserRepo
 }
 
 func (u *MongoUserRepository) Save(ctx context.Context, user domain.User) error {
-	if _, err := u.collection.InsertOne(ctx, user); err != nil {
-		return err
+	document := userDocument{User: user, EmailNormalized: domain.NormalizeEmail(user.Email)}
+	if _, err := u.collection.InsertOne(ctx, document); err != nil {
+		return translateError(err)
 	}
 	return nil
 }
@@ -29,14 +43,59 @@ func (u *MongoUserRepository) Save(ctx context.Context, user domain.User) error
 func (u *MongoUserRepository) GetUserByID(ctx context.Context, id string) (domain.User, error) {
 	var user domain.User
 	if err := u.collection.FindOne(ctx, bson.M{"id": id}).Decode(&user); err != nil {
-		return domain.User{}, err
+		return domain.User{}, translateError(err)
 	}
 	return user, nil
 }
 
-func (u *MongoUserRepository) GetAllUsers(ctx context.Context) ([]domain.User, error) {
+func (u *MongoUserRepository) GetAllUsers(ctx context.Context, query domain.UserQuery) ([]domain.User, error) {
+	direction := 1
+	comparison := "$gt"
+	if query.Descending {
+		direction = -1
+		comparison = "$lt"
+	}
+
+	conditions := bson.A{}
+	if query.NamePrefix != "" {
+		conditions = append(conditions, bson.M{"name": bson.M{"$regex": "^" + regexp.QuoteMeta(query.NamePrefix)}})
+	}
+	if query.EmailDomain != "" {
+		conditions = append(conditions, bson.M{"email_normalized": bson.M{
+			"$regex": "@" + regexp.QuoteMeta(strings.ToLower(query.EmailDomain)) + "$",
+		}})
+	}
+	if !query.CreatedFrom.IsZero() {
+		conditions = append(conditions, bson.M{"created_at": bson.M{"$gte": query.CreatedFrom}})
+	}
+	if !query.CreatedTo.IsZero() {
+		conditions = append(conditions, bson.M{"created_at": bson.M{"$lt": query.CreatedTo}})
+	}
+	if query.After != nil {
+		var sortValue interface{} = query.After.CreatedAt
+		if query.SortBy == domain.SortByName {
+			sortValue = query.After.Name
+		}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{query.SortBy: bson.M{comparison: sortValue}},
+			bson.M{query.SortBy: sortValue, "id": bson.M{comparison: query.After.ID}},
+		}})
+	}
+
+	filter := bson.M{}
+	if len(conditions) > 0 {
+		filter["$and"] = conditions
+	}
+	findOptions := options.Find().SetSort(bson.D{
+		{Key: query.SortBy, Value: direction},
+		{Key: "id", Value: direction},
+	})
+	if query.Limit > 0 {
+		findOptions.SetLimit(int64(query.Limit))
+	}
+
 	var users []domain.User
-	cursor, err := u.collection.Find(ctx, bson.M{})
+	cursor, err := u.collection.Find(ctx, filter, findOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -46,30 +105,88 @@ func (u *MongoUserRepository) GetAllUsers(ctx context.Context) ([]domain.User, e
 	return users, nil
 }
 
-func (u *MongoUserRepository) UpdateUser(ctx context.Context, uid string, user bson.M) error {
-	_, err := u.collection.UpdateOne(
+func (u *MongoUserRepository) UpdateUser(ctx context.Context, uid string, patch domain.UserPatch) error {
+	if patch.IsEmpty() {
+		return domain.NewValidationError("name and email cannot be empty")
+	}
+	updateFields := bson.M{}
+	if patch.Name != nil {
+		updateFields["name"] = *patch.Name
+	}
+	if patch.Email != nil {
+		updateFields["email"] = *patch.Email
+		updateFields["email_normalized"] = domain.NormalizeEmail(*patch.Email)
+	}
+	if patch.Password != nil {
+		updateFields["password"] = *patch.Password
+	}
+	if patch.PasswordChangedAt != nil {
+		updateFields["password_changed_at"] = *patch.PasswordChangedAt
+	}
+	if patch.EmailVerified != nil {
+		updateFields["email_verified"] = *patch.EmailVerified
+	}
+	if patch.TwoFactorEnabled != nil {
+		updateFields["two_factor_enabled"] = *patch.TwoFactorEnabled
+	}
+	if patch.TOTPSecret != nil {
+		updateFields["totp_secret"] = *patch.TOTPSecret
+	}
+	if patch.TOTPLastStep != nil {
+		updateFields["totp_last_step"] = *patch.TOTPLastStep
+	}
+	if patch.RecoveryCodes != nil {
+		updateFields["recovery_codes"] = *patch.RecoveryCodes
+	}
+
+	filter := bson.M{"id": uid}
+	if patch.ExpectedPassword != nil {
+		filter["password"] = *patch.ExpectedPassword
+	}
+	// Zero steps and empty code lists are omitted when users are saved.
+	if patch.ExpectedTOTPLastStep != nil {
+		filter["totp_last_step"] = *patch.ExpectedTOTPLastStep
+		if *patch.ExpectedTOTPLastStep == 0 {
+			filter["totp_last_step"] = bson.M{"$in": bson.A{0, nil}}
+		}
+	}
+	if patch.ExpectedRecoveryCodes != nil {
+		filter["recovery_codes"] = *patch.ExpectedRecoveryCodes
+		if len(*patch.ExpectedRecoveryCodes) == 0 {
+			filter["recovery_codes"] = bson.M{"$in": bson.A{bson.A{}, nil}}
+		}
+	}
+	result, err := u.collection.UpdateOne(
 		ctx,
-		bson.M{"id": uid},
-		bson.M{"$set": user},
+		filter,
+		bson.M{"$set": updateFields},
 	)
 	if err != nil {
-		return err
+		return translateError(err)
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrUserNotFound
 	}
 
 	return nil
 }
 
 func (u *MongoUserRepository) DeleteUser(ctx context.Context, id string) error {
-	if _, err := u.collection.DeleteOne(ctx, bson.M{"id": id}); err != nil {
-		return err
+	result, err := u.collection.DeleteOne(ctx, bson.M{"id": id})
+	if err != nil {
+		return translateError(err)
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrUserNotFound
 	}
 	return nil
 }
 
 func (u *MongoUserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user *domain.User
-	if err := u.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user); err != nil {
-		return nil, err
+	filter := bson.M{"email_normalized": domain.NormalizeEmail(email)}
+	if err := u.collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, translateError(err)
 	}
 	return user, nil
 }
@@ -81,3 +198,14 @@ func (u *MongoUserRepository) GetUserCount(ctx context.Context) (int64, error) {
 	}
 	return count, nil
 }
+
+// translateError maps driver errors to domain errors so the core never sees Mongo types.
+func translateError(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return domain.ErrUserNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), UserEmailIndex) {
+		return domain.ErrEmailTaken
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package repositories_test
+
+import (
+	"context"
+	"fmt"
+	"one1-be-chal/internal/adapters/storages/mongo/migrations"
+	"one1-be-chal/internal/adapters/storages/mongo/mongotest"
+	"one1-be-chal/internal/adapters/storages/mongo/repositories"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func migratedUserRepository(t *testing.T) ports.UserRepository {
+	db := mongotest.Database(t)
+	migrator, err := migrations.NewMigrator(db, migrations.All())
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return repositories.NewUserRepository(db, repositories.UsersCollection)
+}
+
+func TestUserRepositoryConcurrentSave(t *testing.T) {
+	repo := migratedUserRepository(t)
+	ctx := context.Background()
+
+	const attempts = 10
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = repo.Save(ctx, domain.User{ID: fmt.Sprint(i), Email: "Test@gmail.com"})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, domain.ErrEmailTaken)
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestUserRepositoryUpdateEmailTaken(t *testing.T) {
+	repo := migratedUserRepository(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Save(ctx, domain.User{ID: "1", Email: "first@gmail.com"}))
+	require.NoError(t, repo.Save(ctx, domain.User{ID: "2", Email: "second@gmail.com"}))
+
+	taken := "FIRST@gmail.com"
+	err := repo.UpdateUser(ctx, "2", domain.UserPatch{Email: &taken})
+	assert.ErrorIs(t, err, domain.ErrEmailTaken)
+
+	user, err := repo.GetUserByEmail(ctx, "First@Gmail.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", user.ID)
+}
+
+func TestUserRepositoryUpdateExpectedPassword(t *testing.T) {
+	repo := migratedUserRepository(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Save(ctx, domain.User{ID: "1", Email: "test@gmail.com", Password: "old hash"}))
+
+	stale, current, rehashed := "stale hash", "old hash", "rehashed"
+	err := repo.UpdateUser(ctx, "1", domain.UserPatch{Password: &rehashed, ExpectedPassword: &stale})
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	require.NoError(t, repo.UpdateUser(ctx, "1", domain.UserPatch{Password: &rehashed, ExpectedPassword: &current}))
+
+	user, err := repo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "rehashed", user.Password)
+}
+
+func TestUserRepositoryUpdateTwoFactor(t *testing.T) {
+	repo := migratedUserRepository(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Save(ctx, domain.User{ID: "1", Email: "test@gmail.com"}))
+
+	enabled, secret, step, codes := true, "SECRET", int64(42), []string{"first", "second"}
+	require.NoError(t, repo.UpdateUser(ctx, "1", domain.UserPatch{TwoFactorEnabled: &enabled, TOTPSecret: &secret, TOTPLastStep: &step, RecoveryCodes: &codes}))
+	user, err := repo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.True(t, user.TwoFactorEnabled)
+	assert.Equal(t, "SECRET", user.TOTPSecret)
+	assert.Equal(t, int64(42), user.TOTPLastStep)
+	assert.Equal(t, []string{"first", "second"}, user.RecoveryCodes)
+
+	staleStep, nextStep := int64(41), int64(43)
+	err = repo.UpdateUser(ctx, "1", domain.UserPatch{TOTPLastStep: &nextStep, ExpectedTOTPLastStep: &staleStep})
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	require.NoError(t, repo.UpdateUser(ctx, "1", domain.UserPatch{TOTPLastStep: &nextStep, ExpectedTOTPLastStep: &step}))
+	staleCodes, remaining := []string{"first"}, []string{"second"}
+	err = repo.UpdateUser(ctx, "1", domain.UserPatch{RecoveryCodes: &remaining, ExpectedRecoveryCodes: &staleCodes})
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	require.NoError(t, repo.UpdateUser(ctx, "1", domain.UserPatch{RecoveryCodes: &remaining, ExpectedRecoveryCodes: &codes}))
+
+	disabled, noSecret, noStep := false, "", int64(0)
+	require.NoError(t, repo.UpdateUser(ctx, "1", domain.UserPatch{TwoFactorEnabled: &disabled, TOTPSecret: &noSecret, TOTPLastStep: &noStep, RecoveryCodes: &[]string{}}))
+	user, err = repo.GetUserByID(ctx, "1")
+	require.NoError(t, err)
+	assert.False(t, user.TwoFactorEnabled)
+	assert.Empty(t, user.TOTPSecret)
+	assert.Empty(t, user.RecoveryCodes)
+
+	require.NoError(t, repo.Save(ctx, domain.User{ID: "2", Email: "second@gmail.com"}))
+	noCodes := []string{}
+	require.NoError(t, repo.UpdateUser(ctx, "2", domain.UserPatch{TOTPLastStep: &step, ExpectedTOTPLastStep: &noStep, ExpectedRecoveryCodes: &noCodes}),
+		"users saved without two-factor state match zero values")
+}
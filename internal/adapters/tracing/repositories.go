@@ -0,0 +1,227 @@
+package tracing
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"time"
+)
+
+type tracedUserRepository struct {
+	next ports.UserRepository
+}
+
+// TraceUserRepository wraps every call to repository in a span named after the method.
+func TraceUserRepository(repository ports.UserRepository) ports.UserRepository {
+	return &tracedUserRepository{next: repository}
+}
+
+func (r *tracedUserRepository) Save(ctx context.Context, user domain.User) (err error) {
+	ctx, span := start(ctx, "UserRepository.Save")
+	defer func() { end(span, err) }()
+	return r.next.Save(ctx, user)
+}
+
+func (r *tracedUserRepository) GetUserByID(ctx context.Context, id string) (user domain.User, err error) {
+	ctx, span := start(ctx, "UserRepository.GetUserByID")
+	defer func() { end(span, err) }()
+	return r.next.GetUserByID(ctx, id)
+}
+
+func (r *tracedUserRepository) GetAllUsers(ctx context.Context, query domain.UserQuery) (users []domain.User, err error) {
+	ctx, span := start(ctx, "UserRepository.GetAllUsers")
+	defer func() { end(span, err) }()
+	return r.next.GetAllUsers(ctx, query)
+}
+
+func (r *tracedUserRepository) UpdateUser(ctx context.Context, id string, patch domain.UserPatch) (err error) {
+	ctx, span := start(ctx, "UserRepository.UpdateUser")
+	defer func() { end(span, err) }()
+	return r.next.UpdateUser(ctx, id, patch)
+}
+
+func (r *tracedUserRepository) DeleteUser(ctx context.Context, id string) (err error) {
+	ctx, span := start(ctx, "UserRepository.DeleteUser")
+	defer func() { end(span, err) }()
+	return r.next.DeleteUser(ctx, id)
+}
+
+func (r *tracedUserRepository) GetUserByEmail(ctx context.Context, email string) (user *domain.User, err error) {
+	ctx, span := start(ctx, "UserRepository.GetUserByEmail")
+	defer func() { end(span, err) }()
+	return r.next.GetUserByEmail(ctx, email)
+}
+
+func (r *tracedUserRepository) GetUserCount(ctx context.Context) (count int64, err error) {
+	ctx, span := start(ctx, "UserRepository.GetUserCount")
+	defer func() { end(span, err) }()
+	return r.next.GetUserCount(ctx)
+}
+
+type tracedRefreshTokenRepository struct {
+	next ports.RefreshTokenRepository
+}
+
+// TraceRefreshTokenRepository wraps every call to repository in a span named after the method.
+func TraceRefreshTokenRepository(repository ports.RefreshTokenRepository) ports.RefreshTokenRepository {
+	return &tracedRefreshTokenRepository{next: repository}
+}
+
+func (r *tracedRefreshTokenRepository) Save(ctx context.Context, token domain.RefreshToken) (err error) {
+	ctx, span := start(ctx, "RefreshTokenRepository.Save")
+	defer func() { end(span, err) }()
+	return r.next.Save(ctx, token)
+}
+
+func (r *tracedRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (token *domain.RefreshToken, err error) {
+	ctx, span := start(ctx, "RefreshTokenRepository.GetByHash")
+	defer func() { end(span, err) }()
+	return r.next.GetByHash(ctx, tokenHash)
+}
+
+func (r *tracedRefreshTokenRepository) Revoke(ctx context.Context, id string, replacedBy string) (revoked bool, err error) {
+	ctx, span := start(ctx, "RefreshTokenRepository.Revoke")
+	defer func() { end(span, err) }()
+	return r.next.Revoke(ctx, id, replacedBy)
+}
+
+func (r *tracedRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) (err error) {
+	ctx, span := start(ctx, "RefreshTokenRepository.RevokeFamily")
+	defer func() { end(span, err) }()
+	return r.next.RevokeFamily(ctx, familyID)
+}
+
+func (r *tracedRefreshTokenRepository) RevokeUser(ctx context.Context, userID string) (err error) {
+	ctx, span := start(ctx, "RefreshTokenRepository.RevokeUser")
+	defer func() { end(span, err) }()
+	return r.next.RevokeUser(ctx, userID)
+}
+
+type tracedTokenRevocationStore struct {
+	next ports.TokenRevocationStore
+}
+
+// TraceTokenRevocationStore wraps every call to store in a span named after the method.
+func TraceTokenRevocationStore(store ports.TokenRevocationStore) ports.TokenRevocationStore {
+	return &tracedTokenRevocationStore{next: store}
+}
+
+func (s *tracedTokenRevocationStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) (err error) {
+	ctx, span := start(ctx, "TokenRevocationStore.RevokeToken")
+	defer func() { end(span, err) }()
+	return s.next.RevokeToken(ctx, tokenID, expiresAt)
+}
+
+func (s *tracedTokenRevocationStore) RevokeUserTokens(ctx context.Context, userID string, revokedAt time.Time, expiresAt time.Time) (err error) {
+	ctx, span := start(ctx, "TokenRevocationStore.RevokeUserTokens")
+	defer func() { end(span, err) }()
+	return s.next.RevokeUserTokens(ctx, userID, revokedAt, expiresAt)
+}
+
+func (s *tracedTokenRevocationStore) IsRevoked(ctx context.Context, tokenID string, userID string, issuedAt time.Time) (revoked bool, err error) {
+	ctx, span := start(ctx, "TokenRevocationStore.IsRevoked")
+	defer func() { end(span, err) }()
+	return s.next.IsRevoked(ctx, tokenID, userID, issuedAt)
+}
+
+type tracedLoginAttemptStore struct {
+	next ports.LoginAttemptStore
+}
+
+// TraceLoginAttemptStore wraps every call to store in a span named after the method.
+func TraceLoginAttemptStore(store ports.LoginAttemptStore) ports.LoginAttemptStore {
+	return &tracedLoginAttemptStore{next: store}
+}
+
+func (s *tracedLoginAttemptStore) Get(ctx context.Context, key string) (attempts domain.LoginAttempts, err error) {
+	ctx, span := start(ctx, "LoginAttemptStore.Get")
+	defer func() { end(span, err) }()
+	return s.next.Get(ctx, key)
+}
+
+func (s *tracedLoginAttemptStore) RecordFailure(ctx context.Context, key string, policy domain.LockoutPolicy, now time.Time) (attempts domain.LoginAttempts, err error) {
+	ctx, span := start(ctx, "LoginAttemptStore.RecordFailure")
+	defer func() { end(span, err) }()
+	return s.next.RecordFailure(ctx, key, policy, now)
+}
+
+func (s *tracedLoginAttemptStore) Reset(ctx context.Context, key string) (err error) {
+	ctx, span := start(ctx, "LoginAttemptStore.Reset")
+	defer func() { end(span, err) }()
+	return s.next.Reset(ctx, key)
+}
+
+type tracedPasswordResetTokenRepository struct {
+	next ports.PasswordResetTokenRepository
+}
+
+// TracePasswordResetTokenRepository wraps every call to repository in a span named after the method.
+func TracePasswordResetTokenRepository(repository ports.PasswordResetTokenRepository) ports.PasswordResetTokenRepository {
+	return &tracedPasswordResetTokenRepository{next: repository}
+}
+
+func (r *tracedPasswordResetTokenRepository) Save(ctx context.Context, token domain.PasswordResetToken) (err error) {
+	ctx, span := start(ctx, "PasswordResetTokenRepository.Save")
+	defer func() { end(span, err) }()
+	return r.next.Save(ctx, token)
+}
+
+func (r *tracedPasswordResetTokenRepository) Get(ctx context.Context, tokenHash string, now time.Time) (token *domain.PasswordResetToken, err error) {
+	ctx, span := start(ctx, "PasswordResetTokenRepository.Get")
+	defer func() { end(span, err) }()
+	return r.next.Get(ctx, tokenHash, now)
+}
+
+func (r *tracedPasswordResetTokenRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (token *domain.PasswordResetToken, err error) {
+	ctx, span := start(ctx, "PasswordResetTokenRepository.Consume")
+	defer func() { end(span, err) }()
+	return r.next.Consume(ctx, tokenHash, now)
+}
+
+func (r *tracedPasswordResetTokenRepository) DeleteUserTokens(ctx context.Context, userID string) (err error) {
+	ctx, span := start(ctx, "PasswordResetTokenRepository.DeleteUserTokens")
+	defer func() { end(span, err) }()
+	return r.next.DeleteUserTokens(ctx, userID)
+}
+
+type tracedEmailVerificationTokenRepository struct {
+	next ports.EmailVerificationTokenRepository
+}
+
+// TraceEmailVerificationTokenRepository wraps every call to repository in a span named after the method.
+func TraceEmailVerificationTokenRepository(repository ports.EmailVerificationTokenRepository) ports.EmailVerificationTokenRepository {
+	return &tracedEmailVerificationTokenRepository{next: repository}
+}
+
+func (r *tracedEmailVerificationTokenRepository) Save(ctx context.Context, token domain.EmailVerificationToken) (err error) {
+	ctx, span := start(ctx, "EmailVerificationTokenRepository.Save")
+	defer func() { end(span, err) }()
+	return r.next.Save(ctx, token)
+}
+
+func (r *tracedEmailVerificationTokenRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (token *domain.EmailVerificationToken, err error) {
+	ctx, span := start(ctx, "EmailVerificationTokenRepository.Consume")
+	defer func() { end(span, err) }()
+	return r.next.Consume(ctx, tokenHash, now)
+}
+
+func (r *tracedEmailVerificationTokenRepository) DeleteUserTokens(ctx context.Context, userID string) (err error) {
+	ctx, span := start(ctx, "EmailVerificationTokenRepository.DeleteUserTokens")
+	defer func() { end(span, err) }()
+	return r.next.DeleteUserTokens(ctx, userID)
+}
+
+type tracedNotifier struct {
+	next ports.Notifier
+}
+
+// TraceNotifier wraps every message sent by notifier in a span.
+func TraceNotifier(notifier ports.Notifier) ports.Notifier {
+	return &tracedNotifier{next: notifier}
+}
+
+func (n *tracedNotifier) Notify(ctx context.Context, message domain.Message) (err error) {
+	ctx, span := start(ctx, "Notifier.Notify")
+	defer func() { end(span, err) }()
+	return n.next.Notify(ctx, message)
+}
@@ -0,0 +1,26 @@
+package tracing
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "one1-be-chal/internal/adapters/tracing"
+
+func start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// end ends span, marking it failed for errors other than the domain's
+// expected answers such as not found.
+func end(span trace.Span, err error) {
+	if err != nil && !domain.IsExpected(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
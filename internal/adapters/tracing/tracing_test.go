@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/adapters/hashers"
+	"one1-be-chal/internal/adapters/notifiers"
+	"one1-be-chal/internal/adapters/storages/memory"
+	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"one1-be-chal/internal/core/services"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	recorder     *tracetest.SpanRecorder
+	recorderOnce sync.Once
+)
+
+// traceTest installs a recording tracer provider, once per process because
+// tracers created before the first provider is installed stay bound to it, and
+// starts a root span. spans returns the spans that ended in the root's trace.
+func traceTest(t *testing.T) (ctx context.Context, spans func() []sdktrace.ReadOnlySpan) {
+	recorderOnce.Do(func() {
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), t.Name())
+	t.Cleanup(func() { root.End() })
+	traceID := root.SpanContext().TraceID()
+	return ctx, func() []sdktrace.ReadOnlySpan {
+		var ended []sdktrace.ReadOnlySpan
+		for _, span := range recorder.Ended() {
+			if span.SpanContext().TraceID() == traceID {
+				ended = append(ended, span)
+			}
+		}
+		return ended
+	}
+}
+
+func spanNamed(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %s", name)
+	return nil
+}
+
+// failingCountRepository fails GetUserCount and delegates everything else.
+type failingCountRepository struct {
+	ports.UserRepository
+}
+
+func (failingCountRepository) GetUserCount(ctx context.Context) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestTraceUserRepository(t *testing.T) {
+	ctx, spans := traceTest(t)
+	repo := TraceUserRepository(failingCountRepository{memory.NewUserRepository()})
+
+	require.NoError(t, repo.Save(ctx, domain.User{ID: "1", Email: "test@gmail.com"}))
+	_, err := repo.GetUserByID(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	_, err = repo.GetUserCount(ctx)
+	assert.Error(t, err)
+
+	ended := spans()
+	require.Len(t, ended, 3)
+	save := spanNamed(t, ended, "UserRepository.Save")
+	assert.Equal(t, trace.SpanKindClient, save.SpanKind())
+	assert.Equal(t, codes.Unset, save.Status().Code)
+	assert.Equal(t, codes.Unset, spanNamed(t, ended, "UserRepository.GetUserByID").Status().Code)
+	count := spanNamed(t, ended, "UserRepository.GetUserCount")
+	assert.Equal(t, codes.Error, count.Status().Code)
+	assert.Equal(t, "connection refused", count.Status().Description)
+}
+
+func TestServiceSpansNestRepositoryCalls(t *testing.T) {
+	ctx, spans := traceTest(t)
+	hasher, err := hashers.NewBcryptHasher(&config.Bcrypt{Cost: bcrypt.MinCost})
+	require.NoError(t, err)
+	service := services.NewUserService(
+		TraceUserRepository(memory.NewUserRepository()),
+		TraceRefreshTokenRepository(memory.NewRefreshTokenRepository()),
+		TraceTokenRevocationStore(memory.NewTokenRevocationStore()),
+		TraceLoginAttemptStore(memory.NewLoginAttemptStore()),
+		TracePasswordResetTokenRepository(memory.NewPasswordResetTokenRepository()),
+		TraceEmailVerificationTokenRepository(memory.NewEmailVerificationTokenRepository()),
+		TraceNotifier(notifiers.NewLogNotifier()),
+		hasher,
+	)
+	mockConfig := config.Container{
+		JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+	}
+
+	_, err = service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+
+	_, err = service.Login(ctx, "test@gmail.com", "wrongpassword", "192.0.2.1", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+
+	ended := spans()
+	register := spanNamed(t, ended, "UserService.Register")
+	for _, name := range []string{"HashPassword", "UserRepository.GetUserByEmail", "UserRepository.Save", "RefreshTokenRepository.Save"} {
+		assert.Equal(t, register.SpanContext().SpanID(), spanNamed(t, ended, name).Parent().SpanID(), name)
+	}
+	assert.Equal(t, codes.Unset, spanNamed(t, ended, "UserService.Login").Status().Code, "wrong passwords are expected")
+}
+
+func TestSetup(t *testing.T) {
+	_, err := Setup(context.Background(), &config.Tracing{Exporter: "zipkin", SampleRatio: 1})
+	assert.ErrorContains(t, err, "must be none, stdout or otlp")
+}
@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// EmailVerificationToken proves control of Email, the address the user had
+// when the token was sent.
+type EmailVerificationToken struct {
+	ID        string    `json:"id" bson:"id"`                 // auto-generated
+	UserID    string    `json:"user_id" bson:"user_id"`       // owner of the token
+	Email     string    `json:"email" bson:"email"`           // address being verified
+	TokenHash string    `json:"-" bson:"token_hash"`          // sha256 of the raw token
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"` // timestamp
+	CreatedAt time.Time `json:"created_at" bson:"created_at"` // timestamp
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" query:"token" validate:"required"`
+}
@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUserNotFound         = errors.New("user not found")
+	ErrEmailTaken           = errors.New("email already exist")
+	ErrValidation           = errors.New("validation failed")
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrInvalidRefreshToken  = errors.New("invalid refresh token")
+	ErrRefreshTokenReused   = errors.New("refresh token reuse detected")
+	ErrLoginThrottled       = errors.New("too many failed login attempts, retry later")
+	ErrIncorrectPassword    = errors.New("current password is incorrect")
+	ErrWeakPassword         = errors.New("password does not meet the password policy")
+
+	ErrPasswordResetTokenNotFound = errors.New("password reset token not found")
+	ErrInvalidPasswordResetToken  = errors.New("invalid or expired password reset token")
+
+	ErrEmailVerificationTokenNotFound = errors.New("email verification token not found")
+	ErrInvalidEmailVerificationToken  = errors.New("invalid or expired email verification token")
+	ErrEmailAlreadyVerified           = errors.New("email already verified")
+	ErrEmailVerificationDisabled      = errors.New("email verification is disabled")
+
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not set up")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidMFAToken         = errors.New("invalid or expired mfa token")
+)
+
+// expectedErrors are the domain's answers to a request, as opposed to failures
+// of the service or its dependencies.
+var expectedErrors = []error{
+	ErrUserNotFound, ErrEmailTaken, ErrValidation, ErrInvalidCredentials,
+	ErrRefreshTokenNotFound, ErrInvalidRefreshToken, ErrRefreshTokenReused,
+	ErrLoginThrottled, ErrIncorrectPassword, ErrWeakPassword,
+	ErrPasswordResetTokenNotFound, ErrInvalidPasswordResetToken,
+	ErrEmailVerificationTokenNotFound, ErrInvalidEmailVerificationToken,
+	ErrEmailAlreadyVerified, ErrEmailVerificationDisabled,
+	ErrTwoFactorAlreadyEnabled, ErrTwoFactorNotEnabled, ErrInvalidTwoFactorCode, ErrInvalidMFAToken,
+}
+
+// IsExpected reports whether err is one of the domain's answers, such as not
+// found or invalid credentials. Traces don't mark those as failed.
+func IsExpected(err error) bool {
+	for _, expected := range expectedErrors {
+		if errors.Is(err, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidationError describes invalid input. It matches ErrValidation with errors.Is
+// while keeping its own message.
+type ValidationError struct {
+	Message string
+}
+
+func NewValidationError(message string) error {
+	return &ValidationError{Message: message}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// LoginThrottledError rejects a login without checking the password, because
+// the account or the client failed too often. It matches ErrLoginThrottled
+// with errors.Is.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrLoginThrottled.Error()
+}
+
+func (e *LoginThrottledError) Is(target error) bool {
+	return target == ErrLoginThrottled
+}
+
+// WeakPasswordError lists every rule of the PasswordPolicy a password broke.
+// It matches ErrWeakPassword with errors.Is.
+type WeakPasswordError struct {
+	Violations []PasswordViolation
+}
+
+func (e *WeakPasswordError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return ErrWeakPassword.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *WeakPasswordError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
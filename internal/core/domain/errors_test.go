@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationError(t *testing.T) {
+	err := NewValidationError("name and email cannot be empty")
+
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.ErrorIs(t, fmt.Errorf("update user: %w", err), ErrValidation)
+	assert.Equal(t, "name and email cannot be empty", err.Error())
+	assert.False(t, errors.Is(err, ErrUserNotFound))
+}
+
+func TestIsExpected(t *testing.T) {
+	assert.True(t, IsExpected(ErrInvalidCredentials))
+	assert.True(t, IsExpected(fmt.Errorf("get user: %w", ErrUserNotFound)))
+	assert.True(t, IsExpected(NewValidationError("name cannot be empty")))
+	assert.True(t, IsExpected(&LoginThrottledError{RetryAfter: time.Minute}))
+	assert.False(t, IsExpected(errors.New("connection refused")))
+	assert.False(t, IsExpected(nil))
+}
+
+func TestLoginThrottledError(t *testing.T) {
+	err := &LoginThrottledError{RetryAfter: time.Minute}
+
+	assert.ErrorIs(t, fmt.Errorf("login: %w", err), ErrLoginThrottled)
+	assert.Equal(t, ErrLoginThrottled.Error(), err.Error())
+
+	var throttled *LoginThrottledError
+	assert.True(t, errors.As(fmt.Errorf("login: %w", err), &throttled))
+	assert.Equal(t, time.Minute, throttled.RetryAfter)
+}
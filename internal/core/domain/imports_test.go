@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The core must stay storage agnostic, only adapters may import the Mongo driver.
+func TestCoreHasNoMongoImports(t *testing.T) {
+	err := filepath.WalkDir("..", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, spec := range file.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			assert.False(t, strings.HasPrefix(importPath, "go.mongodb.org/"), "%s imports %s", path, importPath)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,65 @@
+package domain
+
+import "time"
+
+// LoginAttempts counts the failed logins of one account or client IP.
+type LoginAttempts struct {
+	Failures      int
+	LastFailureAt time.Time
+}
+
+// LockoutPolicy slows down password guessing. Once BackoffAfter attempts in a
+// row failed, every further failure doubles the wait before the next attempt,
+// starting at BaseDelay and capped at MaxDelay. After LockAfter failures no
+// attempt is accepted for LockDuration. Failures are forgotten LockDuration
+// after the last one, so it has to be positive. Zero counts disable their stage.
+type LockoutPolicy struct {
+	BackoffAfter int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockAfter    int
+	LockDuration time.Duration
+}
+
+// Current drops failures that are old enough to be forgotten.
+func (p LockoutPolicy) Current(attempts LoginAttempts, now time.Time) LoginAttempts {
+	if !now.Before(attempts.LastFailureAt.Add(p.LockDuration)) {
+		return LoginAttempts{}
+	}
+	return attempts
+}
+
+// Fail returns attempts with one more failure at now.
+func (p LockoutPolicy) Fail(attempts LoginAttempts, now time.Time) LoginAttempts {
+	attempts = p.Current(attempts, now)
+	attempts.Failures++
+	attempts.LastFailureAt = now
+	return attempts
+}
+
+// Locked reports whether attempts reached the lock, rather than a backoff.
+func (p LockoutPolicy) Locked(attempts LoginAttempts, now time.Time) bool {
+	attempts = p.Current(attempts, now)
+	return p.LockAfter > 0 && attempts.Failures >= p.LockAfter
+}
+
+// BlockedUntil is when the next attempt is accepted again, it is not after
+// now when attempts may be made right away.
+func (p LockoutPolicy) BlockedUntil(attempts LoginAttempts, now time.Time) time.Time {
+	attempts = p.Current(attempts, now)
+	if p.LockAfter > 0 && attempts.Failures >= p.LockAfter {
+		return attempts.LastFailureAt.Add(p.LockDuration)
+	}
+	if p.BackoffAfter <= 0 || attempts.Failures < p.BackoffAfter {
+		return time.Time{}
+	}
+
+	delay := p.BaseDelay
+	for i := p.BackoffAfter; i < attempts.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return attempts.LastFailureAt.Add(delay)
+}
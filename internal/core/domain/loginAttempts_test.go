@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutPolicy(t *testing.T) {
+	policy := LockoutPolicy{
+		BackoffAfter: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Second,
+		LockAfter:    6,
+		LockDuration: time.Hour,
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var attempts LoginAttempts
+	for i := 1; i <= 2; i++ {
+		attempts = policy.Fail(attempts, now)
+		assert.Equal(t, i, attempts.Failures)
+		assert.False(t, policy.BlockedUntil(attempts, now).After(now), "no delay before BackoffAfter")
+	}
+
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		attempts = policy.Fail(attempts, now)
+		assert.Equal(t, now.Add(delay), policy.BlockedUntil(attempts, now))
+		assert.False(t, policy.Locked(attempts, now))
+	}
+
+	attempts = policy.Fail(attempts, now)
+	assert.Equal(t, 6, attempts.Failures)
+	assert.True(t, policy.Locked(attempts, now))
+	assert.Equal(t, now.Add(time.Hour), policy.BlockedUntil(attempts, now))
+
+	later := now.Add(time.Hour)
+	assert.Equal(t, LoginAttempts{}, policy.Current(attempts, later), "failures are forgotten once the lock ends")
+	assert.False(t, policy.Locked(attempts, later))
+	assert.Equal(t, 1, policy.Fail(attempts, later).Failures)
+}
+
+func TestLockoutPolicyCapsDelay(t *testing.T) {
+	policy := LockoutPolicy{BackoffAfter: 1, BaseDelay: time.Second, MaxDelay: 5 * time.Second, LockDuration: time.Hour}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	attempts := LoginAttempts{Failures: 1000, LastFailureAt: now}
+	assert.Equal(t, now.Add(5*time.Second), policy.BlockedUntil(attempts, now))
+	assert.False(t, policy.Locked(attempts, now), "LockAfter 0 never locks")
+}
+
+func TestLockoutPolicyDisabled(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	attempts := LockoutPolicy{}.Fail(LoginAttempts{}, now)
+	assert.Equal(t, LoginAttempts{}, LockoutPolicy{}.Current(attempts, now))
+	assert.True(t, LockoutPolicy{}.BlockedUntil(attempts, now).IsZero())
+}
@@ -0,0 +1,8 @@
+package domain
+
+// Message is a plain text message for a user, such as an email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
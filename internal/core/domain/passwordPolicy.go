@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules of the PasswordPolicy, reported in PasswordViolation.Rule.
+const (
+	PasswordRuleMinLength        = "min_length"
+	PasswordRuleMaxLength        = "max_length"
+	PasswordRuleCharacterClasses = "character_classes"
+	PasswordRulePersonalInfo     = "personal_info"
+	PasswordRuleBlocklist        = "blocklist"
+)
+
+// PasswordPolicy is what a new password has to satisfy. Zero values turn
+// their rule off.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxBytes is the longest password the password hash can take, in bytes
+	// of UTF-8 since that is what hashes limit.
+	MaxBytes int
+	// MinCharacterClasses is how many of lowercase letters, uppercase letters,
+	// digits and other characters a password has to mix.
+	MinCharacterClasses int
+	// RejectPersonalInfo rejects passwords containing the user's name or the
+	// part of their email before the @.
+	RejectPersonalInfo bool
+	// Blocklist holds common or breached passwords in lowercase, they are
+	// rejected regardless of case.
+	Blocklist map[string]struct{}
+}
+
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// personalInfoMinLength keeps short names like "Al" from ruling out every
+// password that happens to contain them.
+const personalInfoMinLength = 3
+
+// Check returns a *WeakPasswordError listing every rule password breaks as
+// the password of user.
+func (p PasswordPolicy) Check(password string, user User) error {
+	var violations []PasswordViolation
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleMinLength,
+			Message: fmt.Sprintf("password must be at least %d characters", p.MinLength),
+		})
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleMaxLength,
+			Message: fmt.Sprintf("password must be at most %d bytes, letters outside English take several", p.MaxBytes),
+		})
+	}
+	if characterClasses(password) < p.MinCharacterClasses {
+		violations = append(violations, PasswordViolation{
+			Rule: PasswordRuleCharacterClasses,
+			Message: fmt.Sprintf("password must mix at least %d of lowercase letters, uppercase letters, digits and symbols",
+				p.MinCharacterClasses),
+		})
+	}
+	lower := strings.ToLower(password)
+	if p.RejectPersonalInfo && containsPersonalInfo(lower, user) {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRulePersonalInfo,
+			Message: "password must not contain your name or email",
+		})
+	}
+	if _, ok := p.Blocklist[lower]; ok {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleBlocklist,
+			Message: "password is too common",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &WeakPasswordError{Violations: violations}
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, has := range []bool{lower, upper, digit, other} {
+		if has {
+			classes++
+		}
+	}
+	return classes
+}
+
+// containsPersonalInfo reports whether the lowercased password contains a
+// word of the user's name or the local part of their email.
+func containsPersonalInfo(password string, user User) bool {
+	parts := strings.Fields(strings.ToLower(user.Name))
+	if local, _, ok := strings.Cut(NormalizeEmail(user.Email), "@"); ok {
+		parts = append(parts, local)
+	}
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= personalInfoMinLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
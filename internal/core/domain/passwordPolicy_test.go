@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:           8,
+		MaxBytes:            24,
+		MinCharacterClasses: 2,
+		RejectPersonalInfo:  true,
+		Blocklist:           map[string]struct{}{"password1": {}},
+	}
+	user := User{Name: "One1 Al", Email: "yean.dev@gmail.com"}
+
+	tests := []struct {
+		Name     string
+		Password string
+		Rules    []string
+	}{
+		{Name: "strong", Password: "correct horse battery", Rules: nil},
+		{Name: "too short", Password: "a1b2c3", Rules: []string{PasswordRuleMinLength}},
+		{Name: "length counts characters, not bytes", Password: "รหัส1234", Rules: nil},
+		{Name: "too long", Password: "correct horse battery staple", Rules: []string{PasswordRuleMaxLength}},
+		{Name: "maximum counts bytes", Password: "รหัสผ่าน12", Rules: []string{PasswordRuleMaxLength}},
+		{Name: "single class", Password: "passwordkrub", Rules: []string{PasswordRuleCharacterClasses}},
+		{Name: "contains name", Password: "my-ONE1-secret", Rules: []string{PasswordRulePersonalInfo}},
+		{Name: "contains email", Password: "Yean.dev2025", Rules: []string{PasswordRulePersonalInfo}},
+		{Name: "short name parts are ignored", Password: "always-2025", Rules: nil},
+		{Name: "blocked regardless of case", Password: "PassWord1", Rules: []string{PasswordRuleBlocklist}},
+		{Name: "every broken rule", Password: "one1", Rules: []string{PasswordRuleMinLength, PasswordRulePersonalInfo}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			err := policy.Check(test.Password, user)
+			if test.Rules == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrWeakPassword)
+			var weak *WeakPasswordError
+			require.True(t, errors.As(err, &weak))
+			var rules []string
+			for _, violation := range weak.Violations {
+				rules = append(rules, violation.Rule)
+			}
+			assert.Equal(t, test.Rules, rules)
+		})
+	}
+
+	assert.NoError(t, PasswordPolicy{}.Check("1", user), "the zero policy accepts anything")
+}
+
+func TestWeakPasswordError(t *testing.T) {
+	err := &WeakPasswordError{Violations: []PasswordViolation{
+		{Rule: PasswordRuleMinLength, Message: "password must be at least 8 characters"},
+		{Rule: PasswordRuleBlocklist, Message: "password is too common"},
+	}}
+
+	assert.Equal(t, "password does not meet the password policy: password must be at least 8 characters; password is too common", err.Error())
+	assert.False(t, errors.Is(err, ErrValidation))
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+type PasswordResetToken struct {
+	ID        string    `json:"id" bson:"id"`                 // auto-generated
+	UserID    string    `json:"user_id" bson:"user_id"`       // owner of the token
+	TokenHash string    `json:"-" bson:"token_hash"`          // sha256 of the raw token
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"` // timestamp
+	CreatedAt time.Time `json:"created_at" bson:"created_at"` // timestamp
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
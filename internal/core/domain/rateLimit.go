@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit is a token bucket holding up to Burst tokens that refills Burst
+// tokens every Period. Every request takes one token.
+type RateLimit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseRateLimit reads limits written as "<burst>/<period>", e.g. "5/1m". An
+// empty spec or "off" disables the limit and returns nil.
+func ParseRateLimit(spec string) (*RateLimit, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		return nil, nil
+	}
+	burst, period, ok := strings.Cut(spec, "/")
+	if !ok {
+		return nil, fmt.Errorf("rate limit %q: want <burst>/<period>", spec)
+	}
+	limit := RateLimit{}
+	var err error
+	if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst < 1 {
+		return nil, fmt.Errorf("rate limit %q: burst must be a positive integer", spec)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return nil, fmt.Errorf("rate limit %q: period must be a positive duration", spec)
+	}
+	return &limit, nil
+}
+
+// RefillRate is the number of tokens added per second.
+func (l RateLimit) RefillRate() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// TokenBucket is the stored state of one key's bucket.
+type TokenBucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Take refills bucket for the time passed since it was last updated and takes
+// a token if one is left. A zero bucket is a new, full one.
+func (l RateLimit) Take(bucket TokenBucket, now time.Time) (TokenBucket, RateLimitResult) {
+	tokens := float64(l.Burst)
+	if !bucket.UpdatedAt.IsZero() {
+		elapsed := math.Max(0, now.Sub(bucket.UpdatedAt).Seconds())
+		tokens = math.Min(float64(l.Burst), bucket.Tokens+elapsed*l.RefillRate())
+	}
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	return TokenBucket{Tokens: tokens, UpdatedAt: now}, l.Result(tokens, allowed)
+}
+
+// RateLimitResult is the outcome of taking a token, in the terms of the
+// RateLimit-* response headers.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next token, zero when allowed.
+	RetryAfter time.Duration
+}
+
+// Result describes a bucket holding tokens after a take that was allowed or not.
+func (l RateLimit) Result(tokens float64, allowed bool) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     l.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     l.timeToRefill(float64(l.Burst) - tokens),
+	}
+	if !allowed {
+		result.RetryAfter = l.timeToRefill(1 - tokens)
+	}
+	return result
+}
+
+func (l RateLimit) timeToRefill(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / l.RefillRate() * float64(time.Second)))
+}
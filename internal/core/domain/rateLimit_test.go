@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := ParseRateLimit("5/1m")
+	require.NoError(t, err)
+	assert.Equal(t, &RateLimit{Burst: 5, Period: time.Minute}, limit)
+
+	for _, spec := range []string{"", "off"} {
+		limit, err := ParseRateLimit(spec)
+		assert.NoError(t, err)
+		assert.Nil(t, limit)
+	}
+
+	for _, spec := range []string{"5", "0/1m", "x/1m", "5/never", "5/-1s"} {
+		_, err := ParseRateLimit(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestRateLimitTake(t *testing.T) {
+	limit := RateLimit{Burst: 2, Period: 10 * time.Second}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	bucket, result := limit.Take(TokenBucket{}, now)
+	assert.Equal(t, RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: 5 * time.Second}, result)
+
+	bucket, result = limit.Take(bucket, now)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 10*time.Second, result.Reset)
+
+	bucket, result = limit.Take(bucket, now.Add(time.Second))
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 4*time.Second, result.RetryAfter)
+
+	// Five seconds after the last full take one token is back.
+	_, result = limit.Take(bucket, now.Add(5*time.Second))
+	assert.True(t, result.Allowed)
+
+	// The bucket never refills past the burst.
+	_, result = limit.Take(bucket, now.Add(time.Hour))
+	assert.Equal(t, 1, result.Remaining)
+}
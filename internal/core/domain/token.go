@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// AuthTokens is the result of a login. Users with two-factor authentication
+// only get an MFAToken from the password step, to be exchanged for the others
+// with a two-factor code.
+type AuthTokens struct {
+	AccessToken  string `json:"jwToken,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	MFAToken     string `json:"mfaToken,omitempty"`
+}
+
+type RefreshToken struct {
+	ID         string    `json:"id" bson:"id"`                   // auto-generated
+	UserID     string    `json:"user_id" bson:"user_id"`         // owner of the token
+	FamilyID   string    `json:"family_id" bson:"family_id"`     // shared by every rotation of a login
+	TokenHash  string    `json:"-" bson:"token_hash"`            // sha256 of the raw token
+	Revoked    bool      `json:"revoked" bson:"revoked"`         // set once rotated or revoked
+	ReplacedBy string    `json:"replaced_by" bson:"replaced_by"` // id of the rotated token
+	ExpiresAt  time.Time `json:"expires_at" bson:"expires_at"`   // timestamp
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`   // timestamp
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
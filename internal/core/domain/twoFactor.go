@@ -0,0 +1,27 @@
+package domain
+
+// TwoFactorEnrollment is what an authenticator app needs to add the account,
+// URI is usually shown as a QR code.
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// ConfirmTwoFactorRequest takes the password, so a stolen access token alone
+// can't put the attacker's authenticator app on the account.
+type ConfirmTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// DisableTwoFactorRequest takes a code from the authenticator app or a recovery code.
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// LoginTwoFactorRequest takes a code from the authenticator app or a recovery code.
+type LoginTwoFactorRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
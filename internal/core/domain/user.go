@@ -1,16 +1,40 @@
 package domain
 
 import (
-	"errors"
+	"strings"
 	"time"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID        string    `json:"id,omitempty" bson:"id" `                      // auto-generated
 	Name      string    `json:"name" bson:"name" validate:"required"`         // string
 	Email     string    `json:"email" bson:"email" validate:"required,email"` // unique
 	Password  string    `json:"password" bson:"password" validate:"required"` // hashed
+	Role      string    `json:"role" bson:"role"`                             // user or admin
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`                 // timestamp
+
+	EmailVerified     bool      `json:"email_verified" bson:"email_verified"`                     // set once the email is confirmed
+	PasswordChangedAt time.Time `json:"password_changed_at" bson:"password_changed_at,omitempty"` // timestamp
+
+	TwoFactorEnabled bool     `json:"two_factor_enabled" bson:"two_factor_enabled"` // set once an authenticator app is confirmed
+	TOTPSecret       string   `json:"-" bson:"totp_secret,omitempty"`               // base32, pending until TwoFactorEnabled
+	TOTPLastStep     int64    `json:"-" bson:"totp_last_step,omitempty"`            // newest step a code was accepted for
+	RecoveryCodes    []string `json:"-" bson:"recovery_codes,omitempty"`            // sha256 of the unused recovery codes
+}
+
+type LoginUser struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
 }
 
 type EditUser struct {
@@ -18,9 +42,48 @@ type EditUser struct {
 	Email string `json:"email,omitempty" bson:"email" validate:"omitempty,email"` // unique
 }
 
+// UserPatch is a partial update of a user, nil fields are left unchanged.
+type UserPatch struct {
+	Name     *string
+	Email    *string
+	Password *string // hashed
+
+	PasswordChangedAt *time.Time
+	EmailVerified     *bool
+	TwoFactorEnabled  *bool
+	TOTPSecret        *string
+	TOTPLastStep      *int64
+	RecoveryCodes     *[]string // hashed
+
+	// The Expected fields make the patch conditional, it only applies while
+	// the stored values still equal them and reports ErrUserNotFound otherwise.
+	ExpectedPassword      *string
+	ExpectedTOTPLastStep  *int64
+	ExpectedRecoveryCodes *[]string
+}
+
+func (p UserPatch) IsEmpty() bool {
+	return p.Name == nil && p.Email == nil && p.Password == nil && p.PasswordChangedAt == nil && p.EmailVerified == nil &&
+		p.TwoFactorEnabled == nil && p.TOTPSecret == nil && p.TOTPLastStep == nil && p.RecoveryCodes == nil
+}
+
+// NormalizeEmail is the form emails are compared in, so "One@Gmail.com" and
+// "one@gmail.com" belong to the same account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// UserRole returns the user's role, treating users stored before roles existed as RoleUser.
+func (u *User) UserRole() string {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
+}
+
 func (u *User) ValidateEmailAndName() error {
 	if u.Email == "" && u.Name == "" {
-		return errors.New("name and email cannot be empty")
+		return NewValidationError("name and email cannot be empty")
 	}
 	return nil
 }
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	SortByCreatedAt = "created_at"
+	SortByName      = "name"
+
+	DefaultUserPageLimit = 20
+	MaxUserPageLimit     = 100
+)
+
+// UserQuery selects a page of users. Zero values mean "no filter".
+type UserQuery struct {
+	Limit       int
+	SortBy      string
+	Descending  bool
+	NamePrefix  string
+	EmailDomain string
+	CreatedFrom time.Time // inclusive
+	CreatedTo   time.Time // exclusive
+	After       *UserCursor
+}
+
+type UserPage struct {
+	Users      []User
+	Limit      int
+	NextCursor string
+}
+
+// UserCursor is the position after the last user of a page. It is handed to
+// clients as an opaque string and carries the sort it was created for.
+type UserCursor struct {
+	SortBy     string    `json:"s"`
+	Descending bool      `json:"d,omitempty"`
+	Name       string    `json:"n,omitempty"`
+	CreatedAt  time.Time `json:"c"`
+	ID         string    `json:"i"`
+}
+
+// Normalize applies the defaults and rejects invalid queries.
+func (q *UserQuery) Normalize() error {
+	if q.After != nil {
+		if q.SortBy == "" {
+			q.SortBy = q.After.SortBy
+			q.Descending = q.After.Descending
+		}
+		if q.After.SortBy != q.SortBy || q.After.Descending != q.Descending {
+			return NewValidationError("cursor does not match the requested sort")
+		}
+	}
+	if q.SortBy == "" {
+		q.SortBy = SortByCreatedAt
+	}
+	if q.SortBy != SortByCreatedAt && q.SortBy != SortByName {
+		return NewValidationError("sort must be one of created_at, name")
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultUserPageLimit
+	}
+	if q.Limit < 0 || q.Limit > MaxUserPageLimit {
+		return NewValidationError("limit must be between 1 and 100")
+	}
+	if !q.CreatedFrom.IsZero() && !q.CreatedTo.IsZero() && !q.CreatedFrom.Before(q.CreatedTo) {
+		return NewValidationError("created_from must be before created_to")
+	}
+	q.EmailDomain = strings.TrimPrefix(q.EmailDomain, "@")
+	return nil
+}
+
+// Matches reports whether the user passes the filters and lies after the cursor.
+func (q UserQuery) Matches(user User) bool {
+	if q.NamePrefix != "" && !strings.HasPrefix(user.Name, q.NamePrefix) {
+		return false
+	}
+	if q.EmailDomain != "" && !strings.HasSuffix(strings.ToLower(user.Email), "@"+strings.ToLower(q.EmailDomain)) {
+		return false
+	}
+	if !q.CreatedFrom.IsZero() && user.CreatedAt.Before(q.CreatedFrom) {
+		return false
+	}
+	if !q.CreatedTo.IsZero() && !user.CreatedAt.Before(q.CreatedTo) {
+		return false
+	}
+	if q.After != nil {
+		cursorUser := User{ID: q.After.ID, Name: q.After.Name, CreatedAt: q.After.CreatedAt}
+		return q.Less(cursorUser, user)
+	}
+	return true
+}
+
+// Less orders users by the sort field, then by ID so the order is total.
+func (q UserQuery) Less(a, b User) bool {
+	var cmp int
+	switch q.SortBy {
+	case SortByName:
+		cmp = strings.Compare(a.Name, b.Name)
+	default:
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.ID, b.ID)
+	}
+	if q.Descending {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func NewUserCursor(user User, q UserQuery) UserCursor {
+	cursor := UserCursor{
+		SortBy:     q.SortBy,
+		Descending: q.Descending,
+		CreatedAt:  user.CreatedAt,
+		ID:         user.ID,
+	}
+	if q.SortBy == SortByName {
+		cursor.Name = user.Name
+	}
+	return cursor
+}
+
+func (c UserCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeUserCursor(encoded string) (*UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, NewValidationError("invalid cursor")
+	}
+	var cursor UserCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, NewValidationError("invalid cursor")
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserQueryNormalize(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		query := UserQuery{EmailDomain: "@gmail.com"}
+		assert.NoError(t, query.Normalize())
+		assert.Equal(t, DefaultUserPageLimit, query.Limit)
+		assert.Equal(t, SortByCreatedAt, query.SortBy)
+		assert.Equal(t, "gmail.com", query.EmailDomain)
+	})
+
+	t.Run("cursor brings its sort", func(t *testing.T) {
+		query := UserQuery{After: &UserCursor{SortBy: SortByName, Descending: true, ID: "1"}}
+		assert.NoError(t, query.Normalize())
+		assert.Equal(t, SortByName, query.SortBy)
+		assert.True(t, query.Descending)
+	})
+
+	invalid := []struct {
+		Name  string
+		Query UserQuery
+	}{
+		{Name: "unknown sort", Query: UserQuery{SortBy: "email"}},
+		{Name: "limit too large", Query: UserQuery{Limit: MaxUserPageLimit + 1}},
+		{Name: "negative limit", Query: UserQuery{Limit: -1}},
+		{Name: "empty created range", Query: UserQuery{CreatedFrom: time.Unix(2, 0), CreatedTo: time.Unix(1, 0)}},
+		{Name: "cursor for another sort", Query: UserQuery{SortBy: SortByCreatedAt, After: &UserCursor{SortBy: SortByName, ID: "1"}}},
+	}
+	for _, test := range invalid {
+		t.Run(test.Name, func(t *testing.T) {
+			query := test.Query
+			assert.ErrorIs(t, query.Normalize(), ErrValidation)
+		})
+	}
+}
+
+func TestUserQueryLess(t *testing.T) {
+	older := User{ID: "2", Name: "bob", CreatedAt: time.Unix(1, 0)}
+	newer := User{ID: "1", Name: "alice", CreatedAt: time.Unix(2, 0)}
+	sameTime := User{ID: "3", Name: "carol", CreatedAt: time.Unix(1, 0)}
+
+	byCreatedAt := UserQuery{SortBy: SortByCreatedAt}
+	assert.True(t, byCreatedAt.Less(older, newer))
+	assert.True(t, byCreatedAt.Less(older, sameTime), "ties are broken by id")
+
+	byName := UserQuery{SortBy: SortByName}
+	assert.True(t, byName.Less(newer, older))
+
+	descending := UserQuery{SortBy: SortByCreatedAt, Descending: true}
+	assert.True(t, descending.Less(newer, older))
+}
+
+func TestUserCursorRoundTrip(t *testing.T) {
+	user := User{ID: "123", Name: "One1 yean", CreatedAt: time.Date(2025, 6, 2, 18, 2, 12, 65000000, time.UTC)}
+
+	cursor := NewUserCursor(user, UserQuery{SortBy: SortByName, Descending: true})
+	decoded, err := DecodeUserCursor(cursor.Encode())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "123", decoded.ID)
+	assert.Equal(t, "One1 yean", decoded.Name)
+	assert.True(t, decoded.CreatedAt.Equal(user.CreatedAt))
+	assert.True(t, decoded.Descending)
+
+	_, err = DecodeUserCursor("not a cursor")
+	assert.ErrorIs(t, err, ErrValidation)
+	_, err = DecodeUserCursor("e30")
+	assert.ErrorIs(t, err, ErrValidation)
+}
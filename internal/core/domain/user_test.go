@@ -46,3 +46,25 @@ func TestValidateEmailAndName(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRole(t *testing.T) {
+	assert.Equal(t, RoleUser, (&User{}).UserRole())
+	assert.Equal(t, RoleUser, (&User{Role: RoleUser}).UserRole())
+	assert.Equal(t, RoleAdmin, (&User{Role: RoleAdmin}).UserRole())
+}
+
+func TestUserPatchIsEmpty(t *testing.T) {
+	name := "One1 yean"
+
+	assert.True(t, UserPatch{}.IsEmpty())
+	assert.False(t, UserPatch{Name: &name}.IsEmpty())
+	assert.False(t, UserPatch{Email: &name}.IsEmpty())
+	assert.False(t, UserPatch{Password: &name}.IsEmpty())
+	assert.False(t, UserPatch{TOTPSecret: &name}.IsEmpty())
+	assert.True(t, UserPatch{ExpectedPassword: &name}.IsEmpty(), "a condition alone changes nothing")
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	assert.Equal(t, "test@gmail.com", NormalizeEmail(" Test@Gmail.COM "))
+	assert.Equal(t, NormalizeEmail("one@gmail.com"), NormalizeEmail("ONE@gmail.com"))
+}
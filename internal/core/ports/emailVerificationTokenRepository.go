@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"time"
+)
+
+type EmailVerificationTokenRepository interface {
+	Save(ctx context.Context, token domain.EmailVerificationToken) error
+	// Consume removes and returns the token with tokenHash if it is still valid
+	// at now, so every token works once. Anything else is
+	// domain.ErrEmailVerificationTokenNotFound.
+	Consume(ctx context.Context, tokenHash string, now time.Time) (*domain.EmailVerificationToken, error)
+	DeleteUserTokens(ctx context.Context, userID string) error
+}
@@ -0,0 +1,10 @@
+package ports
+
+import "context"
+
+// HealthChecker is a dependency the service needs to serve traffic, such as a
+// database. Check returns an error when the dependency is unreachable.
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
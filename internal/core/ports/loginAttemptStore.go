@@ -0,0 +1,17 @@
+package ports
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"time"
+)
+
+// LoginAttemptStore counts failed logins per key, such as an account or a
+// client IP. RecordFailure must be atomic per key, so concurrent guesses all
+// count.
+type LoginAttemptStore interface {
+	// Get returns the zero LoginAttempts for keys without failures.
+	Get(ctx context.Context, key string) (domain.LoginAttempts, error)
+	RecordFailure(ctx context.Context, key string, policy domain.LockoutPolicy, now time.Time) (domain.LoginAttempts, error)
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,11 @@
+package ports
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+)
+
+// Notifier delivers messages to users, for instance by email.
+type Notifier interface {
+	Notify(ctx context.Context, message domain.Message) error
+}
@@ -0,0 +1,10 @@
+package ports
+
+// PasswordHasher hashes passwords into self-describing strings that carry
+// their algorithm and parameters.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash, and whether hash was made
+	// with an outdated algorithm or parameters and should be replaced by a new Hash.
+	Verify(password, hash string) (ok bool, needsRehash bool, err error)
+}
@@ -0,0 +1,19 @@
+package ports
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+	"time"
+)
+
+type PasswordResetTokenRepository interface {
+	Save(ctx context.Context, token domain.PasswordResetToken) error
+	// Get returns the token with tokenHash if it is still valid at now, without
+	// using it up. Anything else is domain.ErrPasswordResetTokenNotFound.
+	Get(ctx context.Context, tokenHash string, now time.Time) (*domain.PasswordResetToken, error)
+	// Consume removes and returns the token with tokenHash if it is still valid
+	// at now, so every token works once. Anything else is
+	// domain.ErrPasswordResetTokenNotFound.
+	Consume(ctx context.Context, tokenHash string, now time.Time) (*domain.PasswordResetToken, error)
+	DeleteUserTokens(ctx context.Context, userID string) error
+}
@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+)
+
+// RateLimitStore keeps one token bucket per key. Take must be atomic per key,
+// so concurrent requests can't spend the same token.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit domain.RateLimit) (domain.RateLimitResult, error)
+}
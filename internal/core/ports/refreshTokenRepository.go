@@ -0,0 +1,15 @@
+package ports
+
+import (
+	"context"
+	"one1-be-chal/internal/core/domain"
+)
+
+type RefreshTokenRepository interface {
+	Save(ctx context.Context, token domain.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error)
+	// Revoke marks a live token as revoked and reports whether this call revoked it.
+	Revoke(ctx context.Context, id string, replacedBy string) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeUser(ctx context.Context, userID string) error
+}
@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// TokenRevocationStore is the denylist for access tokens that must stop
+// working before they expire. Entries only need to live until expiresAt,
+// after which the token is rejected on expiry anyway.
+type TokenRevocationStore interface {
+	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	// RevokeUserTokens revokes every token of the user issued before revokedAt.
+	RevokeUserTokens(ctx context.Context, userID string, revokedAt time.Time, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenID string, userID string, issuedAt time.Time) (bool, error)
+}
@@ -3,15 +3,14 @@ package ports
 import (
 	"context"
 	"one1-be-chal/internal/core/domain"
-
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 type UserRepository interface {
 	Save(ctx context.Context, user domain.User) error
 	GetUserByID(ctx context.Context, id string) (domain.User, error)
-	GetAllUsers(ctx context.Context) ([]domain.User, error)
-	UpdateUser(ctx context.Context, id string, user bson.M) error
+	// GetAllUsers returns at most query.Limit users matching the query, in the query's order.
+	GetAllUsers(ctx context.Context, query domain.UserQuery) ([]domain.User, error)
+	UpdateUser(ctx context.Context, id string, patch domain.UserPatch) error
 	DeleteUser(ctx context.Context, id string) error
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
 	GetUserCount(ctx context.Context) (int64, error)
@@ -4,13 +4,26 @@ import (
 	"context"
 	"one1-be-chal/internal/adapters/config"
 	"one1-be-chal/internal/core/domain"
+	"time"
 )
 
 type UserService interface {
-	Register(ctx context.Context, user domain.User, config config.Container) (string, error)
+	Register(ctx context.Context, user domain.User, config config.Container) (domain.AuthTokens, error)
+	Login(ctx context.Context, email, password, clientIP string, config config.Container) (domain.AuthTokens, error)
+	LoginTwoFactor(ctx context.Context, mfaToken, code, clientIP string, config config.Container) (domain.AuthTokens, error)
+	RefreshToken(ctx context.Context, refreshToken string, config config.Container) (domain.AuthTokens, error)
+	Logout(ctx context.Context, userID, tokenID string, expiresAt time.Time, refreshToken string) error
 	GetUserByID(ctx context.Context, id string) (domain.User, error)
-	GetAllUsers(ctx context.Context) ([]domain.User, error)
-	UpdateUser(ctx context.Context, id string, user domain.User) error
+	GetAllUsers(ctx context.Context, query domain.UserQuery) (domain.UserPage, error)
+	UpdateUser(ctx context.Context, id string, user domain.User, config config.Container) error
 	DeleteUser(ctx context.Context, id string) error
-	LogTotalUser(ctx context.Context)
+	UnlockUser(ctx context.Context, id string) error
+	ForgotPassword(ctx context.Context, email string, config config.Container) error
+	ResetPassword(ctx context.Context, token, password string, config config.Container) error
+	ChangePassword(ctx context.Context, id, currentPassword, newPassword string, config config.Container) (domain.AuthTokens, error)
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, id string, config config.Container) error
+	EnrollTwoFactor(ctx context.Context, id string, config config.Container) (domain.TwoFactorEnrollment, error)
+	ConfirmTwoFactor(ctx context.Context, id, password, code string, config config.Container) ([]string, error)
+	DisableTwoFactor(ctx context.Context, id, password, code string, config config.Container) error
 }
@@ -0,0 +1,23 @@
+package services
+
+import (
+	"one1-be-chal/internal/core/domain"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer only uses the OpenTelemetry API, spans are dropped unless the
+// adapters install a tracer provider.
+var tracer = otel.Tracer("one1-be-chal/internal/core/services")
+
+// endSpan ends span, marking it failed for errors other than the domain's
+// expected answers such as invalid credentials.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !domain.IsExpected(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -3,25 +3,60 @@ package services
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"one1-be-chal/internal/adapters/config"
 	"one1-be-chal/internal/adapters/helpers"
 	"one1-be-chal/internal/core/domain"
 	"one1-be-chal/internal/core/ports"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type UserServiceImpl struct {
-	UserRepository ports.UserRepository
+	UserRepository          ports.UserRepository
+	RefreshTokenRepository  ports.RefreshTokenRepository
+	TokenRevocationStore    ports.TokenRevocationStore
+	LoginAttemptStore       ports.LoginAttemptStore
+	PasswordResetTokens     ports.PasswordResetTokenRepository
+	EmailVerificationTokens ports.EmailVerificationTokenRepository
+	Notifier                ports.Notifier
+	PasswordHasher          ports.PasswordHasher
+
+	// now is the clock of two-factor codes and logins, tests replace it.
+	now func() time.Time
+	// dummyHash is verified against for unknown emails, so logins take as long
+	// whether the email is registered or not.
+	dummyHash func() (string, error)
 }
 
-func NewUserService(userRepository ports.UserRepository) ports.UserService {
+func NewUserService(
+	userRepository ports.UserRepository,
+	refreshTokenRepository ports.RefreshTokenRepository,
+	tokenRevocationStore ports.TokenRevocationStore,
+	loginAttemptStore ports.LoginAttemptStore,
+	passwordResetTokens ports.PasswordResetTokenRepository,
+	emailVerificationTokens ports.EmailVerificationTokenRepository,
+	notifier ports.Notifier,
+	passwordHasher ports.PasswordHasher,
+) ports.UserService {
 	return &UserServiceImpl{
-		UserRepository: userRepository,
+		UserRepository:          userRepository,
+		RefreshTokenRepository:  refreshTokenRepository,
+		TokenRevocationStore:    tokenRevocationStore,
+		LoginAttemptStore:       loginAttemptStore,
+		PasswordResetTokens:     passwordResetTokens,
+		EmailVerificationTokens: emailVerificationTokens,
+		Notifier:                notifier,
+		PasswordHasher:          passwordHasher,
+		now:                     time.Now,
+		dummyHash: sync.OnceValues(func() (string, error) {
+			return passwordHasher.Hash(uuid.NewString())
+		}),
 	}
 }
 
@@ -29,86 +64,1091 @@ func (s *UserServiceImpl) Register(
 	ctx context.Context,
 	user domain.User,
 	config config.Container,
-) (string, error) {
+) (tokens domain.AuthTokens, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Register")
+	defer func() { endSpan(span, err) }()
+
+	if err := checkPassword(user.Password, user, config.PasswordPolicy); err != nil {
+		return domain.AuthTokens{}, err
+	}
+
+	// The lookup only saves a password hash on obvious duplicates; Save is what
+	// enforces uniqueness when two registrations race.
 	existUser, err := s.UserRepository.GetUserByEmail(ctx, user.Email)
-	if err != nil && err != mongo.ErrNoDocuments {
-		return "", err
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return domain.AuthTokens{}, err
 	}
 	if existUser != nil {
-		return "", errors.New("email already exist")
+		return domain.AuthTokens{}, domain.ErrEmailTaken
 	}
 
-	hashedPassword, err := helpers.HashPassword(user.Password)
+	_, hashSpan := tracer.Start(ctx, "HashPassword")
+	hashedPassword, err := s.PasswordHasher.Hash(user.Password)
+	hashSpan.End()
 	if err != nil {
-		return "", err
+		return domain.AuthTokens{}, err
 	}
 
-	user.ID = uuid.NewString()
-	user.Password = hashedPassword
-	user.CreatedAt = time.Now()
+	// Only the name, email and password come from the client, everything else
+	// such as the role or two-factor state starts out fresh.
+	user = domain.User{
+		ID:        uuid.NewString(),
+		Name:      user.Name,
+		Email:     user.Email,
+		Password:  hashedPassword,
+		Role:      domain.RoleUser,
+		CreatedAt: time.Now(),
+	}
+	// Without email verification enabled, emails are trusted as given.
+	user.EmailVerified = config.EmailVerification == nil
 
 	if err := s.UserRepository.Save(ctx, user); err != nil {
-		return "", err
+		return domain.AuthTokens{}, err
+	}
+
+	slog.InfoContext(ctx, "user registered", "registered_user_id", user.ID)
+	if !user.EmailVerified {
+		// The account exists either way, the user can ask for another message.
+		if err := s.sendEmailVerification(ctx, user, config.EmailVerification); err != nil {
+			slog.WarnContext(ctx, "sending email verification failed", "registered_user_id", user.ID, "error", err)
+		}
+	}
+	return s.issueTokens(ctx, user, uuid.NewString(), "", config)
+}
+
+// Login checks the password unless the account or the client IP failed too
+// often lately, see domain.LockoutPolicy. A successful login clears the
+// account's failures, see resetLoginAttempts.
+// Users with two-factor authentication only get an MFA token, see LoginTwoFactor.
+func (s *UserServiceImpl) Login(
+	ctx context.Context,
+	email, password, clientIP string,
+	config config.Container,
+) (tokens domain.AuthTokens, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Login")
+	defer func() { endSpan(span, err) }()
+
+	now := s.now()
+	attemptKeys := loginAttemptKeys(config.Lockout, email, clientIP)
+	if err := s.checkLoginAttempts(ctx, attemptKeys, now); err != nil {
+		return domain.AuthTokens{}, err
+	}
+
+	user, err := s.UserRepository.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return domain.AuthTokens{}, err
+	}
+	_, checkSpan := tracer.Start(ctx, "CheckPasswordHash")
+	passwordMatches, needsRehash, err := s.verifyPassword(password, user)
+	checkSpan.End()
+	if err != nil {
+		return domain.AuthTokens{}, err
+	}
+	if !passwordMatches {
+		slog.InfoContext(ctx, "login failed", "known_user", user != nil)
+		if err := s.recordLoginFailure(ctx, attemptKeys, user, clientIP, now); err != nil {
+			return domain.AuthTokens{}, err
+		}
+		return domain.AuthTokens{}, domain.ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		s.rehashPassword(ctx, *user, password)
+	}
+	if user.TwoFactorEnabled {
+		// Failures are only cleared after the second factor, or knowing the
+		// password would allow guessing codes without ever being locked out.
+		mfaToken, err := helpers.GenerateMFAToken(user.ID, tokenIssueTime(*user, now), config)
+		if err != nil {
+			return domain.AuthTokens{}, err
+		}
+		slog.InfoContext(ctx, "login needs a two-factor code", "two_factor_user_id", user.ID)
+		return domain.AuthTokens{MFAToken: mfaToken}, nil
+	}
+	if err := s.resetLoginAttempts(ctx, attemptKeys); err != nil {
+		return domain.AuthTokens{}, err
 	}
+	return s.issueTokens(ctx, *user, uuid.NewString(), "", config)
+}
 
-	jwToken, err := helpers.GenerateJWT(user.ID, user.Name, user.Email, config)
+// LoginTwoFactor finishes the login of a user with two-factor authentication,
+// exchanging the MFA token from Login and a code from their authenticator app
+// or a recovery code for the tokens. Wrong codes count as failed logins.
+func (s *UserServiceImpl) LoginTwoFactor(
+	ctx context.Context,
+	mfaToken, code, clientIP string,
+	config config.Container,
+) (tokens domain.AuthTokens, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.LoginTwoFactor")
+	defer func() { endSpan(span, err) }()
+
+	now := s.now()
+	claims, err := helpers.ParseMFAToken(mfaToken, now, config)
+	if err != nil {
+		return domain.AuthTokens{}, domain.ErrInvalidMFAToken
+	}
+	user, err := s.UserRepository.GetUserByID(ctx, claims.ID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return domain.AuthTokens{}, domain.ErrInvalidMFAToken
+	}
+	if err != nil {
+		return domain.AuthTokens{}, err
+	}
+	// The password step no longer counts once the password changed or
+	// two-factor authentication was turned off.
+	if !user.TwoFactorEnabled || claims.IssuedAt == nil || claims.IssuedAt.Before(sessionsRevokedBefore(user.PasswordChangedAt)) {
+		return domain.AuthTokens{}, domain.ErrInvalidMFAToken
+	}
+
+	attemptKeys := loginAttemptKeys(config.Lockout, user.Email, clientIP)
+	if err := s.checkLoginAttempts(ctx, attemptKeys, now); err != nil {
+		return domain.AuthTokens{}, err
+	}
+	if err := s.useTwoFactorCode(ctx, user, code, now); err != nil {
+		if errors.Is(err, domain.ErrInvalidTwoFactorCode) {
+			slog.InfoContext(ctx, "two-factor login failed", "two_factor_user_id", user.ID)
+			if err := s.recordLoginFailure(ctx, attemptKeys, &user, clientIP, now); err != nil {
+				return domain.AuthTokens{}, err
+			}
+		}
+		return domain.AuthTokens{}, err
+	}
+
+	if err := s.resetLoginAttempts(ctx, attemptKeys); err != nil {
+		return domain.AuthTokens{}, err
+	}
+	return s.issueTokens(ctx, user, uuid.NewString(), "", config)
+}
+
+// resetLoginAttempts clears the account's failures after a successful login.
+// The IP's failures are left to expire, or an attacker could clear them by
+// logging into an account of their own between guesses at others.
+func (s *UserServiceImpl) resetLoginAttempts(ctx context.Context, keys []loginAttemptKey) error {
+	for _, attemptKey := range keys {
+		if attemptKey.scope != "account" {
+			continue
+		}
+		if err := s.LoginAttemptStore.Reset(ctx, attemptKey.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyPassword checks password against the hash of user, which is nil for
+// unknown emails. Those are checked against a dummy hash that never matches,
+// so the time taken doesn't reveal whether the email is registered.
+func (s *UserServiceImpl) verifyPassword(password string, user *domain.User) (ok, needsRehash bool, err error) {
+	if user == nil {
+		dummyHash, err := s.dummyHash()
+		if err != nil {
+			return false, false, err
+		}
+		_, _, err = s.PasswordHasher.Verify(password, dummyHash)
+		return false, false, err
+	}
+	return s.PasswordHasher.Verify(password, user.Password)
+}
+
+// rehashPassword replaces the outdated hash of user after a successful login.
+// The login goes on either way, a failed rehash is retried on the next one.
+func (s *UserServiceImpl) rehashPassword(ctx context.Context, user domain.User, password string) {
+	_, hashSpan := tracer.Start(ctx, "HashPassword")
+	hashedPassword, err := s.PasswordHasher.Hash(password)
+	hashSpan.End()
+	if err == nil {
+		// Only replace the hash that was verified, in case the password changed meanwhile.
+		patch := domain.UserPatch{Password: &hashedPassword, ExpectedPassword: &user.Password}
+		err = s.UserRepository.UpdateUser(ctx, user.ID, patch)
+	}
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		slog.InfoContext(ctx, "password rehash skipped, the hash changed meanwhile", "rehashed_user_id", user.ID)
+	case err != nil:
+		slog.WarnContext(ctx, "password rehash failed", "rehashed_user_id", user.ID, "error", err)
+	default:
+		slog.InfoContext(ctx, "password rehashed", "rehashed_user_id", user.ID)
+	}
+}
+
+// loginAttemptKey is a key failed logins are counted under, with its policy.
+type loginAttemptKey struct {
+	key    string
+	scope  string // "account" or "ip"
+	policy domain.LockoutPolicy
+}
+
+// loginAttemptKeys returns no keys when lockout isn't configured. Accounts are
+// keyed by email so unknown emails are throttled like existing ones.
+func loginAttemptKeys(lockout *config.Lockout, email, clientIP string) []loginAttemptKey {
+	if lockout == nil {
+		return nil
+	}
+	keys := []loginAttemptKey{{key: accountLoginKey(email), scope: "account", policy: lockout.Account}}
+	if clientIP != "" {
+		keys = append(keys, loginAttemptKey{key: "ip:" + clientIP, scope: "ip", policy: lockout.IP})
+	}
+	return keys
+}
+
+func accountLoginKey(email string) string {
+	return "account:" + domain.NormalizeEmail(email)
+}
+
+// checkLoginAttempts returns a domain.LoginThrottledError while any key is
+// still backing off or locked.
+func (s *UserServiceImpl) checkLoginAttempts(ctx context.Context, keys []loginAttemptKey, now time.Time) error {
+	var blockedUntil time.Time
+	for _, attemptKey := range keys {
+		attempts, err := s.LoginAttemptStore.Get(ctx, attemptKey.key)
+		if err != nil {
+			return err
+		}
+		if until := attemptKey.policy.BlockedUntil(attempts, now); until.After(blockedUntil) {
+			blockedUntil = until
+		}
+	}
+	if !blockedUntil.After(now) {
+		return nil
+	}
+	slog.InfoContext(ctx, "login throttled", "retry_after", blockedUntil.Sub(now))
+	return &domain.LoginThrottledError{RetryAfter: blockedUntil.Sub(now)}
+}
+
+// recordLoginFailure counts the failure under every key, and logs the failure
+// that locks a key.
+func (s *UserServiceImpl) recordLoginFailure(
+	ctx context.Context,
+	keys []loginAttemptKey,
+	user *domain.User,
+	clientIP string,
+	now time.Time,
+) error {
+	for _, attemptKey := range keys {
+		attempts, err := s.LoginAttemptStore.RecordFailure(ctx, attemptKey.key, attemptKey.policy, now)
+		if err != nil {
+			return err
+		}
+		if attempts.Failures != attemptKey.policy.LockAfter {
+			continue
+		}
+		attrs := []any{
+			"scope", attemptKey.scope,
+			"client_ip", clientIP,
+			"failures", attempts.Failures,
+			"locked_until", now.Add(attemptKey.policy.LockDuration),
+		}
+		if user != nil && attemptKey.scope == "account" {
+			attrs = append(attrs, "locked_user_id", user.ID)
+		}
+		slog.WarnContext(ctx, "login locked", attrs...)
+	}
+	return nil
+}
+
+// RefreshToken rotates a refresh token. Presenting a token that was already
+// rotated revokes its whole family, so a stolen token stops working as soon as
+// either party uses it twice.
+func (s *UserServiceImpl) RefreshToken(
+	ctx context.Context,
+	refreshToken string,
+	config config.Container,
+) (tokens domain.AuthTokens, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.RefreshToken")
+	defer func() { endSpan(span, err) }()
+
+	current, err := s.RefreshTokenRepository.GetByHash(ctx, helpers.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			return domain.AuthTokens{}, domain.ErrInvalidRefreshToken
+		}
+		return domain.AuthTokens{}, err
+	}
+
+	if current.Revoked {
+		slog.WarnContext(ctx, "refresh token reused, revoking its family",
+			"token_user_id", current.UserID,
+			"family_id", current.FamilyID,
+		)
+		if err := s.RefreshTokenRepository.RevokeFamily(ctx, current.FamilyID); err != nil {
+			return domain.AuthTokens{}, err
+		}
+		return domain.AuthTokens{}, domain.ErrRefreshTokenReused
+	}
+	if current.IsExpired(time.Now()) {
+		return domain.AuthTokens{}, domain.ErrInvalidRefreshToken
+	}
+
+	user, err := s.UserRepository.GetUserByID(ctx, current.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.AuthTokens{}, domain.ErrInvalidRefreshToken
+		}
+		return domain.AuthTokens{}, err
+	}
+	// Changing the password revokes older refresh tokens already, this also
+	// covers a change whose revocation failed.
+	if current.CreatedAt.Before(user.PasswordChangedAt) {
+		return domain.AuthTokens{}, domain.ErrInvalidRefreshToken
+	}
+
+	nextID := uuid.NewString()
+	revoked, err := s.RefreshTokenRepository.Revoke(ctx, current.ID, nextID)
+	if err != nil {
+		return domain.AuthTokens{}, err
+	}
+	if !revoked {
+		// Another request rotated this token first.
+		slog.WarnContext(ctx, "refresh token rotated concurrently, revoking its family",
+			"token_user_id", current.UserID,
+			"family_id", current.FamilyID,
+		)
+		if err := s.RefreshTokenRepository.RevokeFamily(ctx, current.FamilyID); err != nil {
+			return domain.AuthTokens{}, err
+		}
+		return domain.AuthTokens{}, domain.ErrRefreshTokenReused
+	}
+
+	return s.issueTokens(ctx, user, nextID, current.FamilyID, config)
+}
+
+// Logout revokes the access token until it expires. When the caller also sends
+// its refresh token, the whole refresh token family is revoked with it.
+func (s *UserServiceImpl) Logout(
+	ctx context.Context,
+	userID, tokenID string,
+	expiresAt time.Time,
+	refreshToken string,
+) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Logout")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.TokenRevocationStore.RevokeToken(ctx, tokenID, expiresAt); err != nil {
+		return err
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	current, err := s.RefreshTokenRepository.GetByHash(ctx, helpers.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenNotFound) {
+			return nil
+		}
+		return err
+	}
+	if current.UserID != userID {
+		return nil
+	}
+	return s.RefreshTokenRepository.RevokeFamily(ctx, current.FamilyID)
+}
+
+// ForgotPassword sends the user a single-use token to reset their password
+// with. Unknown emails get no message, but otherwise the same work, so neither
+// the answer nor its timing reveals which emails are registered.
+func (s *UserServiceImpl) ForgotPassword(ctx context.Context, email string, config config.Container) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ForgotPassword")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.UserRepository.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return err
+	}
+
+	token, err := helpers.GenerateRandomToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	record := domain.PasswordResetToken{
+		ID:        uuid.NewString(),
+		TokenHash: helpers.HashToken(token),
+		ExpiresAt: now.Add(config.PasswordReset.TokenTTL),
+		CreatedAt: now,
+	}
+	if user != nil {
+		record.UserID = user.ID
+	}
+	// Tokens of unknown emails belong to no user, ResetPassword rejects them
+	// and they expire like any other.
+	if err := s.PasswordResetTokens.Save(ctx, record); err != nil {
+		return err
+	}
+	if user == nil {
+		slog.InfoContext(ctx, "password reset requested for unknown email")
+		return nil
+	}
+
+	message, err := passwordResetMessage(*user, token, config.PasswordReset)
+	if err != nil {
+		return err
+	}
+	if err := s.Notifier.Notify(ctx, message); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "password reset requested", "reset_user_id", user.ID)
+	return nil
+}
+
+func passwordResetMessage(user domain.User, token string, config *config.PasswordReset) (domain.Message, error) {
+	instructions := "Use this token to reset your password:\n\n" + token
+	if config.URL != "" {
+		link, err := tokenLink(config.URL, token)
+		if err != nil {
+			return domain.Message{}, err
+		}
+		instructions = "Open this link to reset your password:\n\n" + link
+	}
+	return domain.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n%s\n\nIt expires in %s and works once. "+
+			"If you didn't ask to reset your password, you can ignore this message.\n",
+			user.Name, instructions, config.TokenTTL),
+	}, nil
+}
+
+// checkPassword checks password against policy as the password of user, a nil
+// policy accepts any password.
+func checkPassword(password string, user domain.User, policy *domain.PasswordPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	return policy.Check(password, user)
+}
+
+// tokenLink adds token as the token query parameter of the frontend page at pageURL.
+func tokenLink(pageURL, token string) (string, error) {
+	link, err := url.Parse(pageURL)
 	if err != nil {
 		return "", err
 	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. Every
+// session of the user ends, and so do their other reset tokens and any login
+// lockout. A password the policy rejects leaves the token usable.
+func (s *UserServiceImpl) ResetPassword(ctx context.Context, token, password string, config config.Container) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ResetPassword")
+	defer func() { endSpan(span, err) }()
+
+	tokenHash := helpers.HashToken(token)
+	record, err := s.PasswordResetTokens.Get(ctx, tokenHash, time.Now())
+	if err != nil {
+		if errors.Is(err, domain.ErrPasswordResetTokenNotFound) {
+			return domain.ErrInvalidPasswordResetToken
+		}
+		return err
+	}
+	user, err := s.UserRepository.GetUserByID(ctx, record.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrInvalidPasswordResetToken
+		}
+		return err
+	}
+	if err := checkPassword(password, user, config.PasswordPolicy); err != nil {
+		return err
+	}
+	if _, err := s.PasswordResetTokens.Consume(ctx, tokenHash, time.Now()); err != nil {
+		if errors.Is(err, domain.ErrPasswordResetTokenNotFound) {
+			return domain.ErrInvalidPasswordResetToken
+		}
+		return err
+	}
+
+	_, hashSpan := tracer.Start(ctx, "HashPassword")
+	hashedPassword, err := s.PasswordHasher.Hash(password)
+	hashSpan.End()
+	if err != nil {
+		return err
+	}
+	changedAt := time.Now()
+	patch := domain.UserPatch{Password: &hashedPassword, PasswordChangedAt: &changedAt}
+	if err := s.UserRepository.UpdateUser(ctx, user.ID, patch); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "password reset", "reset_user_id", user.ID)
+
+	if err := s.PasswordResetTokens.DeleteUserTokens(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.LoginAttemptStore.Reset(ctx, accountLoginKey(user.Email)); err != nil {
+		return err
+	}
+	return s.revokeSessions(ctx, user.ID, sessionsRevokedBefore(changedAt))
+}
+
+// ChangePassword sets a new password for a user who knows the current one. A
+// wrong current password counts towards the account's login lockout. Every
+// session of the user ends, the caller goes on with the returned tokens.
+func (s *UserServiceImpl) ChangePassword(
+	ctx context.Context,
+	id, currentPassword, newPassword string,
+	config config.Container,
+) (tokens domain.AuthTokens, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ChangePassword")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return domain.AuthTokens{}, err
+	}
+
+	now := time.Now()
+	attemptKeys := loginAttemptKeys(config.Lockout, user.Email, "")
+	if err := s.checkLoginAttempts(ctx, attemptKeys, now); err != nil {
+		return domain.AuthTokens{}, err
+	}
+	_, checkSpan := tracer.Start(ctx, "CheckPasswordHash")
+	passwordMatches, _, err := s.PasswordHasher.Verify(currentPassword, user.Password)
+	checkSpan.End()
+	if err != nil {
+		return domain.AuthTokens{}, err
+	}
+	if !passwordMatches {
+		slog.InfoContext(ctx, "password change failed", "changed_user_id", user.ID)
+		if err := s.recordLoginFailure(ctx, attemptKeys, &user, "", now); err != nil {
+			return domain.AuthTokens{}, err
+		}
+		return domain.AuthTokens{}, domain.ErrIncorrectPassword
+	}
+	if err := checkPassword(newPassword, user, config.PasswordPolicy); err != nil {
+		return domain.AuthTokens{}, err
+	}
+
+	_, hashSpan := tracer.Start(ctx, "HashPassword")
+	hashedPassword, err := s.PasswordHasher.Hash(newPassword)
+	hashSpan.End()
+	if err != nil {
+		return domain.AuthTokens{}, err
+	}
+	changedAt := time.Now()
+	patch := domain.UserPatch{Password: &hashedPassword, PasswordChangedAt: &changedAt}
+	if err := s.UserRepository.UpdateUser(ctx, user.ID, patch); err != nil {
+		return domain.AuthTokens{}, err
+	}
+	slog.InfoContext(ctx, "password changed", "changed_user_id", user.ID)
+
+	if err := s.PasswordResetTokens.DeleteUserTokens(ctx, user.ID); err != nil {
+		return domain.AuthTokens{}, err
+	}
+	if err := s.revokeSessions(ctx, user.ID, sessionsRevokedBefore(changedAt)); err != nil {
+		return domain.AuthTokens{}, err
+	}
+	// The returned session counts from after the change, see tokenIssueTime.
+	user.PasswordChangedAt = changedAt
+	return s.issueTokens(ctx, user, uuid.NewString(), "", config)
+}
+
+// VerifyEmail marks the email of the token's user as verified. Tokens sent to
+// an address the user has changed since don't verify the new one.
+func (s *UserServiceImpl) VerifyEmail(ctx context.Context, token string) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.VerifyEmail")
+	defer func() { endSpan(span, err) }()
+
+	record, err := s.EmailVerificationTokens.Consume(ctx, helpers.HashToken(token), time.Now())
+	if err != nil {
+		if errors.Is(err, domain.ErrEmailVerificationTokenNotFound) {
+			return domain.ErrInvalidEmailVerificationToken
+		}
+		return err
+	}
+	user, err := s.UserRepository.GetUserByID(ctx, record.UserID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrInvalidEmailVerificationToken
+		}
+		return err
+	}
+	if domain.NormalizeEmail(user.Email) != domain.NormalizeEmail(record.Email) {
+		return domain.ErrInvalidEmailVerificationToken
+	}
+
+	verified := true
+	if err := s.UserRepository.UpdateUser(ctx, user.ID, domain.UserPatch{EmailVerified: &verified}); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "email verified", "verified_user_id", user.ID)
+	return s.EmailVerificationTokens.DeleteUserTokens(ctx, user.ID)
+}
+
+// ResendVerification sends the user a new verification token, the ones sent
+// before stop working.
+func (s *UserServiceImpl) ResendVerification(ctx context.Context, id string, config config.Container) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ResendVerification")
+	defer func() { endSpan(span, err) }()
+
+	if config.EmailVerification == nil {
+		return domain.ErrEmailVerificationDisabled
+	}
+	user, err := s.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return domain.ErrEmailAlreadyVerified
+	}
+	if err := s.EmailVerificationTokens.DeleteUserTokens(ctx, user.ID); err != nil {
+		return err
+	}
+	return s.sendEmailVerification(ctx, user, config.EmailVerification)
+}
 
-	return jwToken, nil
+// sendEmailVerification stores a new verification token for the user's
+// current email and sends it there.
+func (s *UserServiceImpl) sendEmailVerification(ctx context.Context, user domain.User, config *config.EmailVerification) error {
+	token, err := helpers.GenerateRandomToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	record := domain.EmailVerificationToken{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: helpers.HashToken(token),
+		ExpiresAt: now.Add(config.TokenTTL),
+		CreatedAt: now,
+	}
+	if err := s.EmailVerificationTokens.Save(ctx, record); err != nil {
+		return err
+	}
+
+	message, err := emailVerificationMessage(user, token, config)
+	if err != nil {
+		return err
+	}
+	if err := s.Notifier.Notify(ctx, message); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "email verification sent", "verification_user_id", user.ID)
+	return nil
 }
 
-func (s *UserServiceImpl) GetUserByID(ctx context.Context, id string) (domain.User, error) {
+func emailVerificationMessage(user domain.User, token string, config *config.EmailVerification) (domain.Message, error) {
+	instructions := "Use this token to verify your email:\n\n" + token
+	if config.URL != "" {
+		link, err := tokenLink(config.URL, token)
+		if err != nil {
+			return domain.Message{}, err
+		}
+		instructions = "Open this link to verify your email:\n\n" + link
+	}
+	return domain.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\n%s\n\nIt expires in %s. "+
+			"If you didn't sign up with this email, you can ignore this message.\n",
+			user.Name, instructions, config.TokenTTL),
+	}, nil
+}
+
+// EnrollTwoFactor starts setting up two-factor authentication with a new
+// secret, which only takes effect after ConfirmTwoFactor. Enrolling again
+// replaces a secret that wasn't confirmed.
+func (s *UserServiceImpl) EnrollTwoFactor(
+	ctx context.Context,
+	id string,
+	config config.Container,
+) (enrollment domain.TwoFactorEnrollment, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.EnrollTwoFactor")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+	if user.TwoFactorEnabled {
+		return domain.TwoFactorEnrollment{}, domain.ErrTwoFactorAlreadyEnabled
+	}
+	secret, err := helpers.GenerateTOTPSecret()
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+	if err := s.UserRepository.UpdateUser(ctx, id, domain.UserPatch{TOTPSecret: &secret}); err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+	slog.InfoContext(ctx, "two-factor enrollment started", "two_factor_user_id", id)
+	return domain.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    helpers.TOTPURI(config.TwoFactor.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor turns two-factor authentication on once the user proved
+// their authenticator app works with a code. It needs the password like
+// DisableTwoFactor, a wrong one counts towards the account's login lockout. It
+// returns the recovery codes, only their hashes are kept.
+func (s *UserServiceImpl) ConfirmTwoFactor(
+	ctx context.Context,
+	id, password, code string,
+	config config.Container,
+) (recoveryCodes []string, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ConfirmTwoFactor")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, domain.ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, domain.ErrTwoFactorNotEnabled
+	}
+
+	now := s.now()
+	attemptKeys := loginAttemptKeys(config.Lockout, user.Email, "")
+	if err := s.checkLoginAttempts(ctx, attemptKeys, now); err != nil {
+		return nil, err
+	}
+	_, checkSpan := tracer.Start(ctx, "CheckPasswordHash")
+	passwordMatches, _, err := s.PasswordHasher.Verify(password, user.Password)
+	checkSpan.End()
+	if err != nil {
+		return nil, err
+	}
+	if !passwordMatches {
+		slog.InfoContext(ctx, "enabling two-factor failed", "two_factor_user_id", id)
+		if err := s.recordLoginFailure(ctx, attemptKeys, &user, "", now); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrIncorrectPassword
+	}
+	step, err := checkTOTP(user, code, now)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes = make([]string, config.TwoFactor.RecoveryCodes)
+	hashes := make([]string, len(recoveryCodes))
+	for i := range recoveryCodes {
+		if recoveryCodes[i], err = helpers.GenerateRecoveryCode(); err != nil {
+			return nil, err
+		}
+		hashes[i] = helpers.HashToken(helpers.NormalizeRecoveryCode(recoveryCodes[i]))
+	}
+	enabled := true
+	patch := domain.UserPatch{TwoFactorEnabled: &enabled, TOTPLastStep: &step, RecoveryCodes: &hashes, ExpectedTOTPLastStep: &user.TOTPLastStep}
+	if err := codeUsedFirst(s.UserRepository.UpdateUser(ctx, id, patch)); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "two-factor enabled", "two_factor_user_id", id)
+	return recoveryCodes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off, it needs the password
+// and a code. Wrong ones count towards the account's login lockout.
+func (s *UserServiceImpl) DisableTwoFactor(
+	ctx context.Context,
+	id, password, code string,
+	config config.Container,
+) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.DisableTwoFactor")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled {
+		return domain.ErrTwoFactorNotEnabled
+	}
+
+	now := s.now()
+	attemptKeys := loginAttemptKeys(config.Lockout, user.Email, "")
+	if err := s.checkLoginAttempts(ctx, attemptKeys, now); err != nil {
+		return err
+	}
+	_, checkSpan := tracer.Start(ctx, "CheckPasswordHash")
+	passwordMatches, _, err := s.PasswordHasher.Verify(password, user.Password)
+	checkSpan.End()
+	if err != nil {
+		return err
+	}
+	if !passwordMatches {
+		err = domain.ErrIncorrectPassword
+	} else {
+		err = s.useTwoFactorCode(ctx, user, code, now)
+	}
+	if errors.Is(err, domain.ErrIncorrectPassword) || errors.Is(err, domain.ErrInvalidTwoFactorCode) {
+		slog.InfoContext(ctx, "disabling two-factor failed", "two_factor_user_id", id)
+		if err := s.recordLoginFailure(ctx, attemptKeys, &user, "", now); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	disabled, noSecret, noStep := false, "", int64(0)
+	patch := domain.UserPatch{TwoFactorEnabled: &disabled, TOTPSecret: &noSecret, TOTPLastStep: &noStep, RecoveryCodes: &[]string{}}
+	if err := s.UserRepository.UpdateUser(ctx, id, patch); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "two-factor disabled", "two_factor_user_id", id)
+	return nil
+}
+
+// useTwoFactorCode accepts a code from the authenticator app that wasn't used
+// before, or an unused recovery code, and uses it up. The update only applies
+// if the user's codes didn't change since they were read, so of two requests
+// with the same code only one gets through.
+func (s *UserServiceImpl) useTwoFactorCode(ctx context.Context, user domain.User, code string, now time.Time) error {
+	code = strings.ReplaceAll(code, " ", "")
+	if isTOTPCode(code) {
+		step, err := checkTOTP(user, code, now)
+		if err != nil {
+			return err
+		}
+		patch := domain.UserPatch{TOTPLastStep: &step, ExpectedTOTPLastStep: &user.TOTPLastStep}
+		return codeUsedFirst(s.UserRepository.UpdateUser(ctx, user.ID, patch))
+	}
+
+	hash := helpers.HashToken(helpers.NormalizeRecoveryCode(code))
+	remaining := make([]string, 0, len(user.RecoveryCodes))
+	for _, recoveryCode := range user.RecoveryCodes {
+		if recoveryCode != hash {
+			remaining = append(remaining, recoveryCode)
+		}
+	}
+	if len(remaining) == len(user.RecoveryCodes) {
+		return domain.ErrInvalidTwoFactorCode
+	}
+	patch := domain.UserPatch{RecoveryCodes: &remaining, ExpectedRecoveryCodes: &user.RecoveryCodes}
+	if err := codeUsedFirst(s.UserRepository.UpdateUser(ctx, user.ID, patch)); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "recovery code used", "two_factor_user_id", user.ID, "recovery_codes_left", len(remaining))
+	return nil
+}
+
+// codeUsedFirst turns the ErrUserNotFound of a conditional patch that lost the
+// race to another request into ErrInvalidTwoFactorCode.
+func codeUsedFirst(err error) error {
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return domain.ErrInvalidTwoFactorCode
+	}
+	return err
+}
+
+// checkTOTP returns the step of a valid code from the user's authenticator app.
+// Steps up to the last one used are rejected, so every code works once.
+func checkTOTP(user domain.User, code string, now time.Time) (int64, error) {
+	step, ok, err := helpers.ValidateTOTP(user.TOTPSecret, code, now)
+	if err != nil {
+		return 0, err
+	}
+	if !ok || step <= user.TOTPLastStep {
+		return 0, domain.ErrInvalidTwoFactorCode
+	}
+	return step, nil
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// sessionsRevokedBefore is the issue time from which tokens survive a password
+// change at changedAt. Tokens carry their issue time in whole seconds, so every
+// token of the second the change happened in is revoked, even the ones issued
+// earlier in that second, and tokens issued after the change count from the
+// next second, see tokenIssueTime.
+func sessionsRevokedBefore(changedAt time.Time) time.Time {
+	return changedAt.Truncate(time.Second).Add(time.Second)
+}
+
+// tokenIssueTime is the issue time of tokens for user issued at now. Tokens
+// issued right after a password change, in the same second, are dated to the
+// next second so the change doesn't revoke them.
+func tokenIssueTime(user domain.User, now time.Time) time.Time {
+	if revokedBefore := sessionsRevokedBefore(user.PasswordChangedAt); now.Before(revokedBefore) {
+		return revokedBefore
+	}
+	return now
+}
+
+// revokeSessions ends every session of the user started before revokedAt,
+// access and refresh tokens alike.
+func (s *UserServiceImpl) revokeSessions(ctx context.Context, userID string, revokedAt time.Time) error {
+	expiresAt := time.Now().Add(helpers.AccessTokenTTL)
+	if err := s.TokenRevocationStore.RevokeUserTokens(ctx, userID, revokedAt, expiresAt); err != nil {
+		return err
+	}
+	return s.RefreshTokenRepository.RevokeUser(ctx, userID)
+}
+
+// issueTokens signs an access token and stores a new refresh token. An
+// empty familyID starts a new family rooted at the new refresh token.
+func (s *UserServiceImpl) issueTokens(
+	ctx context.Context,
+	user domain.User,
+	refreshTokenID string,
+	familyID string,
+	config config.Container,
+) (domain.AuthTokens, error) {
+	accessToken, err := helpers.GenerateJWT(user.ID, user.Name, user.Email, user.UserRole(), user.EmailVerified, tokenIssueTime(user, time.Now()), config)
+	if err != nil {
+		return domain.AuthTokens{}, err
+	}
+
+	refreshToken, err := helpers.GenerateRandomToken()
+	if err != nil {
+		return domain.AuthTokens{}, err
+	}
+
+	if familyID == "" {
+		familyID = refreshTokenID
+	}
+	now := time.Now()
+	record := domain.RefreshToken{
+		ID:        refreshTokenID,
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: helpers.HashToken(refreshToken),
+		ExpiresAt: now.Add(config.JWT.RefreshTokenTTL),
+		CreatedAt: now,
+	}
+	if err := s.RefreshTokenRepository.Save(ctx, record); err != nil {
+		return domain.AuthTokens{}, err
+	}
+
+	return domain.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *UserServiceImpl) GetUserByID(ctx context.Context, id string) (user domain.User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserByID")
+	defer func() { endSpan(span, err) }()
+
 	return s.UserRepository.GetUserByID(ctx, id)
 }
 
-func (s *UserServiceImpl) GetAllUsers(ctx context.Context) ([]domain.User, error) {
-	return s.UserRepository.GetAllUsers(ctx)
+// GetAllUsers returns one page of users. One extra user is fetched to find out
+// whether another page follows.
+func (s *UserServiceImpl) GetAllUsers(ctx context.Context, query domain.UserQuery) (page domain.UserPage, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetAllUsers")
+	defer func() { endSpan(span, err) }()
+
+	if err := query.Normalize(); err != nil {
+		return domain.UserPage{}, err
+	}
+
+	fetch := query
+	fetch.Limit = query.Limit + 1
+	users, err := s.UserRepository.GetAllUsers(ctx, fetch)
+	if err != nil {
+		return domain.UserPage{}, err
+	}
+
+	page = domain.UserPage{Users: users, Limit: query.Limit}
+	if len(users) > query.Limit {
+		page.Users = users[:query.Limit]
+		page.NextCursor = domain.NewUserCursor(page.Users[query.Limit-1], query).Encode()
+	}
+	return page, nil
 }
 
-func (s *UserServiceImpl) UpdateUser(ctx context.Context, id string, user domain.User) error {
-	updateFields := bson.M{}
+// UpdateUser changes the name and email of a user. A new email has to be
+// verified again when email verification is configured.
+func (s *UserServiceImpl) UpdateUser(ctx context.Context, id string, user domain.User, config config.Container) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateUser")
+	defer func() { endSpan(span, err) }()
+
 	if err := user.ValidateEmailAndName(); err != nil {
 		return err
 	}
 
-	_, err := s.UserRepository.GetUserByID(ctx, id)
+	current, err := s.UserRepository.GetUserByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
+	var patch domain.UserPatch
 	if user.Email != "" {
-		existUser, _ := s.UserRepository.GetUserByEmail(ctx, user.Email)
+		existUser, err := s.UserRepository.GetUserByEmail(ctx, user.Email)
+		if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
 		if existUser != nil && existUser.ID != id {
-			return errors.New("email already exist")
+			return domain.ErrEmailTaken
+		}
+		patch.Email = &user.Email
+		if config.EmailVerification != nil && domain.NormalizeEmail(user.Email) != domain.NormalizeEmail(current.Email) {
+			verified := false
+			patch.EmailVerified = &verified
 		}
-		updateFields["email"] = user.Email
 	}
 	if user.Name != "" {
-		updateFields["name"] = user.Name
+		patch.Name = &user.Name
+	}
+
+	if err := s.UserRepository.UpdateUser(ctx, id, patch); err != nil {
+		return err
+	}
+	if patch.EmailVerified == nil {
+		return nil
 	}
 
-	return s.UserRepository.UpdateUser(ctx, id, updateFields)
+	if err := s.EmailVerificationTokens.DeleteUserTokens(ctx, id); err != nil {
+		return err
+	}
+	current.Email = user.Email
+	if patch.Name != nil {
+		current.Name = user.Name
+	}
+	if err := s.sendEmailVerification(ctx, current, config.EmailVerification); err != nil {
+		slog.WarnContext(ctx, "sending email verification failed", "updated_user_id", id, "error", err)
+	}
+	return nil
 }
 
-func (s *UserServiceImpl) DeleteUser(ctx context.Context, id string) error {
-	_, err := s.UserRepository.GetUserByID(ctx, id)
+func (s *UserServiceImpl) DeleteUser(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.DeleteUser")
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.UserRepository.GetUserByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	return s.UserRepository.DeleteUser(ctx, id)
+	if err := s.UserRepository.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "user deleted", "deleted_user_id", id)
+
+	return s.revokeSessions(ctx, id, time.Now())
 }
-func (s *UserServiceImpl) LogTotalUser(ctx context.Context) {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-	for range ticker.C {
-		count, err := s.UserRepository.GetUserCount(context.Background())
-		if err != nil {
-			log.Println("Error getting user count:", err)
-			continue
-		}
-		log.Println("Total number of users:", count)
+
+// UnlockUser clears the failed logins of the user's account, lifting a
+// lockout before it expires. Failures counted per client IP are kept.
+func (s *UserServiceImpl) UnlockUser(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.UnlockUser")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.UserRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.LoginAttemptStore.Reset(ctx, accountLoginKey(user.Email)); err != nil {
+		return err
 	}
+	slog.InfoContext(ctx, "account unlocked", "unlocked_user_id", id)
+	return nil
 }
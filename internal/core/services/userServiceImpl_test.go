@@ -2,16 +2,40 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
 	"one1-be-chal/internal/adapters/config"
+	"one1-be-chal/internal/adapters/hashers"
+	"one1-be-chal/internal/adapters/helpers"
+	"one1-be-chal/internal/adapters/storages/memory"
 	"one1-be-chal/internal/core/domain"
+	"one1-be-chal/internal/core/ports"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// testHasher hashes with Argon2id parameters cheap enough for tests and still
+// verifies bcrypt hashes.
+var testHasher = func() ports.PasswordHasher {
+	hasher, err := hashers.New(&config.PasswordHashing{
+		Algorithm: "argon2id",
+		Argon2id:  &config.Argon2id{Memory: 64, Iterations: 1, Parallelism: 1},
+		Bcrypt:    &config.Bcrypt{Cost: bcrypt.MinCost},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return hasher
+}()
+
 type MockUserRepository struct {
 	mock.Mock
 }
@@ -33,13 +57,13 @@ func (m *MockUserRepository) GetUserByID(ctx context.Context, id string) (domain
 	return args.Get(0).(domain.User), args.Error(1)
 }
 
-func (m *MockUserRepository) GetAllUsers(ctx context.Context) ([]domain.User, error) {
-	args := m.Called(ctx)
+func (m *MockUserRepository) GetAllUsers(ctx context.Context, query domain.UserQuery) ([]domain.User, error) {
+	args := m.Called(ctx, query)
 	return args.Get(0).([]domain.User), args.Error(1)
 }
 
-func (m *MockUserRepository) UpdateUser(ctx context.Context, id string, updateFields bson.M) error {
-	return m.Called(ctx, id, updateFields).Error(0)
+func (m *MockUserRepository) UpdateUser(ctx context.Context, id string, patch domain.UserPatch) error {
+	return m.Called(ctx, id, patch).Error(0)
 }
 
 func (m *MockUserRepository) DeleteUser(ctx context.Context, id string) error {
@@ -51,9 +75,125 @@ func (m *MockUserRepository) GetUserCount(ctx context.Context) (int64, error) {
 	return args.Get(0).(int64), args.Error(1)
 }
 
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Save(ctx context.Context, token domain.RefreshToken) error {
+	return m.Called(ctx, token).Error(0)
+}
+
+func (m *MockRefreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.RefreshToken), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) Revoke(ctx context.Context, id string, replacedBy string) (bool, error) {
+	args := m.Called(ctx, id, replacedBy)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return m.Called(ctx, familyID).Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeUser(ctx context.Context, userID string) error {
+	return m.Called(ctx, userID).Error(0)
+}
+
+type MockTokenRevocationStore struct {
+	mock.Mock
+}
+
+func (m *MockTokenRevocationStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	return m.Called(ctx, tokenID, expiresAt).Error(0)
+}
+
+func (m *MockTokenRevocationStore) RevokeUserTokens(ctx context.Context, userID string, revokedAt time.Time, expiresAt time.Time) error {
+	return m.Called(ctx, userID, revokedAt, expiresAt).Error(0)
+}
+
+func (m *MockTokenRevocationStore) IsRevoked(ctx context.Context, tokenID string, userID string, issuedAt time.Time) (bool, error) {
+	args := m.Called(ctx, tokenID, userID, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, message domain.Message) error {
+	return m.Called(ctx, message).Error(0)
+}
+
+// testDependencies are what newTestService passes to NewUserService.
+type testDependencies struct {
+	users               ports.UserRepository
+	refreshTokens       ports.RefreshTokenRepository
+	revocations         ports.TokenRevocationStore
+	loginAttempts       ports.LoginAttemptStore
+	passwordResetTokens ports.PasswordResetTokenRepository
+	notifier            ports.Notifier
+	hasher              ports.PasswordHasher
+}
+
+type testOption func(*testDependencies)
+
+func withUsers(users ports.UserRepository) testOption {
+	return func(deps *testDependencies) { deps.users = users }
+}
+
+func withRefreshTokens(refreshTokens ports.RefreshTokenRepository) testOption {
+	return func(deps *testDependencies) { deps.refreshTokens = refreshTokens }
+}
+
+func withRevocations(revocations ports.TokenRevocationStore) testOption {
+	return func(deps *testDependencies) { deps.revocations = revocations }
+}
+
+func withLoginAttempts(loginAttempts ports.LoginAttemptStore) testOption {
+	return func(deps *testDependencies) { deps.loginAttempts = loginAttempts }
+}
+
+func withPasswordResetTokens(passwordResetTokens ports.PasswordResetTokenRepository) testOption {
+	return func(deps *testDependencies) { deps.passwordResetTokens = passwordResetTokens }
+}
+
+func withNotifier(notifier ports.Notifier) testOption {
+	return func(deps *testDependencies) { deps.notifier = notifier }
+}
+
+func withHasher(hasher ports.PasswordHasher) testOption {
+	return func(deps *testDependencies) { deps.hasher = hasher }
+}
+
+// newTestService builds a service on memory stores, a MockNotifier without
+// expectations and testHasher, options replace the dependencies a test cares about.
+func newTestService(t *testing.T, options ...testOption) ports.UserService {
+	t.Helper()
+	deps := testDependencies{
+		users:               memory.NewUserRepository(),
+		refreshTokens:       memory.NewRefreshTokenRepository(),
+		revocations:         memory.NewTokenRevocationStore(),
+		loginAttempts:       memory.NewLoginAttemptStore(),
+		passwordResetTokens: memory.NewPasswordResetTokenRepository(),
+		notifier:            new(MockNotifier),
+		hasher:              testHasher,
+	}
+	for _, option := range options {
+		option(&deps)
+	}
+	return NewUserService(deps.users, deps.refreshTokens, deps.revocations, deps.loginAttempts,
+		deps.passwordResetTokens, memory.NewEmailVerificationTokenRepository(), deps.notifier, deps.hasher)
+}
+
 func TestRegisterUser(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	mockTokenRepo := new(MockRefreshTokenRepository)
+	service := newTestService(t, withUsers(mockRepo), withRefreshTokens(mockTokenRepo))
 
 	user := domain.User{
 		Email:    "test@gmail.com",
@@ -63,24 +203,116 @@ func TestRegisterUser(t *testing.T) {
 
 	mockRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(nil, nil)
 	mockRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	mockTokenRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
 
-	jwtToken, err := service.Register(
+	tokens, err := service.Register(
 		context.Background(),
 		user,
 		config.Container{
 			JWT: &config.JWT{
-				SecretKey: []byte("secret"),
+				SecretKey:       []byte("secret"),
+				RefreshTokenTTL: time.Hour,
 			},
 		},
 	)
 
 	assert.NoError(t, err)
-	assert.NotEmpty(t, jwtToken)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	mockTokenRepo.AssertCalled(t, "Save", mock.Anything, mock.MatchedBy(func(token domain.RefreshToken) bool {
+		return token.TokenHash == helpers.HashToken(tokens.RefreshToken) && token.FamilyID == token.ID
+	}))
+}
+
+func TestRegisterIgnoresRequestedRole(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockRefreshTokenRepository)
+	service := newTestService(t, withUsers(mockRepo), withRefreshTokens(mockTokenRepo))
+
+	user := domain.User{
+		Email:    "test@gmail.com",
+		Password: "passwordkrub",
+		Name:     "One1 yean",
+		Role:     domain.RoleAdmin,
+	}
+
+	mockRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(nil, nil)
+	mockRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	mockTokenRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+	mockConfig := config.Container{JWT: &config.JWT{SecretKey: []byte("secret")}}
+	tokens, err := service.Register(context.Background(), user, mockConfig)
+
+	assert.NoError(t, err)
+	mockRepo.AssertCalled(t, "Save", mock.Anything, mock.MatchedBy(func(saved domain.User) bool {
+		return saved.Role == domain.RoleUser
+	}))
+	claims, err := helpers.ParseJWT(tokens.AccessToken, mockConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RoleUser, claims.Role)
+}
+
+func TestRegisterIgnoresServerManagedFields(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Set     func(user *domain.User)
+		IsReset func(saved domain.User) bool
+	}{
+		{
+			Name:    "two_factor_enabled",
+			Set:     func(user *domain.User) { user.TwoFactorEnabled = true },
+			IsReset: func(saved domain.User) bool { return !saved.TwoFactorEnabled },
+		},
+		{
+			Name:    "totp secret",
+			Set:     func(user *domain.User) { user.TOTPSecret = "JBSWY3DPEHPK3PXP" },
+			IsReset: func(saved domain.User) bool { return saved.TOTPSecret == "" },
+		},
+		{
+			Name:    "totp last step",
+			Set:     func(user *domain.User) { user.TOTPLastStep = 1 << 40 },
+			IsReset: func(saved domain.User) bool { return saved.TOTPLastStep == 0 },
+		},
+		{
+			Name:    "recovery codes",
+			Set:     func(user *domain.User) { user.RecoveryCodes = []string{helpers.HashToken("aaaaabbbbb")} },
+			IsReset: func(saved domain.User) bool { return len(saved.RecoveryCodes) == 0 },
+		},
+		{
+			Name:    "password_changed_at",
+			Set:     func(user *domain.User) { user.PasswordChangedAt = time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC) },
+			IsReset: func(saved domain.User) bool { return saved.PasswordChangedAt.IsZero() },
+		},
+		{
+			Name:    "created_at",
+			Set:     func(user *domain.User) { user.CreatedAt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC) },
+			IsReset: func(saved domain.User) bool { return time.Since(saved.CreatedAt) < time.Minute },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			mockTokenRepo := new(MockRefreshTokenRepository)
+			service := newTestService(t, withUsers(mockRepo), withRefreshTokens(mockTokenRepo))
+			user := domain.User{Email: "test@gmail.com", Password: "passwordkrub", Name: "One1 yean"}
+			test.Set(&user)
+
+			mockRepo.On("GetUserByEmail", mock.Anything, user.Email).Return(nil, nil)
+			mockRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
+			mockTokenRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+			_, err := service.Register(context.Background(), user, config.Container{JWT: &config.JWT{SecretKey: []byte("secret")}})
+
+			require.NoError(t, err)
+			mockRepo.AssertCalled(t, "Save", mock.Anything, mock.MatchedBy(test.IsReset))
+		})
+	}
 }
 
 func TestRegisterExistingUser(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service := newTestService(t, withUsers(mockRepo))
 
 	existingUser := &domain.User{
 		Email: "test@gmail.com",
@@ -90,13 +322,499 @@ func TestRegisterExistingUser(t *testing.T) {
 
 	_, err := service.Register(context.Background(), *existingUser, config.Container{})
 
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrEmailTaken)
 	assert.Equal(t, "email already exist", err.Error())
 }
 
+func TestRegisterWeakPassword(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := newTestService(t, withUsers(mockRepo))
+	mockConfig := config.Container{PasswordPolicy: &domain.PasswordPolicy{
+		MinLength:           8,
+		MinCharacterClasses: 2,
+		RejectPersonalInfo:  true,
+		Blocklist:           map[string]struct{}{"one1yean": {}},
+	}}
+
+	_, err := service.Register(context.Background(), domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "One1yean"}, mockConfig)
+
+	var weak *domain.WeakPasswordError
+	require.ErrorAs(t, err, &weak)
+	assert.ErrorIs(t, err, domain.ErrWeakPassword)
+	assert.Equal(t, []string{domain.PasswordRulePersonalInfo, domain.PasswordRuleBlocklist}, rules(weak.Violations))
+	mockRepo.AssertNotCalled(t, "GetUserByEmail", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func rules(violations []domain.PasswordViolation) []string {
+	var rules []string
+	for _, violation := range violations {
+		rules = append(rules, violation.Rule)
+	}
+	return rules
+}
+
+func TestRegisterLosesRace(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := newTestService(t, withUsers(mockRepo))
+
+	mockRepo.On("GetUserByEmail", mock.Anything, "test@gmail.com").Return(nil, domain.ErrUserNotFound)
+	mockRepo.On("Save", mock.Anything, mock.Anything).Return(domain.ErrEmailTaken)
+
+	_, err := service.Register(context.Background(), domain.User{Email: "test@gmail.com", Password: "passwordkrub"}, config.Container{})
+
+	assert.ErrorIs(t, err, domain.ErrEmailTaken)
+}
+
+func TestRegisterConcurrently(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+	mockConfig := config.Container{
+		JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+	}
+
+	const attempts = 10
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Vary the case so only the normalized comparison catches the duplicate.
+			email := "test@gmail.com"
+			if i%2 == 1 {
+				email = "Test@Gmail.com"
+			}
+			_, errs[i] = service.Register(ctx, domain.User{Name: "One1 yean", Email: email, Password: "passwordkrub"}, mockConfig)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, domain.ErrEmailTaken)
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestLogin(t *testing.T) {
+	hashedPassword, _ := testHasher.Hash("passwordkrub")
+	existingUser := &domain.User{
+		ID:       "123",
+		Name:     "One1 yean",
+		Email:    "test@gmail.com",
+		Password: hashedPassword,
+	}
+	mockConfig := config.Container{
+		JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+	}
+
+	tests := []struct {
+		Name          string
+		Email         string
+		Password      string
+		ExpectedError error
+	}{
+		{
+			Name:          "valid credentials",
+			Email:         "test@gmail.com",
+			Password:      "passwordkrub",
+			ExpectedError: nil,
+		},
+		{
+			Name:          "wrong password",
+			Email:         "test@gmail.com",
+			Password:      "wrongpassword",
+			ExpectedError: domain.ErrInvalidCredentials,
+		},
+		{
+			Name:          "unknown email",
+			Email:         "unknown@gmail.com",
+			Password:      "passwordkrub",
+			ExpectedError: domain.ErrInvalidCredentials,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			mockTokenRepo := new(MockRefreshTokenRepository)
+			service := newTestService(t, withUsers(mockRepo), withRefreshTokens(mockTokenRepo))
+			mockRepo.On("GetUserByEmail", mock.Anything, "test@gmail.com").Return(existingUser, nil)
+			mockRepo.On("GetUserByEmail", mock.Anything, "unknown@gmail.com").Return(nil, domain.ErrUserNotFound)
+			mockTokenRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+			tokens, err := service.Login(context.Background(), test.Email, test.Password, "1.2.3.4", mockConfig)
+
+			if test.ExpectedError != nil {
+				assert.ErrorIs(t, err, test.ExpectedError)
+				assert.Empty(t, tokens.AccessToken)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.NotEmpty(t, tokens.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	ctx := context.Background()
+	userRepo := memory.NewUserRepository()
+	service := newTestService(t, withUsers(userRepo))
+	mockConfig := config.Container{JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour}}
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte("passwordkrub"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, userRepo.Save(ctx, domain.User{ID: "123", Name: "One1 yean", Email: "test@gmail.com", Password: string(legacyHash)}))
+
+	_, err = service.Login(ctx, "test@gmail.com", "wrongpassword", "", mockConfig)
+	require.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	user, err := userRepo.GetUserByID(ctx, "123")
+	require.NoError(t, err)
+	assert.Equal(t, string(legacyHash), user.Password, "failed logins leave the hash alone")
+
+	_, err = service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	require.NoError(t, err)
+	user, err = userRepo.GetUserByID(ctx, "123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(user.Password, "$argon2id$"), user.Password)
+	assert.True(t, user.PasswordChangedAt.IsZero(), "a rehash keeps the sessions")
+
+	_, err = service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	assert.NoError(t, err)
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	mockConfig := config.Container{
+		JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		Lockout: &config.Lockout{
+			Account: domain.LockoutPolicy{LockAfter: 3, LockDuration: time.Hour},
+			IP:      domain.LockoutPolicy{LockAfter: 5, LockDuration: time.Hour},
+		},
+	}
+	newService := func(t *testing.T) (ports.UserService, string) {
+		service := newTestService(t)
+		tokens, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+		require.NoError(t, err)
+		claims, err := helpers.ParseJWT(tokens.AccessToken, mockConfig)
+		require.NoError(t, err)
+		return service, claims.ID
+	}
+
+	t.Run("locks the account until an admin unlocks it", func(t *testing.T) {
+		service, id := newService(t)
+		for i := 0; i < 3; i++ {
+			_, err := service.Login(ctx, "test@gmail.com", "wrongpassword", "1.2.3.4", mockConfig)
+			assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+		}
+
+		_, err := service.Login(ctx, "TEST@gmail.com", "passwordkrub", "5.6.7.8", mockConfig)
+		var throttled *domain.LoginThrottledError
+		require.ErrorAs(t, err, &throttled, "the account is locked from every IP and spelling")
+		assert.InDelta(t, time.Hour, throttled.RetryAfter, float64(time.Minute))
+
+		require.NoError(t, service.UnlockUser(ctx, id))
+		_, err = service.Login(ctx, "test@gmail.com", "passwordkrub", "5.6.7.8", mockConfig)
+		assert.NoError(t, err)
+	})
+
+	t.Run("a successful login clears the failures", func(t *testing.T) {
+		service, _ := newService(t)
+		for round := 0; round < 2; round++ {
+			for i := 0; i < 2; i++ {
+				_, err := service.Login(ctx, "test@gmail.com", "wrongpassword", "1.2.3.4", mockConfig)
+				assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+			}
+			_, err := service.Login(ctx, "test@gmail.com", "passwordkrub", "1.2.3.4", mockConfig)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("locks the IP guessing across accounts", func(t *testing.T) {
+		service, _ := newService(t)
+		for i := 0; i < 5; i++ {
+			_, err := service.Login(ctx, fmt.Sprintf("unknown%d@gmail.com", i), "passwordkrub", "1.2.3.4", mockConfig)
+			assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+		}
+
+		_, err := service.Login(ctx, "test@gmail.com", "passwordkrub", "1.2.3.4", mockConfig)
+		assert.ErrorIs(t, err, domain.ErrLoginThrottled)
+		_, err = service.Login(ctx, "test@gmail.com", "passwordkrub", "5.6.7.8", mockConfig)
+		assert.NoError(t, err)
+	})
+
+	t.Run("a successful login doesn't clear the failures of the IP", func(t *testing.T) {
+		service, _ := newService(t)
+		_, err := service.Register(ctx, domain.User{Name: "Attacker", Email: "attacker@gmail.com", Password: "attackerkrub"}, mockConfig)
+		require.NoError(t, err)
+		// Guesses at other accounts, each after logging into the attacker's own.
+		for _, email := range []string{"test@gmail.com", "test@gmail.com", "other@gmail.com", "other@gmail.com", "third@gmail.com"} {
+			_, err := service.Login(ctx, "attacker@gmail.com", "attackerkrub", "1.2.3.4", mockConfig)
+			require.NoError(t, err)
+			_, err = service.Login(ctx, email, "wrongpassword", "1.2.3.4", mockConfig)
+			assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+		}
+
+		_, err = service.Login(ctx, "attacker@gmail.com", "attackerkrub", "1.2.3.4", mockConfig)
+		assert.ErrorIs(t, err, domain.ErrLoginThrottled, "the IP is locked after 5 failures across accounts")
+	})
+
+	t.Run("unlocking an unknown user", func(t *testing.T) {
+		service, _ := newService(t)
+		assert.ErrorIs(t, service.UnlockUser(ctx, "unknown"), domain.ErrUserNotFound)
+	})
+}
+
+// countingHasher counts the hashes it verifies.
+type countingHasher struct {
+	ports.PasswordHasher
+	verified []string
+}
+
+func (h *countingHasher) Verify(password, hash string) (ok, needsRehash bool, err error) {
+	h.verified = append(h.verified, hash)
+	return h.PasswordHasher.Verify(password, hash)
+}
+
+func TestLoginUnknownEmailVerifiesHash(t *testing.T) {
+	hasher := &countingHasher{PasswordHasher: testHasher}
+	service := newTestService(t, withHasher(hasher))
+	mockConfig := config.Container{JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour}}
+	_, err := service.Register(context.Background(), domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+
+	_, err = service.Login(context.Background(), "unknown@gmail.com", "passwordkrub", "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	_, err = service.Login(context.Background(), "other@gmail.com", "passwordkrub", "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	_, err = service.Login(context.Background(), "test@gmail.com", "wrongpassword", "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+
+	require.Len(t, hasher.verified, 3, "unknown emails cost a hash check like known ones")
+	assert.Equal(t, hasher.verified[0], hasher.verified[1], "the dummy hash is computed once")
+	assert.True(t, strings.HasPrefix(hasher.verified[0], "$argon2id$"), "the dummy hash uses the configured algorithm")
+}
+
+func TestLoginBacksOff(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	attempts := memory.NewLoginAttemptStore()
+	service := newTestService(t, withUsers(mockRepo), withLoginAttempts(attempts))
+	mockRepo.On("GetUserByEmail", mock.Anything, "test@gmail.com").Return(nil, domain.ErrUserNotFound)
+	mockConfig := config.Container{
+		Lockout: &config.Lockout{
+			Account: domain.LockoutPolicy{BackoffAfter: 1, BaseDelay: time.Minute, MaxDelay: time.Hour, LockDuration: time.Hour},
+		},
+	}
+
+	_, err := service.Login(context.Background(), "test@gmail.com", "wrongpassword", "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	_, err = service.Login(context.Background(), "test@gmail.com", "wrongpassword", "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrLoginThrottled)
+	mockRepo.AssertNumberOfCalls(t, "GetUserByEmail", 1)
+}
+
+func TestRefreshToken(t *testing.T) {
+	mockConfig := config.Container{
+		JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+	}
+	user := domain.User{ID: "123", Name: "One1 yean", Email: "test@gmail.com"}
+	liveToken := func() *domain.RefreshToken {
+		return &domain.RefreshToken{
+			ID:        "token-1",
+			UserID:    "123",
+			FamilyID:  "family-1",
+			TokenHash: helpers.HashToken("refresh"),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+	}
+
+	t.Run("rotates a live token", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockTokenRepo := new(MockRefreshTokenRepository)
+		service := newTestService(t, withUsers(mockRepo), withRefreshTokens(mockTokenRepo))
+		mockTokenRepo.On("GetByHash", mock.Anything, helpers.HashToken("refresh")).Return(liveToken(), nil)
+		mockRepo.On("GetUserByID", mock.Anything, "123").Return(user, nil)
+		mockTokenRepo.On("Revoke", mock.Anything, "token-1", mock.Anything).Return(true, nil)
+		mockTokenRepo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+		tokens, err := service.RefreshToken(context.Background(), "refresh", mockConfig)
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.NotEqual(t, "refresh", tokens.RefreshToken)
+		mockTokenRepo.AssertCalled(t, "Save", mock.Anything, mock.MatchedBy(func(token domain.RefreshToken) bool {
+			return token.FamilyID == "family-1" && token.UserID == "123"
+		}))
+		mockTokenRepo.AssertNotCalled(t, "RevokeFamily", mock.Anything, mock.Anything)
+	})
+
+	t.Run("token from before a password change", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockTokenRepo := new(MockRefreshTokenRepository)
+		service := newTestService(t, withUsers(mockRepo), withRefreshTokens(mockTokenRepo))
+		stale := liveToken()
+		stale.CreatedAt = time.Now().Add(-time.Minute)
+		changed := user
+		changed.PasswordChangedAt = time.Now()
+		mockTokenRepo.On("GetByHash", mock.Anything, mock.Anything).Return(stale, nil)
+		mockRepo.On("GetUserByID", mock.Anything, "123").Return(changed, nil)
+
+		_, err := service.RefreshToken(context.Background(), "refresh", mockConfig)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidRefreshToken)
+		mockTokenRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("reused token revokes the family", func(t *testing.T) {
+		mockTokenRepo := new(MockRefreshTokenRepository)
+		service := newTestService(t, withRefreshTokens(mockTokenRepo))
+		reused := liveToken()
+		reused.Revoked = true
+		mockTokenRepo.On("GetByHash", mock.Anything, mock.Anything).Return(reused, nil)
+		mockTokenRepo.On("RevokeFamily", mock.Anything, "family-1").Return(nil)
+
+		_, err := service.RefreshToken(context.Background(), "refresh", mockConfig)
+
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+		mockTokenRepo.AssertCalled(t, "RevokeFamily", mock.Anything, "family-1")
+		mockTokenRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("lost rotation race revokes the family", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockTokenRepo := new(MockRefreshTokenRepository)
+		service := newTestService(t, withUsers(mockRepo), withRefreshTokens(mockTokenRepo))
+		mockTokenRepo.On("GetByHash", mock.Anything, mock.Anything).Return(liveToken(), nil)
+		mockRepo.On("GetUserByID", mock.Anything, "123").Return(user, nil)
+		mockTokenRepo.On("Revoke", mock.Anything, "token-1", mock.Anything).Return(false, nil)
+		mockTokenRepo.On("RevokeFamily", mock.Anything, "family-1").Return(nil)
+
+		_, err := service.RefreshToken(context.Background(), "refresh", mockConfig)
+
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+		mockTokenRepo.AssertCalled(t, "RevokeFamily", mock.Anything, "family-1")
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		mockTokenRepo := new(MockRefreshTokenRepository)
+		service := newTestService(t, withRefreshTokens(mockTokenRepo))
+		expired := liveToken()
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		mockTokenRepo.On("GetByHash", mock.Anything, mock.Anything).Return(expired, nil)
+
+		_, err := service.RefreshToken(context.Background(), "refresh", mockConfig)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidRefreshToken)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		mockTokenRepo := new(MockRefreshTokenRepository)
+		service := newTestService(t, withRefreshTokens(mockTokenRepo))
+		mockTokenRepo.On("GetByHash", mock.Anything, mock.Anything).Return(nil, domain.ErrRefreshTokenNotFound)
+
+		_, err := service.RefreshToken(context.Background(), "refresh", mockConfig)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidRefreshToken)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+
+	t.Run("revokes the access token", func(t *testing.T) {
+		mockTokenRepo := new(MockRefreshTokenRepository)
+		mockRevocations := new(MockTokenRevocationStore)
+		service := newTestService(t, withRefreshTokens(mockTokenRepo), withRevocations(mockRevocations))
+		mockRevocations.On("RevokeToken", mock.Anything, "jti-1", expiresAt).Return(nil)
+
+		err := service.Logout(context.Background(), "123", "jti-1", expiresAt, "")
+
+		assert.NoError(t, err)
+		mockRevocations.AssertCalled(t, "RevokeToken", mock.Anything, "jti-1", expiresAt)
+		mockTokenRepo.AssertNotCalled(t, "GetByHash", mock.Anything, mock.Anything)
+	})
+
+	t.Run("revokes the refresh token family", func(t *testing.T) {
+		mockTokenRepo := new(MockRefreshTokenRepository)
+		mockRevocations := new(MockTokenRevocationStore)
+		service := newTestService(t, withRefreshTokens(mockTokenRepo), withRevocations(mockRevocations))
+		mockRevocations.On("RevokeToken", mock.Anything, "jti-1", expiresAt).Return(nil)
+		mockTokenRepo.On("GetByHash", mock.Anything, helpers.HashToken("refresh")).
+			Return(&domain.RefreshToken{ID: "token-1", UserID: "123", FamilyID: "family-1"}, nil)
+		mockTokenRepo.On("RevokeFamily", mock.Anything, "family-1").Return(nil)
+
+		err := service.Logout(context.Background(), "123", "jti-1", expiresAt, "refresh")
+
+		assert.NoError(t, err)
+		mockTokenRepo.AssertCalled(t, "RevokeFamily", mock.Anything, "family-1")
+	})
+
+	t.Run("ignores another user's refresh token", func(t *testing.T) {
+		mockTokenRepo := new(MockRefreshTokenRepository)
+		mockRevocations := new(MockTokenRevocationStore)
+		service := newTestService(t, withRefreshTokens(mockTokenRepo), withRevocations(mockRevocations))
+		mockRevocations.On("RevokeToken", mock.Anything, "jti-1", expiresAt).Return(nil)
+		mockTokenRepo.On("GetByHash", mock.Anything, helpers.HashToken("refresh")).
+			Return(&domain.RefreshToken{ID: "token-1", UserID: "456", FamilyID: "family-1"}, nil)
+
+		err := service.Logout(context.Background(), "123", "jti-1", expiresAt, "refresh")
+
+		assert.NoError(t, err)
+		mockTokenRepo.AssertNotCalled(t, "RevokeFamily", mock.Anything, mock.Anything)
+	})
+}
+
+// TestUserFlowWithMemoryRepositories runs the service against the in-memory
+// adapters instead of mocks.
+func TestUserFlowWithMemoryRepositories(t *testing.T) {
+	ctx := context.Background()
+	revocations := memory.NewTokenRevocationStore()
+	service := newTestService(t, withRevocations(revocations))
+	mockConfig := config.Container{
+		JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+	}
+
+	registered, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	assert.NoError(t, err)
+
+	_, err = service.Register(ctx, domain.User{Name: "Copy", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	assert.ErrorIs(t, err, domain.ErrEmailTaken)
+
+	_, err = service.Login(ctx, "test@gmail.com", "wrongpassword", "1.2.3.4", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+
+	loggedIn, err := service.Login(ctx, "test@gmail.com", "passwordkrub", "1.2.3.4", mockConfig)
+	assert.NoError(t, err)
+
+	rotated, err := service.RefreshToken(ctx, loggedIn.RefreshToken, mockConfig)
+	assert.NoError(t, err)
+	_, err = service.RefreshToken(ctx, loggedIn.RefreshToken, mockConfig)
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+	_, err = service.RefreshToken(ctx, rotated.RefreshToken, mockConfig)
+	assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, service.UpdateUser(ctx, claims.ID, domain.User{Name: "Renamed"}, mockConfig))
+	user, err := service.GetUserByID(ctx, claims.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", user.Name)
+
+	assert.NoError(t, service.DeleteUser(ctx, claims.ID))
+	_, err = service.GetUserByID(ctx, claims.ID)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	revoked, err := revocations.IsRevoked(ctx, claims.TokenID(), claims.ID, claims.IssuedAt.Time)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
 func TestGetUserByID(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service := newTestService(t, withUsers(mockRepo))
 
 	expectedUser := domain.User{ID: "123", Name: "One1 yean", Email: "test@gmail.com"}
 	mockRepo.On("GetUserByID", mock.Anything, "123").Return(expectedUser, nil)
@@ -107,9 +825,86 @@ func TestGetUserByID(t *testing.T) {
 	assert.Equal(t, expectedUser, user)
 }
 
+func TestGetAllUsers(t *testing.T) {
+	users := []domain.User{
+		{ID: "1", Name: "a", CreatedAt: time.Unix(1, 0)},
+		{ID: "2", Name: "b", CreatedAt: time.Unix(2, 0)},
+		{ID: "3", Name: "c", CreatedAt: time.Unix(3, 0)},
+	}
+
+	t.Run("full page has a next cursor", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := newTestService(t, withUsers(mockRepo))
+		mockRepo.On("GetAllUsers", mock.Anything, mock.MatchedBy(func(query domain.UserQuery) bool {
+			return query.Limit == 3 && query.SortBy == domain.SortByCreatedAt
+		})).Return(users, nil)
+
+		page, err := service.GetAllUsers(context.Background(), domain.UserQuery{Limit: 2})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Users, 2)
+		assert.Equal(t, 2, page.Limit)
+		cursor, err := domain.DecodeUserCursor(page.NextCursor)
+		assert.NoError(t, err)
+		assert.Equal(t, "2", cursor.ID)
+		assert.Equal(t, domain.SortByCreatedAt, cursor.SortBy)
+	})
+
+	t.Run("last page has no cursor", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := newTestService(t, withUsers(mockRepo))
+		mockRepo.On("GetAllUsers", mock.Anything, mock.Anything).Return(users, nil)
+
+		page, err := service.GetAllUsers(context.Background(), domain.UserQuery{Limit: 3})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Users, 3)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := newTestService(t, withUsers(mockRepo))
+
+		_, err := service.GetAllUsers(context.Background(), domain.UserQuery{SortBy: "email"})
+
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		mockRepo.AssertNotCalled(t, "GetAllUsers", mock.Anything, mock.Anything)
+	})
+
+	t.Run("pages through the memory repository", func(t *testing.T) {
+		repo := memory.NewUserRepository()
+		for i := 0; i < 5; i++ {
+			repo.Save(context.Background(), domain.User{
+				ID:        string(rune('a' + i)),
+				Name:      "user",
+				Email:     string(rune('a'+i)) + "@gmail.com",
+				CreatedAt: time.Unix(int64(10-i), 0),
+			})
+		}
+		service := newTestService(t, withUsers(repo))
+
+		var ids []string
+		query := domain.UserQuery{Limit: 2, SortBy: domain.SortByName}
+		for {
+			page, err := service.GetAllUsers(context.Background(), query)
+			assert.NoError(t, err)
+			for _, user := range page.Users {
+				ids = append(ids, user.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.After, err = domain.DecodeUserCursor(page.NextCursor)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, ids)
+	})
+}
+
 func TestUpdateUser(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service := newTestService(t, withUsers(mockRepo))
 	tests := []struct {
 		Name        string
 		User        domain.User
@@ -146,7 +941,7 @@ func TestUpdateUser(t *testing.T) {
 			mockRepo.On("GetUserByEmail", mock.Anything, test.User.Email).Return(nil, nil)
 			mockRepo.On("UpdateUser", mock.Anything, "123", mock.Anything).Return(nil)
 
-			err := service.UpdateUser(context.Background(), "123", test.User)
+			err := service.UpdateUser(context.Background(), "123", test.User, config.Container{})
 
 			if test.ExpectError {
 				assert.Error(t, err)
@@ -159,27 +954,651 @@ func TestUpdateUser(t *testing.T) {
 
 }
 
+func TestUpdateUserPatch(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := newTestService(t, withUsers(mockRepo))
+	mockRepo.On("GetUserByID", mock.Anything, "123").Return(domain.User{ID: "123"}, nil)
+	mockRepo.On("UpdateUser", mock.Anything, "123", mock.Anything).Return(nil)
+
+	err := service.UpdateUser(context.Background(), "123", domain.User{Name: "One1 yean"}, config.Container{})
+
+	assert.NoError(t, err)
+	mockRepo.AssertCalled(t, "UpdateUser", mock.Anything, "123", mock.MatchedBy(func(patch domain.UserPatch) bool {
+		return patch.Name != nil && *patch.Name == "One1 yean" && patch.Email == nil
+	}))
+}
+
+func TestUpdateUserErrors(t *testing.T) {
+	t.Run("email taken by another user", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := newTestService(t, withUsers(mockRepo))
+		mockRepo.On("GetUserByID", mock.Anything, "123").Return(domain.User{ID: "123"}, nil)
+		mockRepo.On("GetUserByEmail", mock.Anything, "taken@gmail.com").Return(&domain.User{ID: "456"}, nil)
+
+		err := service.UpdateUser(context.Background(), "123", domain.User{Email: "taken@gmail.com"}, config.Container{})
+
+		assert.ErrorIs(t, err, domain.ErrEmailTaken)
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		service := newTestService(t, withUsers(mockRepo))
+		mockRepo.On("GetUserByID", mock.Anything, "404").Return(domain.User{}, domain.ErrUserNotFound)
+
+		err := service.UpdateUser(context.Background(), "404", domain.User{Name: "One1"}, config.Container{})
+
+		assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	})
+
+	t.Run("empty update", func(t *testing.T) {
+		service := newTestService(t)
+
+		err := service.UpdateUser(context.Background(), "123", domain.User{}, config.Container{})
+
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
 func TestDeleteUser(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	mockTokenRepo := new(MockRefreshTokenRepository)
+	mockRevocations := new(MockTokenRevocationStore)
+	service := newTestService(t, withUsers(mockRepo), withRefreshTokens(mockTokenRepo), withRevocations(mockRevocations))
 
 	mockRepo.On("GetUserByID", mock.Anything, "123").Return(domain.User{ID: "123"}, nil)
 	mockRepo.On("DeleteUser", mock.Anything, "123").Return(nil)
+	mockRevocations.On("RevokeUserTokens", mock.Anything, "123", mock.Anything, mock.Anything).Return(nil)
+	mockTokenRepo.On("RevokeUser", mock.Anything, "123").Return(nil)
 
 	err := service.DeleteUser(context.Background(), "123")
 
 	assert.NoError(t, err)
+	mockRevocations.AssertCalled(t, "RevokeUserTokens", mock.Anything, "123", mock.Anything, mock.Anything)
+	mockTokenRepo.AssertCalled(t, "RevokeUser", mock.Anything, "123")
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	revocations := memory.NewTokenRevocationStore()
+	notifier := new(MockNotifier)
+	service := newTestService(t, withRevocations(revocations), withNotifier(notifier))
+	mockConfig := config.Container{
+		JWT:            &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		PasswordReset:  &config.PasswordReset{TokenTTL: 30 * time.Minute, URL: "https://app.example.com/reset?lang=en"},
+		PasswordPolicy: &domain.PasswordPolicy{MinLength: 8, RejectPersonalInfo: true},
+		Lockout: &config.Lockout{
+			Account: domain.LockoutPolicy{LockAfter: 1, LockDuration: time.Hour},
+		},
+	}
+	var sent []domain.Message
+	notifier.On("Notify", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent = append(sent, args.Get(1).(domain.Message))
+	}).Return(nil)
+
+	registered, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	require.NoError(t, err)
+	_, err = service.Login(ctx, "test@gmail.com", "wrongpassword", "", mockConfig)
+	require.ErrorIs(t, err, domain.ErrInvalidCredentials, "locks the account")
+
+	require.NoError(t, service.ForgotPassword(ctx, "unknown@gmail.com", mockConfig))
+	assert.Empty(t, sent, "unknown emails get nothing")
+	require.NoError(t, service.ForgotPassword(ctx, "TEST@gmail.com", mockConfig))
+	require.NoError(t, service.ForgotPassword(ctx, "test@gmail.com", mockConfig))
+	require.Len(t, sent, 2)
+	assert.Equal(t, "test@gmail.com", sent[0].To)
+	assert.Equal(t, "Reset your password", sent[0].Subject)
+	assert.Contains(t, sent[0].Body, "Hi One1 yean")
+	assert.Contains(t, sent[0].Body, "30m0s")
+	token := tokenFrom(t, sent[0])
+	assert.NotEqual(t, token, tokenFrom(t, sent[1]))
+
+	assert.ErrorIs(t, service.ResetPassword(ctx, "not-a-token", "newpassword", mockConfig), domain.ErrInvalidPasswordResetToken)
+	assert.ErrorIs(t, service.ResetPassword(ctx, token, "one1yean", mockConfig), domain.ErrWeakPassword)
+	require.NoError(t, service.ResetPassword(ctx, token, "newpassword", mockConfig))
+	assert.ErrorIs(t, service.ResetPassword(ctx, token, "again", mockConfig), domain.ErrInvalidPasswordResetToken, "tokens work once")
+	assert.ErrorIs(t, service.ResetPassword(ctx, tokenFrom(t, sent[1]), "again", mockConfig), domain.ErrInvalidPasswordResetToken,
+		"a reset invalidates the other tokens")
+
+	user, err := service.GetUserByID(ctx, claims.ID)
+	require.NoError(t, err)
+	revoked, err := revocations.IsRevoked(ctx, claims.TokenID(), claims.ID, user.PasswordChangedAt.Add(-time.Second))
+	require.NoError(t, err)
+	assert.True(t, revoked, "sessions end")
+	_, err = service.RefreshToken(ctx, registered.RefreshToken, mockConfig)
+	assert.Error(t, err)
+
+	_, err = service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials, "the lockout was lifted and the old password is gone")
+	require.NoError(t, service.ForgotPassword(ctx, "test@gmail.com", mockConfig))
+	require.NoError(t, service.ResetPassword(ctx, tokenFrom(t, sent[2]), "newerpassword", mockConfig))
+	_, err = service.Login(ctx, "test@gmail.com", "newerpassword", "", mockConfig)
+	assert.NoError(t, err)
+}
+
+func TestLoginRightAfterPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	revocations := memory.NewTokenRevocationStore()
+	notifier := new(MockNotifier)
+	service := newTestService(t, withRevocations(revocations), withNotifier(notifier))
+	mockConfig := config.Container{
+		JWT:           &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		PasswordReset: &config.PasswordReset{TokenTTL: 30 * time.Minute, URL: "https://app.example.com/reset?lang=en"},
+	}
+	var sent []domain.Message
+	notifier.On("Notify", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent = append(sent, args.Get(1).(domain.Message))
+	}).Return(nil)
+	_, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+
+	require.NoError(t, service.ForgotPassword(ctx, "test@gmail.com", mockConfig))
+	require.Len(t, sent, 1)
+	require.NoError(t, service.ResetPassword(ctx, tokenFrom(t, sent[0]), "newpassword", mockConfig))
+	tokens, err := service.Login(ctx, "test@gmail.com", "newpassword", "", mockConfig)
+	require.NoError(t, err)
+
+	claims, err := helpers.ParseJWT(tokens.AccessToken, mockConfig)
+	require.NoError(t, err)
+	revoked, err := revocations.IsRevoked(ctx, claims.TokenID(), claims.ID, claims.IssuedAt.Time)
+	require.NoError(t, err)
+	assert.False(t, revoked, "a token issued in the same second as the reset keeps working")
 }
 
-func TestLogTotalUsers(t *testing.T) {
+func TestPasswordResetExpires(t *testing.T) {
+	ctx := context.Background()
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	notifier := new(MockNotifier)
+	service := newTestService(t, withUsers(mockRepo), withNotifier(notifier))
+	mockConfig := config.Container{PasswordReset: &config.PasswordReset{TokenTTL: -time.Second}}
+	mockRepo.On("GetUserByEmail", mock.Anything, "test@gmail.com").Return(&domain.User{ID: "123", Email: "test@gmail.com"}, nil)
+	var message domain.Message
+	notifier.On("Notify", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		message = args.Get(1).(domain.Message)
+	}).Return(nil)
+
+	require.NoError(t, service.ForgotPassword(ctx, "test@gmail.com", mockConfig))
+	assert.NotContains(t, message.Body, "http", "without a URL the message carries the bare token")
+
+	_, token, _ := strings.Cut(message.Body, ":\n\n")
+	token, _, _ = strings.Cut(token, "\n")
+	assert.ErrorIs(t, service.ResetPassword(ctx, token, "newpassword", mockConfig), domain.ErrInvalidPasswordResetToken)
+	mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// recordingResetTokens records the reset tokens saved.
+type recordingResetTokens struct {
+	ports.PasswordResetTokenRepository
+	saved []domain.PasswordResetToken
+}
+
+func (r *recordingResetTokens) Save(ctx context.Context, token domain.PasswordResetToken) error {
+	r.saved = append(r.saved, token)
+	return r.PasswordResetTokenRepository.Save(ctx, token)
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	ctx := context.Background()
+	resetTokens := &recordingResetTokens{PasswordResetTokenRepository: memory.NewPasswordResetTokenRepository()}
+	notifier := new(MockNotifier)
+	service := newTestService(t, withPasswordResetTokens(resetTokens), withNotifier(notifier))
+	mockConfig := config.Container{
+		JWT:           &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		PasswordReset: &config.PasswordReset{TokenTTL: time.Hour},
+	}
+	notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+	registered, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	require.NoError(t, err)
+
+	require.NoError(t, service.ForgotPassword(ctx, "test@gmail.com", mockConfig))
+	require.NoError(t, service.ForgotPassword(ctx, "unknown@gmail.com", mockConfig))
+	require.Len(t, resetTokens.saved, 2, "unknown emails store a token like known ones")
+	assert.Equal(t, claims.ID, resetTokens.saved[0].UserID)
+	assert.Empty(t, resetTokens.saved[1].UserID)
+	notifier.AssertNumberOfCalls(t, "Notify", 1)
+
+	require.NoError(t, resetTokens.Save(ctx, domain.PasswordResetToken{TokenHash: helpers.HashToken("decoy"), ExpiresAt: time.Now().Add(time.Hour)}))
+	assert.ErrorIs(t, service.ResetPassword(ctx, "decoy", "newpassword", mockConfig), domain.ErrInvalidPasswordResetToken,
+		"tokens of unknown emails reset nothing")
+}
+
+func TestForgotPasswordNotifierFails(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	notifier := new(MockNotifier)
+	service := newTestService(t, withUsers(mockRepo), withNotifier(notifier))
+	mockRepo.On("GetUserByEmail", mock.Anything, "test@gmail.com").Return(&domain.User{ID: "123", Email: "test@gmail.com"}, nil)
+	notifier.On("Notify", mock.Anything, mock.Anything).Return(errors.New("smtp: connection refused"))
+
+	err := service.ForgotPassword(context.Background(), "test@gmail.com", config.Container{PasswordReset: &config.PasswordReset{TokenTTL: time.Minute}})
+	assert.EqualError(t, err, "smtp: connection refused")
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	revocations := memory.NewTokenRevocationStore()
+	resetTokens := memory.NewPasswordResetTokenRepository()
+	service := newTestService(t, withRevocations(revocations), withPasswordResetTokens(resetTokens))
+	mockConfig := config.Container{
+		JWT:            &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		PasswordPolicy: &domain.PasswordPolicy{MinLength: 8},
+	}
+
+	registered, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	require.NoError(t, err)
+	require.NoError(t, resetTokens.Save(ctx, domain.PasswordResetToken{UserID: claims.ID, TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}))
+
+	_, err = service.ChangePassword(ctx, claims.ID, "wrongpassword", "newpasswordkrub", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrIncorrectPassword)
+	_, err = service.ChangePassword(ctx, claims.ID, "passwordkrub", "short", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrWeakPassword)
+	_, err = service.ChangePassword(ctx, "unknown", "passwordkrub", "newpasswordkrub", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+
+	changed, err := service.ChangePassword(ctx, claims.ID, "passwordkrub", "newpasswordkrub", mockConfig)
+	require.NoError(t, err)
+
+	user, err := service.GetUserByID(ctx, claims.ID)
+	require.NoError(t, err)
+	assert.False(t, user.PasswordChangedAt.IsZero())
+	revoked, err := revocations.IsRevoked(ctx, claims.TokenID(), claims.ID, user.PasswordChangedAt.Add(-time.Second))
+	require.NoError(t, err)
+	assert.True(t, revoked, "tokens issued before the change stop working")
+	newClaims, err := helpers.ParseJWT(changed.AccessToken, mockConfig)
+	require.NoError(t, err)
+	revoked, err = revocations.IsRevoked(ctx, newClaims.TokenID(), newClaims.ID, newClaims.IssuedAt.Time)
+	require.NoError(t, err)
+	assert.False(t, revoked, "the returned token keeps working")
+
+	_, err = service.RefreshToken(ctx, registered.RefreshToken, mockConfig)
+	assert.Error(t, err, "other sessions end")
+	_, err = service.RefreshToken(ctx, changed.RefreshToken, mockConfig)
+	assert.NoError(t, err)
+	_, err = resetTokens.Consume(ctx, "hash", time.Now())
+	assert.ErrorIs(t, err, domain.ErrPasswordResetTokenNotFound, "pending resets are dropped")
+
+	_, err = service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	_, err = service.Login(ctx, "test@gmail.com", "newpasswordkrub", "", mockConfig)
+	assert.NoError(t, err)
+}
+
+func TestPasswordChangeRevokesTokensOfTheSameSecond(t *testing.T) {
+	ctx := context.Background()
+	mockConfig := config.Container{
+		JWT:           &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		PasswordReset: &config.PasswordReset{TokenTTL: 30 * time.Minute, URL: "https://app.example.com/reset?lang=en"},
+	}
+	changes := map[string]func(t *testing.T, service ports.UserService, id string, sent *[]domain.Message){
+		"change": func(t *testing.T, service ports.UserService, id string, sent *[]domain.Message) {
+			_, err := service.ChangePassword(ctx, id, "passwordkrub", "newpasswordkrub", mockConfig)
+			require.NoError(t, err)
+		},
+		"reset": func(t *testing.T, service ports.UserService, id string, sent *[]domain.Message) {
+			require.NoError(t, service.ForgotPassword(ctx, "test@gmail.com", mockConfig))
+			require.NoError(t, service.ResetPassword(ctx, tokenFrom(t, (*sent)[0]), "newpasswordkrub", mockConfig))
+		},
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			revocations := memory.NewTokenRevocationStore()
+			notifier := new(MockNotifier)
+			var sent []domain.Message
+			notifier.On("Notify", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				sent = append(sent, args.Get(1).(domain.Message))
+			}).Return(nil)
+			service := newTestService(t, withRevocations(revocations), withNotifier(notifier))
+			_, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+			require.NoError(t, err)
+
+			// Start right after a whole second, so the login and the change share it.
+			time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+			tokens, err := service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+			require.NoError(t, err)
+			claims, err := helpers.ParseJWT(tokens.AccessToken, mockConfig)
+			require.NoError(t, err)
+			time.Sleep(2 * time.Millisecond)
+			change(t, service, claims.ID, &sent)
+
+			user, err := service.GetUserByID(ctx, claims.ID)
+			require.NoError(t, err)
+			require.Equal(t, user.PasswordChangedAt.Truncate(time.Second), claims.IssuedAt.Truncate(time.Second), "the login and the change share a second")
+			revoked, err := revocations.IsRevoked(ctx, claims.TokenID(), claims.ID, claims.IssuedAt.Time)
+			require.NoError(t, err)
+			assert.True(t, revoked, "a token issued earlier in the same second stops working")
+		})
+	}
+}
+
+func TestChangePasswordCountsFailures(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+	mockConfig := config.Container{
+		JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		Lockout: &config.Lockout{
+			Account: domain.LockoutPolicy{LockAfter: 2, LockDuration: time.Hour},
+		},
+	}
+	registered, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	require.NoError(t, err)
+
+	_, err = service.Login(ctx, "test@gmail.com", "wrongpassword", "", mockConfig)
+	require.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	_, err = service.ChangePassword(ctx, claims.ID, "wrongpassword", "newpasswordkrub", mockConfig)
+	require.ErrorIs(t, err, domain.ErrIncorrectPassword)
+
+	_, err = service.ChangePassword(ctx, claims.ID, "passwordkrub", "newpasswordkrub", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrLoginThrottled, "guessing the current password locks the account like failed logins")
+	_, err = service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrLoginThrottled)
+}
+
+func TestEmailVerification(t *testing.T) {
+	ctx := context.Background()
+	notifier := new(MockNotifier)
+	service := newTestService(t, withNotifier(notifier))
+	mockConfig := config.Container{
+		JWT:               &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		EmailVerification: &config.EmailVerification{TokenTTL: time.Hour, URL: "https://app.example.com/verify?lang=en"},
+	}
+	var sent []domain.Message
+	notifier.On("Notify", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent = append(sent, args.Get(1).(domain.Message))
+	}).Return(nil)
+
+	registered, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub", EmailVerified: true}, mockConfig)
+	require.NoError(t, err)
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	require.NoError(t, err)
+	assert.False(t, claims.EmailVerified, "clients can't verify their own email")
+	require.Len(t, sent, 1)
+	assert.Equal(t, "test@gmail.com", sent[0].To)
+	assert.Equal(t, "Verify your email", sent[0].Subject)
 
-	mockRepo.On("GetUserCount", mock.Anything).Return(int64(100), nil)
+	require.NoError(t, service.ResendVerification(ctx, claims.ID, mockConfig))
+	require.Len(t, sent, 2)
+	assert.ErrorIs(t, service.VerifyEmail(ctx, tokenFrom(t, sent[0])), domain.ErrInvalidEmailVerificationToken,
+		"resending invalidates the tokens sent before")
+	assert.ErrorIs(t, service.VerifyEmail(ctx, "not-a-token"), domain.ErrInvalidEmailVerificationToken)
 
-	go service.LogTotalUser(context.Background())
+	require.NoError(t, service.VerifyEmail(ctx, tokenFrom(t, sent[1])))
+	user, err := service.GetUserByID(ctx, claims.ID)
+	require.NoError(t, err)
+	assert.True(t, user.EmailVerified)
+	assert.ErrorIs(t, service.ResendVerification(ctx, claims.ID, mockConfig), domain.ErrEmailAlreadyVerified)
 
-	time.Sleep(12 * time.Second)
+	refreshed, err := service.RefreshToken(ctx, registered.RefreshToken, mockConfig)
+	require.NoError(t, err)
+	claims, err = helpers.ParseJWT(refreshed.AccessToken, mockConfig)
+	require.NoError(t, err)
+	assert.True(t, claims.EmailVerified, "refreshed tokens carry the new state")
+
+	require.NoError(t, service.UpdateUser(ctx, claims.ID, domain.User{Name: "Renamed"}, mockConfig))
+	require.NoError(t, service.UpdateUser(ctx, claims.ID, domain.User{Email: "TEST@gmail.com"}, mockConfig))
+	require.Len(t, sent, 2, "neither a new name nor a differently cased email needs verifying")
+	user, _ = service.GetUserByID(ctx, claims.ID)
+	assert.True(t, user.EmailVerified)
+
+	require.NoError(t, service.UpdateUser(ctx, claims.ID, domain.User{Email: "new@gmail.com"}, mockConfig))
+	require.Len(t, sent, 3)
+	assert.Equal(t, "new@gmail.com", sent[2].To)
+	assert.Contains(t, sent[2].Body, "Hi Renamed")
+	user, _ = service.GetUserByID(ctx, claims.ID)
+	assert.False(t, user.EmailVerified, "a new email has to be verified again")
+
+	require.NoError(t, service.UpdateUser(ctx, claims.ID, domain.User{Email: "other@gmail.com"}, mockConfig))
+	assert.ErrorIs(t, service.VerifyEmail(ctx, tokenFrom(t, sent[2])), domain.ErrInvalidEmailVerificationToken,
+		"tokens don't verify a later email")
+	require.NoError(t, service.VerifyEmail(ctx, tokenFrom(t, sent[3])))
+}
+
+func TestRegisterWithoutEmailVerification(t *testing.T) {
+	service := newTestService(t)
+	mockConfig := config.Container{JWT: &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour}}
+
+	registered, err := service.Register(context.Background(), domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	require.NoError(t, err)
+	assert.True(t, claims.EmailVerified, "emails are trusted when verification isn't enabled")
+	assert.ErrorIs(t, service.ResendVerification(context.Background(), claims.ID, mockConfig), domain.ErrEmailVerificationDisabled)
+}
+
+func TestRegisterNotifierFails(t *testing.T) {
+	notifier := new(MockNotifier)
+	service := newTestService(t, withNotifier(notifier))
+	notifier.On("Notify", mock.Anything, mock.Anything).Return(errors.New("smtp: connection refused"))
+	mockConfig := config.Container{
+		JWT:               &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		EmailVerification: &config.EmailVerification{TokenTTL: time.Hour},
+	}
+
+	registered, err := service.Register(context.Background(), domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+
+	assert.NoError(t, err, "the user can ask for another message")
+	assert.NotEmpty(t, registered.AccessToken)
+}
+
+// tokenFrom reads the token from the link in a password reset or email verification message.
+func tokenFrom(t *testing.T, message domain.Message) string {
+	t.Helper()
+	for _, line := range strings.Split(message.Body, "\n") {
+		if link, err := url.Parse(line); err == nil && link.Scheme == "https" {
+			assert.Equal(t, "en", link.Query().Get("lang"))
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatalf("no token link in %q", message.Body)
+	return ""
+}
+
+func TestTwoFactor(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+	now := time.Now()
+	service.(*UserServiceImpl).now = func() time.Time { return now }
+	mockConfig := config.Container{
+		JWT:       &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		TwoFactor: &config.TwoFactor{Issuer: "backend-challenge", PendingTokenTTL: 5 * time.Minute, RecoveryCodes: 2},
+		Lockout: &config.Lockout{
+			Account: domain.LockoutPolicy{LockAfter: 3, LockDuration: time.Hour},
+		},
+	}
+	registered, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	require.NoError(t, err)
+
+	_, err = service.ConfirmTwoFactor(ctx, claims.ID, "passwordkrub", "123456", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrTwoFactorNotEnabled, "confirming needs an enrollment")
+	assert.ErrorIs(t, service.DisableTwoFactor(ctx, claims.ID, "passwordkrub", "123456", mockConfig), domain.ErrTwoFactorNotEnabled)
+
+	enrollment, err := service.EnrollTwoFactor(ctx, claims.ID, mockConfig)
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	tokens, err := service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken, "an unconfirmed enrollment doesn't change logins")
+
+	code, err := helpers.TOTPCode(enrollment.Secret, now)
+	require.NoError(t, err)
+	_, err = service.ConfirmTwoFactor(ctx, claims.ID, "wrongpassword", code, mockConfig)
+	assert.ErrorIs(t, err, domain.ErrIncorrectPassword, "a stolen access token alone can't turn two-factor on")
+	user, err := service.GetUserByID(ctx, claims.ID)
+	require.NoError(t, err)
+	assert.False(t, user.TwoFactorEnabled)
+	recoveryCodes, err := service.ConfirmTwoFactor(ctx, claims.ID, "passwordkrub", code, mockConfig)
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, 2)
+	_, err = service.EnrollTwoFactor(ctx, claims.ID, mockConfig)
+	assert.ErrorIs(t, err, domain.ErrTwoFactorAlreadyEnabled)
+	user, err = service.GetUserByID(ctx, claims.ID)
+	require.NoError(t, err)
+	assert.True(t, user.TwoFactorEnabled)
+
+	tokens, err = service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	require.NoError(t, err)
+	assert.Empty(t, tokens.AccessToken, "the password alone isn't enough")
+	assert.Empty(t, tokens.RefreshToken)
+	require.NotEmpty(t, tokens.MFAToken)
+	_, err = helpers.ParseJWT(tokens.MFAToken, mockConfig)
+	assert.Error(t, err, "the MFA token doesn't work as an access token")
+
+	_, err = service.LoginTwoFactor(ctx, tokens.MFAToken, code, "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidTwoFactorCode, "the code used to confirm can't be used again")
+	now = now.Add(30 * time.Second)
+	code, err = helpers.TOTPCode(enrollment.Secret, now)
+	require.NoError(t, err)
+	loggedIn, err := service.LoginTwoFactor(ctx, tokens.MFAToken, code, "", mockConfig)
+	require.NoError(t, err)
+	assert.NotEmpty(t, loggedIn.AccessToken)
+	assert.NotEmpty(t, loggedIn.RefreshToken)
+	_, err = service.LoginTwoFactor(ctx, tokens.MFAToken, code, "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidTwoFactorCode, "every code works once")
+
+	_, err = service.LoginTwoFactor(ctx, tokens.MFAToken, strings.ToUpper(recoveryCodes[0]), "", mockConfig)
+	require.NoError(t, err, "recovery codes work in any case")
+	_, err = service.LoginTwoFactor(ctx, tokens.MFAToken, recoveryCodes[0], "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidTwoFactorCode, "recovery codes work once")
+
+	now = now.Add(6 * time.Minute)
+	code, err = helpers.TOTPCode(enrollment.Secret, now)
+	require.NoError(t, err)
+	_, err = service.LoginTwoFactor(ctx, tokens.MFAToken, code, "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidMFAToken, "the MFA token expired")
+	_, err = service.LoginTwoFactor(ctx, "not a token", code, "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidMFAToken)
+
+	assert.ErrorIs(t, service.DisableTwoFactor(ctx, claims.ID, "wrongpassword", code, mockConfig), domain.ErrIncorrectPassword)
+	require.NoError(t, service.DisableTwoFactor(ctx, claims.ID, "passwordkrub", code, mockConfig))
+	user, err = service.GetUserByID(ctx, claims.ID)
+	require.NoError(t, err)
+	assert.False(t, user.TwoFactorEnabled)
+	assert.Empty(t, user.TOTPSecret)
+	assert.Empty(t, user.RecoveryCodes)
+	_, err = service.LoginTwoFactor(ctx, tokens.MFAToken, recoveryCodes[1], "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrInvalidMFAToken)
+	tokens, err = service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
+
+func TestTwoFactorCountsFailures(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+	now := time.Now()
+	service.(*UserServiceImpl).now = func() time.Time { return now }
+	mockConfig := config.Container{
+		JWT:       &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		TwoFactor: &config.TwoFactor{Issuer: "backend-challenge", PendingTokenTTL: 5 * time.Minute, RecoveryCodes: 1},
+		Lockout: &config.Lockout{
+			Account: domain.LockoutPolicy{LockAfter: 3, LockDuration: time.Hour},
+		},
+	}
+	registered, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	require.NoError(t, err)
+	enrollment, err := service.EnrollTwoFactor(ctx, claims.ID, mockConfig)
+	require.NoError(t, err)
+	code, err := helpers.TOTPCode(enrollment.Secret, now)
+	require.NoError(t, err)
+	_, err = service.ConfirmTwoFactor(ctx, claims.ID, "passwordkrub", code, mockConfig)
+	require.NoError(t, err)
+
+	_, err = service.Login(ctx, "test@gmail.com", "wrongpassword", "", mockConfig)
+	require.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	tokens, err := service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	require.NoError(t, err)
+	_, err = service.LoginTwoFactor(ctx, tokens.MFAToken, "000000", "", mockConfig)
+	require.ErrorIs(t, err, domain.ErrInvalidTwoFactorCode)
+	_, err = service.LoginTwoFactor(ctx, tokens.MFAToken, "aaaaa-bbbbb", "", mockConfig)
+	require.ErrorIs(t, err, domain.ErrInvalidTwoFactorCode)
+
+	now = now.Add(30 * time.Second)
+	code, err = helpers.TOTPCode(enrollment.Secret, now)
+	require.NoError(t, err)
+	_, err = service.LoginTwoFactor(ctx, tokens.MFAToken, code, "", mockConfig)
+	assert.ErrorIs(t, err, domain.ErrLoginThrottled, "the password step doesn't clear the failures, wrong codes lock the account")
+}
+
+// barrierUserRepository holds every GetUserByID call until all expected
+// readers have read the user, so concurrent requests see the same state.
+type barrierUserRepository struct {
+	ports.UserRepository
+	readers sync.WaitGroup
+}
+
+func (r *barrierUserRepository) GetUserByID(ctx context.Context, id string) (domain.User, error) {
+	user, err := r.UserRepository.GetUserByID(ctx, id)
+	r.readers.Done()
+	r.readers.Wait()
+	return user, err
+}
+
+func TestTwoFactorCodesWorkOnceConcurrently(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewUserRepository()
+	service := newTestService(t, withUsers(repo))
+	now := time.Now()
+	service.(*UserServiceImpl).now = func() time.Time { return now }
+	mockConfig := config.Container{
+		JWT:       &config.JWT{SecretKey: []byte("secret"), RefreshTokenTTL: time.Hour},
+		TwoFactor: &config.TwoFactor{Issuer: "backend-challenge", PendingTokenTTL: 5 * time.Minute, RecoveryCodes: 3},
+	}
+	registered, err := service.Register(ctx, domain.User{Name: "One1 yean", Email: "test@gmail.com", Password: "passwordkrub"}, mockConfig)
+	require.NoError(t, err)
+	claims, err := helpers.ParseJWT(registered.AccessToken, mockConfig)
+	require.NoError(t, err)
+	enrollment, err := service.EnrollTwoFactor(ctx, claims.ID, mockConfig)
+	require.NoError(t, err)
+	code, err := helpers.TOTPCode(enrollment.Secret, now)
+	require.NoError(t, err)
+	recoveryCodes, err := service.ConfirmTwoFactor(ctx, claims.ID, "passwordkrub", code, mockConfig)
+	require.NoError(t, err)
+	tokens, err := service.Login(ctx, "test@gmail.com", "passwordkrub", "", mockConfig)
+	require.NoError(t, err)
+
+	now = now.Add(30 * time.Second)
+	code, err = helpers.TOTPCode(enrollment.Secret, now)
+	require.NoError(t, err)
+	for _, code := range []string{code, recoveryCodes[0]} {
+		const attempts = 2
+		barrier := &barrierUserRepository{UserRepository: repo}
+		barrier.readers.Add(attempts)
+		service.(*UserServiceImpl).UserRepository = barrier
+
+		errs := make([]error, attempts)
+		var wg sync.WaitGroup
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = service.LoginTwoFactor(ctx, tokens.MFAToken, code, "", mockConfig)
+			}(i)
+		}
+		wg.Wait()
+
+		succeeded := 0
+		for _, err := range errs {
+			if err == nil {
+				succeeded++
+				continue
+			}
+			assert.ErrorIs(t, err, domain.ErrInvalidTwoFactorCode)
+		}
+		assert.Equal(t, 1, succeeded, "only one request gets through with the same code")
+	}
 
-	mockRepo.AssertCalled(t, "GetUserCount", mock.Anything)
+	user, err := repo.GetUserByID(ctx, claims.ID)
+	require.NoError(t, err)
+	assert.Len(t, user.RecoveryCodes, 2)
 }